@@ -2,6 +2,7 @@
 
 echo "🔧 Testing CORS Configuration"
 echo "============================="
+echo "The same checks run without a server in: cd src/backend/go && go test -run CORS ./server"
 
 # Colors for output
 GREEN='\033[0;32m'
@@ -24,10 +25,16 @@ echo "$response"
 echo ""
 
 # Check for required CORS headers
-if echo "$response" | grep -q "Access-Control-Allow-Origin: \*"; then
-  echo -e "${GREEN}✅ Access-Control-Allow-Origin: * - PASS${NC}"
+if echo "$response" | grep -qi "Access-Control-Allow-Origin: http://localhost:3000"; then
+  echo -e "${GREEN}✅ Access-Control-Allow-Origin: http://localhost:3000 - PASS${NC}"
 else
-  echo -e "${RED}❌ Access-Control-Allow-Origin header missing - FAIL${NC}"
+  echo -e "${RED}❌ Access-Control-Allow-Origin header missing (is the origin in cors.allowedOrigins?) - FAIL${NC}"
+fi
+
+if echo "$response" | grep -qi "Vary: .*Origin"; then
+  echo -e "${GREEN}✅ Vary: Origin header present - PASS${NC}"
+else
+  echo -e "${RED}❌ Vary: Origin header missing - FAIL${NC}"
 fi
 
 if echo "$response" | grep -q "Access-Control-Allow-Methods:"; then
@@ -126,7 +133,7 @@ echo ""
 echo "🏁 CORS Test Complete!"
 echo ""
 echo "📋 Next steps:"
-echo "1. Make sure your Go server is running: cd src/backend/go/cmd && go run main.go"
+echo "1. Make sure your Go server is running: cd src/backend/go/cmd && go run ."
 echo "2. Open cors-test.html in your browser"
 echo "3. Click the 'Test User Creation API' button"
 echo "4. If it works, CORS is properly configured!"
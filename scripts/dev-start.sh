@@ -45,7 +45,7 @@ echo "🗄️  Starting Backend Services..."
 # Start Go WebSocket server (if exists)
 if [ -d "src/backend/go" ]; then
     echo "   Starting Go WebSocket server..."
-    run_service "Go Server" "go run cmd/server/main.go" "src/backend/go"
+    run_service "Go Server" "go run ." "src/backend/go/cmd"
 else
     echo "   ⚠️  Go backend not found (will use mock data)"
 fi
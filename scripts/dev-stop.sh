@@ -47,7 +47,7 @@ echo "🧹 Cleaning up any remaining processes..."
 pkill -f "vite" 2>/dev/null && echo "   Stopped Vite processes"
 
 # Stop Go processes
-pkill -f "go run cmd/server/main.go" 2>/dev/null && echo "   Stopped Go server processes"
+pkill -f "go run \." 2>/dev/null && echo "   Stopped Go server processes"
 
 # Stop Python processes
 pkill -f "python.*app.main" 2>/dev/null && echo "   Stopped Python AI processes"
@@ -0,0 +1,149 @@
+// Package api has the request and response bodies of the quiz, session and prize routes,
+// shared by the server and the client package
+package api
+
+import (
+	"delfos/prizes"
+	"delfos/scoring"
+	"delfos/sessions"
+)
+
+// ChooseQuestionsResponse represents the question numbers drawn for a profile
+// The terminal builds the question IDs from them, e.g. profile "1" and 7 give CRD0007; a session
+// always gets back the set it was first issued, with its profile
+type ChooseQuestionsResponse struct {
+	Event       string `json:"event"`
+	Profile     string `json:"profile"`
+	QuestionIds []int  `json:"questionIds"`
+}
+
+// CreateUserRequest represents the request body for user creation
+type CreateUserRequest struct {
+	UserEmail string `json:"userEmail"`
+	SessionID string `json:"sessionId"`
+	Timestamp string `json:"timestamp,omitempty"` // Optional frontend timestamp
+}
+
+// CreateUserResponse represents the response for user creation
+type CreateUserResponse struct {
+	Status       string        `json:"status"`
+	Message      string        `json:"message"`
+	Event        string        `json:"event"`
+	Filename     string        `json:"filename"`
+	User         sessions.User `json:"user"`
+	SessionToken string        `json:"sessionToken"` // Resumes the session after a refresh, see ResumeSessionRequest
+}
+
+// UpdateUserRequest represents the request body for updating user with answers
+type UpdateUserRequest struct {
+	UserEmail   string   `json:"userEmail"`
+	SessionID   string   `json:"sessionId"`
+	QuestionIds []int    `json:"questionIds"`
+	UserAnswers []string `json:"userAnswers"`
+	AnsweredAt  []string `json:"answeredAt,omitempty"` // Optional RFC3339 time each answer was given, in userAnswers order
+}
+
+// UpdateUserResponse represents the response for user update
+type UpdateUserResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// EvaluateAnswersRequest represents the request body for answer evaluation
+type EvaluateAnswersRequest struct {
+	QuestionIds []string `json:"questionIds"`
+	UserAnswers []string `json:"userAnswers"`
+	UserEmail   string   `json:"userEmail,omitempty"` // Optional, records the result in the event when set with sessionId
+	SessionID   string   `json:"sessionId,omitempty"`
+}
+
+// EvaluateAnswersResponse represents the response for answer evaluation
+type EvaluateAnswersResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Event   string `json:"event,omitempty"`
+	Receipt string `json:"receipt,omitempty"` // Signed evaluation receipt, set for a session; the prize routes require it
+	scoring.Score
+}
+
+// SubmitAnswerRequest represents one answer of a session, sent to /session/{id}/answer
+type SubmitAnswerRequest struct {
+	UserEmail  string `json:"userEmail"`
+	QuestionID string `json:"questionId"`
+	Answer     string `json:"answer"` // Option letter as the session was shown the options
+}
+
+// SubmitAnswerResponse represents the answer a session holds for a question
+type SubmitAnswerResponse struct {
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Event      string `json:"event"`
+	QuestionID string `json:"questionId"`
+	Answer     string `json:"answer"`
+	Repeated   bool   `json:"repeated"` // The answer was already recorded, e.g. by a retry from this or another kiosk
+	Answered   int    `json:"answered"` // Questions of the session answered so far
+	Total      int    `json:"total"`    // Questions issued to the session
+}
+
+// EvaluateSessionRequest represents the request body for scoring the answers a session holds
+type EvaluateSessionRequest struct {
+	UserEmail string `json:"userEmail"`
+}
+
+// ResumeSessionRequest represents the request body for resuming a session: the email with the
+// resume code shown at registration, or the session token the kiosk kept
+type ResumeSessionRequest struct {
+	UserEmail    string `json:"userEmail,omitempty"`
+	ResumeCode   string `json:"resumeCode,omitempty"`
+	SessionToken string `json:"sessionToken,omitempty"`
+}
+
+// ResumeSessionResponse represents a session where it was left off
+type ResumeSessionResponse struct {
+	Status           string   `json:"status"`
+	Message          string   `json:"message"`
+	Event            string   `json:"event"`
+	UserEmail        string   `json:"userEmail"`
+	SessionID        string   `json:"sessionId"`
+	SessionToken     string   `json:"sessionToken"`
+	Profile          string   `json:"profile,omitempty"`          // Unset until questions are issued
+	QuestionIds      []int    `json:"questionIds"`                // Issued question numbers, as /choose-questions returns them
+	Answers          []string `json:"answers"`                    // Recorded answer of each question, in the letters shown; empty when unanswered
+	Answered         int      `json:"answered"`                   // Questions answered so far
+	RemainingSeconds int      `json:"remainingSeconds,omitempty"` // Server-side time left before the session expires; unset when sessions do not expire
+	ExpiresAt        string   `json:"expiresAt,omitempty"`
+}
+
+// WinnerCountRequest represents the request body for updating winner count
+type WinnerCountRequest struct {
+	UserEmail string `json:"userEmail"`
+	SessionID string `json:"sessionId"`
+	Receipt   string `json:"receipt"` // From the session's passed evaluation
+}
+
+// WinnerCountResponse represents the response for winner count operations
+type WinnerCountResponse struct {
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Event       string `json:"event"`
+	WinnerCount int    `json:"winnerCount"`
+	MaxWinners  int    `json:"maxWinners,omitempty"` // Winner cap of the event, 0 means unlimited
+	UpdatedAt   string `json:"updatedAt,omitempty"`
+}
+
+// DrawPrizeRequest represents the request body for drawing a prize
+type DrawPrizeRequest struct {
+	UserEmail string `json:"userEmail"`
+	SessionID string `json:"sessionId"`
+	Receipt   string `json:"receipt"` // From the session's passed evaluation
+}
+
+// DrawPrizeResponse represents the response for a prize draw
+type DrawPrizeResponse struct {
+	Status      string       `json:"status"`
+	Message     string       `json:"message"`
+	Event       string       `json:"event"`
+	Prize       prizes.Prize `json:"prize"`
+	WinnerCount int          `json:"winnerCount"`
+	AwardedAt   string       `json:"awardedAt"`
+}
@@ -0,0 +1,291 @@
+// Package client is a typed Go client for the DelfosProfiler API
+//
+// It calls the versioned /api/v1 routes, retries requests the server did not process, and returns
+// error responses as *Error with the code of the JSON error envelope
+//
+//	c := client.New("http://localhost:8080")
+//	c.KioskToken = token
+//	drawn, err := c.ChooseQuestions(ctx, "1")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIPrefix is the path every route is called under
+const APIPrefix = "/api/v1"
+
+// Client calls one server; set its fields before the first call
+type Client struct {
+	BaseURL       string       // Scheme and host, e.g. http://localhost:8080
+	HTTPClient    *http.Client // Its Timeout bounds each attempt
+	KioskToken    string       // Sent as X-Kiosk-Token when set
+	Event         string       // Sent as X-Event-ID when set, otherwise the server picks the kiosk's or the default event
+	Retries       int          // Extra attempts for requests the server did not process
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration // Upper bound of a Retry-After the client waits for
+}
+
+// New returns a client with a 10s timeout per attempt and two retries
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		HTTPClient:    &http.Client{Timeout: 10 * time.Second},
+		Retries:       2,
+		RetryDelay:    250 * time.Millisecond,
+		MaxRetryDelay: 5 * time.Second,
+	}
+}
+
+// Error is an error response of the server
+type Error struct {
+	StatusCode int
+	Code       string          // Stable machine readable code, e.g. MISSING_FIELDS
+	Message    string          // For people, may change
+	Details    json.RawMessage // null or an object that depends on the code
+	RequestID  string          // Matches the server logs
+	RetryAfter time.Duration   // From the Retry-After header of 429 and 503 responses
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("delfos: %s: %s (status %d, request %s)", e.Code, e.Message, e.StatusCode, e.RequestID)
+}
+
+// ErrorCode returns the code of an *Error in the chain of err, or "" for other errors
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// CreateUser registers a player session
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (*CreateUserResponse, error) {
+	return call[CreateUserResponse](ctx, c, http.MethodPost, "/user/create", nil, req)
+}
+
+// ChooseQuestions draws question numbers for a profile ("1" CRD, "2" SRV, "3" EXP); see QuestionID
+// With a userEmail and sessionID the set is issued to the session, which Evaluate then requires;
+// empty ones draw a practice set
+func (c *Client) ChooseQuestions(ctx context.Context, profile, userEmail, sessionID string) (*ChooseQuestionsResponse, error) {
+	query := url.Values{"profile": {profile}}
+	if userEmail != "" || sessionID != "" {
+		query.Set("userEmail", userEmail)
+		query.Set("sessionId", sessionID)
+	}
+	return call[ChooseQuestionsResponse](ctx, c, http.MethodGet, "/choose-questions", query, nil)
+}
+
+// GetQuestion returns a question with its options by ID, e.g. CRD0007
+// With a userEmail and sessionID the options come in the order issued to the session, and Evaluate
+// expects the letters of that order
+func (c *Client) GetQuestion(ctx context.Context, id, userEmail, sessionID string) (*Question, error) {
+	query := url.Values{"id": {id}}
+	if userEmail != "" || sessionID != "" {
+		query.Set("userEmail", userEmail)
+		query.Set("sessionId", sessionID)
+	}
+	return call[Question](ctx, c, http.MethodGet, "/question", query, nil)
+}
+
+// Evaluate scores answers; with UserEmail and SessionID it records the result and returns the
+// receipt DrawPrize needs
+func (c *Client) Evaluate(ctx context.Context, req EvaluateAnswersRequest) (*EvaluateAnswersResponse, error) {
+	return call[EvaluateAnswersResponse](ctx, c, http.MethodPost, "/evaluate-answers", nil, req)
+}
+
+// SubmitAnswer records one answer of a session, with the letter of the order GetQuestion showed
+// A question keeps its first answer, so a retry from this or another kiosk is safe and reports Repeated
+func (c *Client) SubmitAnswer(ctx context.Context, sessionID string, req SubmitAnswerRequest) (*SubmitAnswerResponse, error) {
+	return call[SubmitAnswerResponse](ctx, c, http.MethodPost, "/session/"+url.PathEscape(sessionID)+"/answer", nil, req)
+}
+
+// EvaluateSession scores the answers a session holds once every question has one, records the
+// result and returns the receipt DrawPrize needs; calling it again returns a fresh receipt
+func (c *Client) EvaluateSession(ctx context.Context, userEmail, sessionID string) (*EvaluateAnswersResponse, error) {
+	return call[EvaluateAnswersResponse](ctx, c, http.MethodPost, "/session/"+url.PathEscape(sessionID)+"/evaluate", nil,
+		EvaluateSessionRequest{UserEmail: userEmail})
+}
+
+// ResumeSession continues a session after a refresh, with the SessionToken CreateUser returned, or
+// from another kiosk, with the email and the user's ResumeCode
+func (c *Client) ResumeSession(ctx context.Context, req ResumeSessionRequest) (*ResumeSessionResponse, error) {
+	return call[ResumeSessionResponse](ctx, c, http.MethodPost, "/session/resume", nil, req)
+}
+
+// WinnerCount returns the winner count of the event
+func (c *Client) WinnerCount(ctx context.Context) (*WinnerCountResponse, error) {
+	return call[WinnerCountResponse](ctx, c, http.MethodGet, "/winner/count", nil, nil)
+}
+
+// DrawPrize draws a prize for a session with the receipt of its passed evaluation; drawing again
+// for the same session returns the same prize
+func (c *Client) DrawPrize(ctx context.Context, req DrawPrizeRequest) (*DrawPrizeResponse, error) {
+	return call[DrawPrizeResponse](ctx, c, http.MethodPost, "/prize/draw", nil, req)
+}
+
+// QuestionID builds a full question ID like CRD0007 from a profile and a number from ChooseQuestions
+func QuestionID(profile string, number int) string {
+	prefix := "CRD"
+	switch profile {
+	case "2":
+		prefix = "SRV"
+	case "3":
+		prefix = "EXP"
+	}
+	return fmt.Sprintf("%s%04d", prefix, number)
+}
+
+// call sends a request and returns its decoded response
+func call[T any](ctx context.Context, c *Client, method, path string, query url.Values, body any) (*T, error) {
+	var response T
+	if err := c.do(ctx, method, path, query, body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// do sends a request and decodes the JSON response into out, retrying while retryable says so
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("delfos: failed to encode request: %w", err)
+		}
+	}
+	target := c.BaseURL + APIPrefix + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, target, payload, out)
+		if err == nil {
+			return nil
+		}
+		delay, ok := c.retryable(method, err, attempt)
+		if !ok || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, target string, payload []byte, out any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("delfos: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.KioskToken != "" {
+		req.Header.Set("X-Kiosk-Token", c.KioskToken)
+	}
+	if c.Event != "" {
+		req.Header.Set("X-Event-ID", c.Event)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("delfos: %s %s: %w", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("delfos: failed to decode %s %s response: %w", method, target, err)
+	}
+	return nil
+}
+
+// decodeError reads the error envelope of a response; bodies that are not an envelope, e.g. from a
+// proxy, keep their status with the code HTTP_<status>
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var envelope struct {
+		Code      string          `json:"code"`
+		Message   string          `json:"message"`
+		Details   json.RawMessage `json:"details"`
+		RequestID string          `json:"requestId"`
+	}
+	if err := json.Unmarshal(content, &envelope); err != nil || envelope.Code == "" {
+		apiErr.Code = "HTTP_" + strconv.Itoa(resp.StatusCode)
+		apiErr.Message = strings.TrimSpace(string(content))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	apiErr.Code, apiErr.Message, apiErr.Details = envelope.Code, envelope.Message, envelope.Details
+	if envelope.RequestID != "" {
+		apiErr.RequestID = envelope.RequestID
+	}
+	return apiErr
+}
+
+// retryable returns how long to wait before retrying a failed attempt, and false when it must not be retried
+// Only requests the server did not process are retried, so a POST is never applied twice: rate limited
+// requests, 503s, and connections that were never made; GETs are also retried on timeouts and gateway errors
+func (c *Client) retryable(method string, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.Retries {
+		return 0, false
+	}
+	delay := c.RetryDelay << attempt
+
+	var apiErr *Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &apiErr):
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			if apiErr.RetryAfter > 0 {
+				delay = apiErr.RetryAfter
+			}
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			if method != http.MethodGet {
+				return 0, false
+			}
+		default:
+			return 0, false
+		}
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+	case method != http.MethodGet:
+		return 0, false // The server may have applied it before the connection failed or timed out
+	}
+
+	if c.MaxRetryDelay > 0 && delay > c.MaxRetryDelay {
+		return 0, false // Waiting longer than the caller allows, let it decide
+	}
+	return delay, true
+}
@@ -0,0 +1,332 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTypesMatchOpenAPI(t *testing.T) {
+	content, err := os.ReadFile("../../../../docs/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type schema struct {
+		Ref        string             `json:"$ref"`
+		Type       string             `json:"type"`
+		Items      *schema            `json:"items"`
+		Properties map[string]*schema `json:"properties"`
+		Required   []string           `json:"required"`
+	}
+	var doc struct {
+		Components struct {
+			Schemas map[string]*schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// kind returns the schema type of a Go type, and the component name of a struct
+	var kind func(reflect.Type) (string, string)
+	kind = func(t reflect.Type) (string, string) {
+		switch t.Kind() {
+		case reflect.String:
+			return "string", ""
+		case reflect.Bool:
+			return "boolean", ""
+		case reflect.Int, reflect.Int64:
+			return "integer", ""
+		case reflect.Float64:
+			return "number", ""
+		case reflect.Slice:
+			item, name := kind(t.Elem())
+			return "array " + item, name
+		}
+		return "object", t.Name()
+	}
+	var schemaKind func(*schema) (string, string)
+	schemaKind = func(s *schema) (string, string) {
+		if name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/"); ok {
+			return "object", name
+		}
+		if s.Type == "array" {
+			item, name := schemaKind(s.Items)
+			return "array " + item, name
+		}
+		return s.Type, ""
+	}
+
+	types := []any{
+		CreateUserRequest{}, CreateUserResponse{}, User{}, ChooseQuestionsResponse{}, Question{},
+		SubmitAnswerRequest{}, SubmitAnswerResponse{}, EvaluateSessionRequest{},
+		ResumeSessionRequest{}, ResumeSessionResponse{},
+		EvaluateAnswersRequest{}, EvaluateAnswersResponse{}, AnswerEvaluationResult{},
+		WinnerCountResponse{}, DrawPrizeRequest{}, DrawPrizeResponse{}, Prize{},
+	}
+	for _, value := range types {
+		typ := reflect.TypeOf(value)
+		spec := doc.Components.Schemas[typ.Name()]
+		if spec == nil {
+			t.Errorf("%s is not in docs/openapi.json", typ.Name())
+			continue
+		}
+
+		var fields, required []string
+		for _, field := range reflect.VisibleFields(typ) {
+			if field.Anonymous {
+				continue // Embedded struct, its fields are promoted
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			fields = append(fields, name)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+
+			property := spec.Properties[name]
+			if property == nil {
+				t.Errorf("%s.%s is not in docs/openapi.json", typ.Name(), name)
+				continue
+			}
+			gotKind, gotName := kind(field.Type)
+			wantKind, wantName := schemaKind(property)
+			if gotKind != wantKind || gotName != wantName {
+				t.Errorf("%s.%s is %s %s, the document has %s %s", typ.Name(), name, gotKind, gotName, wantKind, wantName)
+			}
+		}
+		for name := range spec.Properties {
+			if !slices.Contains(fields, name) {
+				t.Errorf("%s has no field for %s", typ.Name(), name)
+			}
+		}
+		slices.Sort(required)
+		slices.Sort(spec.Required)
+		if !slices.Equal(required, spec.Required) {
+			t.Errorf("%s requires %v, the document requires %v", typ.Name(), required, spec.Required)
+		}
+	}
+}
+
+func TestClientCalls(t *testing.T) {
+	type call struct {
+		method, path, query, body string
+	}
+	var got []call
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, call{r.Method, r.URL.Path, r.URL.RawQuery, string(body)})
+		if r.Header.Get("X-Kiosk-Token") != "token-1" || r.Header.Get("X-Event-ID") != "expo" {
+			t.Errorf("%s: headers = %v", r.URL.Path, r.Header)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/choose-questions":
+			io.WriteString(w, `{"event": "expo", "profile": "1", "questionIds": [3, 7]}`)
+		case "/api/v1/question":
+			io.WriteString(w, `{"id": "CRD0007", "question": "?", "options": ["x", "y"]}`)
+		case "/api/v1/session/s-1/answer":
+			io.WriteString(w, `{"status": "success", "questionId": "CRD0007", "answer": "a", "repeated": true, "answered": 1, "total": 1}`)
+		case "/api/v1/session/resume":
+			io.WriteString(w, `{"status": "success", "sessionId": "s-1", "sessionToken": "t.sig", "questionIds": [3, 7], "answers": ["a", ""], "answered": 1, "remainingSeconds": 90}`)
+		case "/api/v1/evaluate-answers", "/api/v1/session/s-1/evaluate":
+			io.WriteString(w, `{"status": "success", "receipt": "r.sig", "totalQuestions": 1, "correctAnswers": 1, "scorePercentage": 100}`)
+		default:
+			io.WriteString(w, `{"status": "success", "winnerCount": 4}`)
+		}
+	}))
+	defer server.Close()
+
+	c := New(server.URL + "/")
+	c.KioskToken, c.Event = "token-1", "expo"
+	ctx := context.Background()
+
+	if _, err := c.CreateUser(ctx, CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-1"}); err != nil {
+		t.Fatal(err)
+	}
+	drawn, err := c.ChooseQuestions(ctx, "1", "a@example.com", "s-1")
+	if err != nil || !slices.Equal(drawn.QuestionIds, []int{3, 7}) {
+		t.Fatalf("ChooseQuestions = %+v, %v", drawn, err)
+	}
+	question, err := c.GetQuestion(ctx, QuestionID(drawn.Profile, drawn.QuestionIds[1]), "a@example.com", "s-1")
+	if err != nil || question.ID != "CRD0007" || len(question.Options) != 2 {
+		t.Fatalf("GetQuestion = %+v, %v", question, err)
+	}
+	result, err := c.Evaluate(ctx, EvaluateAnswersRequest{QuestionIds: []string{"CRD0007"}, UserAnswers: []string{"a"}, UserEmail: "a@example.com", SessionID: "s-1"})
+	if err != nil || result.ScorePercentage != 100 || result.Receipt != "r.sig" {
+		t.Fatalf("Evaluate = %+v, %v", result, err)
+	}
+	answered, err := c.SubmitAnswer(ctx, "s-1", SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: "CRD0007", Answer: "a"})
+	if err != nil || !answered.Repeated || answered.Answered != 1 {
+		t.Fatalf("SubmitAnswer = %+v, %v", answered, err)
+	}
+	resumed, err := c.ResumeSession(ctx, ResumeSessionRequest{UserEmail: "a@example.com", ResumeCode: "K7PX2M"})
+	if err != nil || resumed.Answered != 1 || !slices.Equal(resumed.Answers, []string{"a", ""}) || resumed.RemainingSeconds != 90 {
+		t.Fatalf("ResumeSession = %+v, %v", resumed, err)
+	}
+	if result, err := c.EvaluateSession(ctx, "a@example.com", "s-1"); err != nil || result.Receipt != "r.sig" {
+		t.Fatalf("EvaluateSession = %+v, %v", result, err)
+	}
+	count, err := c.WinnerCount(ctx)
+	if err != nil || count.WinnerCount != 4 {
+		t.Fatalf("WinnerCount = %+v, %v", count, err)
+	}
+	if _, err := c.DrawPrize(ctx, DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: result.Receipt}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []call{
+		{"POST", "/api/v1/user/create", "", `{"userEmail":"a@example.com","sessionId":"s-1"}`},
+		{"GET", "/api/v1/choose-questions", "profile=1&sessionId=s-1&userEmail=a%40example.com", ""},
+		{"GET", "/api/v1/question", "id=CRD0007&sessionId=s-1&userEmail=a%40example.com", ""},
+		{"POST", "/api/v1/evaluate-answers", "", `{"questionIds":["CRD0007"],"userAnswers":["a"],"userEmail":"a@example.com","sessionId":"s-1"}`},
+		{"POST", "/api/v1/session/s-1/answer", "", `{"userEmail":"a@example.com","questionId":"CRD0007","answer":"a"}`},
+		{"POST", "/api/v1/session/resume", "", `{"userEmail":"a@example.com","resumeCode":"K7PX2M"}`},
+		{"POST", "/api/v1/session/s-1/evaluate", "", `{"userEmail":"a@example.com"}`},
+		{"GET", "/api/v1/winner/count", "", ""},
+		{"POST", "/api/v1/prize/draw", "", `{"userEmail":"a@example.com","sessionId":"s-1","receipt":"r.sig"}`},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("requests = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantCode    string
+		wantMessage string
+		wantRequest string
+	}{
+		{"envelope", http.StatusBadRequest, `{"code": "MISSING_FIELDS", "message": "sessionId is required", "details": {"fields": ["sessionId"]}, "requestId": "req-1"}`, "MISSING_FIELDS", "sessionId is required", "req-1"},
+		{"not an envelope", http.StatusBadGateway, "bad gateway\n", "HTTP_502", "bad gateway", "header-id"},
+		{"empty body", http.StatusNotFound, "", "HTTP_404", "Not Found", "header-id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-ID", "header-id")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer server.Close()
+
+			_, err := New(server.URL).CreateUser(context.Background(), CreateUserRequest{})
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want *Error", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Code != tt.wantCode || apiErr.Message != tt.wantMessage || apiErr.RequestID != tt.wantRequest {
+				t.Errorf("err = %+v", apiErr)
+			}
+			if ErrorCode(err) != tt.wantCode {
+				t.Errorf("ErrorCode = %q, want %q", ErrorCode(err), tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		statuses     []int // Status of each attempt, then 200
+		retryAfter   string
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"get after 503", http.MethodGet, []int{503}, "", 2, false},
+		{"get after 502 and 504", http.MethodGet, []int{502, 504}, "", 3, false},
+		{"get gives up", http.MethodGet, []int{503, 503, 503}, "", 3, true},
+		{"post after 429", http.MethodPost, []int{429}, "", 2, false},
+		{"post not after 502", http.MethodPost, []int{502}, "", 1, true},
+		{"post not after 500", http.MethodPost, []int{500}, "", 1, true},
+		{"client errors", http.MethodGet, []int{404}, "", 1, true},
+		{"retry after too long", http.MethodGet, []int{429}, "60", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(attempts.Add(1))
+				if n <= len(tt.statuses) {
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(tt.statuses[n-1])
+					return
+				}
+				io.WriteString(w, `{"status": "success"}`)
+			}))
+			defer server.Close()
+
+			c := New(server.URL)
+			c.RetryDelay = time.Millisecond
+			var err error
+			if tt.method == http.MethodGet {
+				_, err = c.WinnerCount(context.Background())
+			} else {
+				_, err = c.DrawPrize(context.Background(), DrawPrizeRequest{})
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got := int(attempts.Load()); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	var attempts atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := New(server.URL)
+	c.HTTPClient.Timeout = 20 * time.Millisecond
+	c.RetryDelay = time.Millisecond
+
+	// A POST that timed out may have been applied, so it is not sent again; a GET is
+	if _, err := c.Evaluate(context.Background(), EvaluateAnswersRequest{}); err == nil {
+		t.Error("Evaluate: no error after a timeout")
+	}
+	if got := attempts.Swap(0); got != 1 {
+		t.Errorf("POST attempts = %d, want 1", got)
+	}
+	if _, err := c.GetQuestion(context.Background(), "CRD0001", "", ""); err == nil {
+		t.Error("GetQuestion: no error after timeouts")
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("GET attempts = %d, want 3", got)
+	}
+
+	// The caller's deadline stops the retries
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c.HTTPClient.Timeout = 0
+	start := time.Now()
+	if _, err := c.WinnerCount(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"delfos/api"
+	"delfos/prizes"
+	"delfos/questions"
+	"delfos/scoring"
+	"delfos/sessions"
+)
+
+// The request and response types of the server, shared with it through the api and domain packages;
+// TestTypesMatchOpenAPI checks them against their schemas in docs/openapi.json
+
+type (
+	// User represents a player session
+	User = sessions.User
+	// CreateUserRequest represents the request body for user creation
+	CreateUserRequest = api.CreateUserRequest
+	// CreateUserResponse represents the response for user creation
+	CreateUserResponse = api.CreateUserResponse
+	// ChooseQuestionsResponse represents the question numbers drawn for a profile
+	ChooseQuestionsResponse = api.ChooseQuestionsResponse
+	// Question represents a multiple choice question; options are answered with the letters a, b, c, ...
+	Question = questions.Question
+	// SubmitAnswerRequest represents one answer of a session
+	SubmitAnswerRequest = api.SubmitAnswerRequest
+	// SubmitAnswerResponse represents the answer a session holds for a question
+	SubmitAnswerResponse = api.SubmitAnswerResponse
+	// EvaluateSessionRequest represents the request body for scoring the answers a session holds
+	EvaluateSessionRequest = api.EvaluateSessionRequest
+	// ResumeSessionRequest represents the request body for resuming a session by resume code or session token
+	ResumeSessionRequest = api.ResumeSessionRequest
+	// ResumeSessionResponse represents the issued questions, recorded answers and time left of a resumed session
+	ResumeSessionResponse = api.ResumeSessionResponse
+	// EvaluateAnswersRequest represents the request body for answer evaluation
+	EvaluateAnswersRequest = api.EvaluateAnswersRequest
+	// AnswerEvaluationResult represents the result for a single question evaluation
+	AnswerEvaluationResult = scoring.AnswerEvaluationResult
+	// EvaluateAnswersResponse represents the response for answer evaluation, with the embedded Score
+	EvaluateAnswersResponse = api.EvaluateAnswersResponse
+	// WinnerCountResponse represents the winner count of an event
+	WinnerCountResponse = api.WinnerCountResponse
+	// DrawPrizeRequest represents the request body for drawing a prize
+	DrawPrizeRequest = api.DrawPrizeRequest
+	// Prize represents an entry of an event's prize table
+	Prize = prizes.Prize
+	// DrawPrizeResponse represents the response for a prize draw
+	DrawPrizeResponse = api.DrawPrizeResponse
+)
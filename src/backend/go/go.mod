@@ -0,0 +1,3 @@
+module delfos
+
+go 1.24
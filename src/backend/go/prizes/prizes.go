@@ -0,0 +1,172 @@
+// Package prizes draws prizes from an event's prize table and records the awards
+//
+// Awards are stored one per line in the prizes.txt file of the event's data directory as
+// awardedAt|sku|userEmail|sessionId, and prizes handed over at the booth in redemptions.txt as
+// redeemedAt|sku|userEmail|sessionId|redeemedBy
+package prizes
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"delfos/storage"
+)
+
+// Prize represents an entry of an event's prize table
+type Prize struct {
+	SKU    string  `json:"sku"`
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"` // Relative draw probability
+	Stock  int     `json:"stock"`  // Units available for the event, -1 for unlimited (e.g. "honor only")
+}
+
+// Physical reports whether the prize is a counted item; physical prizes count as winners
+func (p Prize) Physical() bool {
+	return p.Stock >= 0
+}
+
+// Award represents a prize recorded for a session
+type Award struct {
+	AwardedAt string
+	SKU       string
+	UserEmail string
+	SessionID string
+}
+
+// Redemption represents an awarded prize handed over to the player by the staff
+type Redemption struct {
+	RedeemedAt string
+	SKU        string
+	UserEmail  string
+	SessionID  string
+	RedeemedBy string // Operator name
+}
+
+// Find returns the prize table entry for a SKU, or a prize with only the SKU when it is not in the table
+func Find(table []Prize, sku string) (Prize, bool) {
+	for _, prize := range table {
+		if prize.SKU == sku {
+			return prize, true
+		}
+	}
+	return Prize{SKU: sku}, false
+}
+
+// ForSession returns the award of a player's session, if it has one
+// Session IDs are chosen by the kiosks, so two players can share one; the email tells them apart
+func ForSession(awards []Award, userEmail, sessionID string) (Award, bool) {
+	for _, award := range awards {
+		if award.UserEmail == userEmail && award.SessionID == sessionID {
+			return award, true
+		}
+	}
+	return Award{}, false
+}
+
+// Draw picks a prize by weight among the prizes with stock left after the given awards
+// When physicalClosed is set, e.g. at the winner cap or after the prize cutoff, only unlimited
+// prizes take part; it returns false when nothing is left to draw
+func Draw(table []Prize, awards []Award, physicalClosed bool) (Prize, bool) {
+	awarded := map[string]int{}
+	for _, award := range awards {
+		awarded[award.SKU]++
+	}
+
+	var candidates []Prize
+	total := 0.0
+	for _, prize := range table {
+		if prize.Physical() && (physicalClosed || awarded[prize.SKU] >= prize.Stock) {
+			continue
+		}
+		candidates = append(candidates, prize)
+		total += prize.Weight
+	}
+	if len(candidates) == 0 {
+		return Prize{}, false
+	}
+
+	pick := rand.Float64() * total
+	for _, candidate := range candidates {
+		if pick < candidate.Weight {
+			return candidate, true
+		}
+		pick -= candidate.Weight
+	}
+	return candidates[len(candidates)-1], true
+}
+
+// ReadAwards reads the prizes awarded in an event from its prizes file
+func ReadAwards(ctx context.Context, dataDir string) (awards []Award, err error) {
+	defer storage.Trace(ctx, "read_prizes")(&err)
+
+	lines, err := readLines(filepath.Join(dataDir, "prizes.txt"), 4)
+	for _, fields := range lines {
+		awards = append(awards, Award{AwardedAt: fields[0], SKU: fields[1], UserEmail: fields[2], SessionID: fields[3]})
+	}
+	return awards, err
+}
+
+// AppendAward appends a prize award line to the event's prizes file
+func AppendAward(ctx context.Context, dataDir string, award Award) (err error) {
+	defer storage.Trace(ctx, "append_prize")(&err)
+	return appendLine(dataDir, "prizes.txt", award.AwardedAt, award.SKU, award.UserEmail, award.SessionID)
+}
+
+// ReadRedemptions reads the prizes handed over in an event from its redemptions file
+func ReadRedemptions(ctx context.Context, dataDir string) (redemptions []Redemption, err error) {
+	defer storage.Trace(ctx, "read_redemptions")(&err)
+
+	lines, err := readLines(filepath.Join(dataDir, "redemptions.txt"), 5)
+	for _, fields := range lines {
+		redemptions = append(redemptions, Redemption{RedeemedAt: fields[0], SKU: fields[1], UserEmail: fields[2], SessionID: fields[3], RedeemedBy: fields[4]})
+	}
+	return redemptions, err
+}
+
+// AppendRedemption appends a redemption line to the event's redemptions file
+func AppendRedemption(ctx context.Context, dataDir string, redemption Redemption) (err error) {
+	defer storage.Trace(ctx, "append_redemption")(&err)
+	return appendLine(dataDir, "redemptions.txt", redemption.RedeemedAt, redemption.SKU, redemption.UserEmail, redemption.SessionID, redemption.RedeemedBy)
+}
+
+// readLines returns the "|" separated fields of the lines of a file that have n fields
+// A missing file has no lines
+func readLines(path string, n int) ([][]string, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+	}
+
+	var lines [][]string
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Split(strings.TrimSpace(line), "|"); len(fields) == n {
+			lines = append(lines, fields)
+		}
+	}
+	return lines, nil
+}
+
+// appendLine appends the fields joined by "|" as a line of a file in dataDir
+func appendLine(dataDir, name string, fields ...string) error {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dataDir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(strings.Join(fields, "|") + "\n"); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
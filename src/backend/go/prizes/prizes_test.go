@@ -0,0 +1,93 @@
+package prizes
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestDraw(t *testing.T) {
+	table := []Prize{
+		{SKU: "MUG", Weight: 1, Stock: 1},
+		{SKU: "PEN", Weight: 1, Stock: 2},
+		{SKU: "HONOR", Weight: 1, Stock: -1},
+	}
+	tests := []struct {
+		name           string
+		table          []Prize
+		awards         []Award
+		physicalClosed bool
+		want           []string // Possible SKUs, none when nothing is left
+	}{
+		{"all in stock", table, nil, false, []string{"MUG", "PEN", "HONOR"}},
+		{"sold out skipped", table, []Award{{SKU: "MUG"}, {SKU: "PEN"}}, false, []string{"PEN", "HONOR"}},
+		{"physical closed", table, nil, true, []string{"HONOR"}},
+		{"nothing left", table[:1], []Award{{SKU: "MUG"}}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				prize, ok := Draw(tt.table, tt.awards, tt.physicalClosed)
+				if ok != (tt.want != nil) {
+					t.Fatalf("Draw ok = %v, want %v", ok, tt.want != nil)
+				}
+				if ok && !slices.Contains(tt.want, prize.SKU) {
+					t.Fatalf("Draw = %s, want one of %v", prize.SKU, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAwardsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	awards, err := ReadAwards(ctx, dir)
+	if err != nil || awards != nil {
+		t.Fatalf("ReadAwards on an empty event = %v, %v", awards, err)
+	}
+	want := []Award{
+		{AwardedAt: "2026-05-01T10:00:00Z", SKU: "MUG", UserEmail: "a@example.com", SessionID: "s-1"},
+		{AwardedAt: "2026-05-01T10:05:00Z", SKU: "HONOR", UserEmail: "b@example.com", SessionID: "s-2"},
+	}
+	for _, award := range want {
+		if err := AppendAward(ctx, dir, award); err != nil {
+			t.Fatalf("AppendAward: %v", err)
+		}
+	}
+
+	awards, err = ReadAwards(ctx, dir)
+	if err != nil {
+		t.Fatalf("ReadAwards: %v", err)
+	}
+	if len(awards) != len(want) || awards[0] != want[0] || awards[1] != want[1] {
+		t.Fatalf("ReadAwards = %+v, want %+v", awards, want)
+	}
+	if award, ok := ForSession(awards, "b@example.com", "s-2"); !ok || award.SKU != "HONOR" {
+		t.Errorf("ForSession(b@example.com, s-2) = %+v, %v", award, ok)
+	}
+	if _, ok := ForSession(awards, "b@example.com", "s-3"); ok {
+		t.Error("ForSession found an award for a session without one")
+	}
+	if _, ok := ForSession(awards, "a@example.com", "s-2"); ok {
+		t.Error("ForSession found another player's award for the same session ID")
+	}
+}
+
+func TestRedemptionsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	want := Redemption{RedeemedAt: "2026-05-01T10:10:00Z", SKU: "MUG", UserEmail: "a@example.com", SessionID: "s-1", RedeemedBy: "ana"}
+	if err := AppendRedemption(ctx, dir, want); err != nil {
+		t.Fatalf("AppendRedemption: %v", err)
+	}
+	redemptions, err := ReadRedemptions(ctx, dir)
+	if err != nil || len(redemptions) != 1 || redemptions[0] != want {
+		t.Fatalf("ReadRedemptions = %+v, %v, want %+v", redemptions, err, want)
+	}
+	if awards, err := ReadAwards(ctx, dir); err != nil || awards != nil {
+		t.Errorf("ReadAwards = %+v, %v, want redemptions kept apart from awards", awards, err)
+	}
+}
@@ -0,0 +1,109 @@
+package questions
+
+// The question bank: 16 questions per profile, answered with the letter of the correct option
+
+var bank = []Question{
+	{ID: "CRD0001", Question: "¿Cuál es el mayor monto total desembolsado en la historia de créditos de Nequi?", Options: []string{"220.317.663.560", "220.560.317.663", "202.317.663.560", "222.317.663.560"}},
+	{ID: "CRD0002", Question: "¿Cuál es el total de monto desembolsado en la historia de Nequi?", Options: []string{"212.445.004.347", "3.654.343.244.567", "2.112.445.004.347", "2.211.544.400.743"}},
+	{ID: "CRD0003", Question: "¿Cuántos Nequis con ocupación registrada han tenido un crédito siendo estudiantes?", Options: []string{"234.245", "114.859", "34.567", "7.535"}},
+	{ID: "CRD0004", Question: "¿Qué segmento ha tenido 58.627 desembolsos en la historia?", Options: []string{"Camellador", "Emprendedor", "Jugador", "Dinamizador"}},
+	{ID: "CRD0005", Question: "¿Cuál es el monto promedio desembolsado en Préstamo Propulsor por parte de los Nequis?", Options: []string{"1.524.869", "2.412.512", "3.412.512", "4.356.234"}},
+	{ID: "CRD0006", Question: "¿Cuántas personas de 50 años han tenido desembolsos?", Options: []string{"11.913", "12.456", "33.235", "12.546"}},
+	{ID: "CRD0007", Question: "¿Cuánto se espera desembolsar en montos para diciembre de 2025 según previsión?", Options: []string{"301.232.961.021", "247.934.545.098", "295.958.450.136", "247.897.010.598"}},
+	{ID: "CRD0008", Question: "¿Cuántas personas con ciudad de nacimiento BARRANQUILLA ATLÁNTICO han tenido un desembolso?", Options: []string{"26.861", "21.346", "38.671", "15.981"}},
+	{ID: "CRD0009", Question: "¿Cuál es el total de créditos cancelados del tipo Bajo Monto?", Options: []string{"10.495", "10.456", "10.053", "10.563"}},
+	{ID: "CRD0010", Question: "¿Cuál fue la variación porcentual en el valor desembolsado entre abril y marzo 2025?", Options: []string{"56.71%", "57.61%", "55.67%", "57.56%"}},
+	{ID: "CRD0011", Question: "¿Cuál es el promedio de variación porcentual de desembolsos en todo el año 2025?", Options: []string{"7.16%", "2.53%", "1.78%", "5.16%"}},
+	{ID: "CRD0012", Question: "¿Cuántos clientes han tenido un desembolso con Nequi?", Options: []string{"1.980.596", "1.098.596", "1.089.686", "1.809.586"}},
+	{ID: "CRD0013", Question: "¿Qué edad ha tenido la mayor cantidad de personas con desembolsos?", Options: []string{"53", "24", "34", "29"}},
+	{ID: "CRD0014", Question: "¿Cuánto ha sido el total desembolsado por el segmento Camellador en Préstamo Propulsor?", Options: []string{"786.334.794.036", "567.334.794.036", "978.334.794.036", "876.334.794.036"}},
+	{ID: "CRD0015", Question: "¿Cuántos créditos han sido suspendidos para el segmento Dinamizador?", Options: []string{"7.456", "10.234", "6.345", "9.821"}},
+	{ID: "CRD0016", Question: "¿Cuál ha sido el total histórico de desembolsos del segmento Joven?", Options: []string{"45.807", "54.678", "23.567", "38.910"}},
+
+	{ID: "SRV0001", Question: "¿Qué peso tienen todos los tickets de la categoría “Envíos” dentro del total?", Options: []string{"6%", "8%", "9%", "7%"}},
+	{ID: "SRV0002", Question: "¿En qué mes explotó la categoría PSE con la mayor cantidad de tickets?", Options: []string{"Abril 2024", "Mayo 2025", "Junio 2024", "Marzo 2025"}},
+	{ID: "SRV0003", Question: "¿Cuántos tickets por llamada hemos tenido en toda la historia?", Options: []string{"4.159.226", "4.129.345", "4.169.226", "4.915.622"}},
+	{ID: "SRV0004", Question: "¿Cuál ha sido el promedio de tickets por día entre enero y junio 2025?", Options: []string{"30.727", "36.075", "40.856", "48.745"}},
+	{ID: "SRV0005", Question: "¿En abril de 2025, cuántos tickets diarios en promedio entraron por el canal de CHAT?", Options: []string{"24.267", "26.129", "22.803", "23.556"}},
+	{ID: "SRV0006", Question: "¿Cuál es la mediana del tiempo total de contacto (en minutos)?", Options: []string{"30", "24", "40", "45"}},
+	{ID: "SRV0007", Question: "¿Cuánto es la mediana del tiempo hablando con un agente en llamada?", Options: []string{"8", "10", "9", "12"}},
+	{ID: "SRV0008", Question: "¿Cuál es la proporción histórica de tickets tipo incidente?", Options: []string{"47%", "59%", "69%", "57%"}},
+	{ID: "SRV0009", Question: "¿Tuvimos tickets abiertos en abril 2025? ¿Cuántos fueron?", Options: []string{"34", "28", "45", "38"}},
+	{ID: "SRV0010", Question: "¿Cuánto se espera que entren de tickets en diciembre 2025, según el pronóstico?", Options: []string{"2.212.212,51", "2.212.026,51", "2.245.026,53", "2.212.620,51"}},
+	{ID: "SRV0011", Question: "¿Cuál fue el mes más intenso en toda la historia por cantidad de tickets?", Options: []string{"1.422.837", "1.337.585", "1.413.595", "1.320.121"}},
+	{ID: "SRV0012", Question: "¿Cuántos tickets en total se han registrado por la razón: 01_transacciones_fraude?", Options: []string{"814.230", "789.045", "630.389", "714.846"}},
+	{ID: "SRV0013", Question: "¿Cuál fue la proporción de tickets de tipo aclaración en junio 2025?", Options: []string{"36%", "45%", "34%", "47%"}},
+	{ID: "SRV0014", Question: "¿Cuántos tickets totales se han registrado en la categoría onboarding?", Options: []string{"4.246", "5.645", "4.365", "5.945"}},
+	{ID: "SRV0015", Question: "En diciembre 2024, ¿cuántos tickets tuvimos por el tipo de incidente evento_masivo?", Options: []string{"12.567", "13.650", "12.456", "13.434"}},
+	{ID: "SRV0016", Question: "¿Cuál fue el promedio diario de contactos por APP en marzo 2025?", Options: []string{"9.579", "9.208", "9.8971", "10.623"}},
+
+	{ID: "EXP0001", Question: "¿Cuántas transacciones se registraron en total durante abril de 2025?", Options: []string{"3.224", "5.621", "7.456", "4.879"}},
+	{ID: "EXP0002", Question: "¿Cuál ha sido el total histórico de transacciones ACH salida registradas?", Options: []string{"2.089", "1.183", "1.399", "2.145"}},
+	{ID: "EXP0003", Question: "¿Qué proporción representan las transacciones entre Nequis dentro del total de conceptos?", Options: []string{"24%", "38%", "40%", "35%"}},
+	{ID: "EXP0004", Question: "¿Cuántos usuarios únicos realizaron transacciones durante mayo de 2025?", Options: []string{"482", "578", "688", "458"}},
+	{ID: "EXP0005", Question: "¿Cuál fue el monto total transado en recargas en comercio durante junio de 2025 (en quetzales)?", Options: []string{"356.934", "456.934", "389.919", "387.129"}},
+	{ID: "EXP0006", Question: "En diciembre de 2025, ¿qué concepto tuvo el mayor monto total transado?", Options: []string{"Recarga en comercio", "Transferencia Nequi a BAM", "Fondos desde BAM", "Fondos desde Bancolombia"}},
+	{ID: "EXP0007", Question: "¿Cuántas transferencias totales se han hecho por concepto de cierre de cuentas?", Options: []string{"3", "2", "7", "4"}},
+	{ID: "EXP0008", Question: "¿Cuál es el promedio de montos en transferencias por concepto \"Fondos desde BAM\"?", Options: []string{"354", "411", "600", "354"}},
+	{ID: "EXP0009", Question: "¿Cuántas cuentas fueron aperturadas durante marzo de 2025?", Options: []string{"1.304", "1.845", "1.789", "1.403"}},
+	{ID: "EXP0010", Question: "Según la previsión, ¿cuántas aperturas de cuentas se esperan para diciembre de 2025?", Options: []string{"7.799", "4.678", "7.679", "8.861"}},
+	{ID: "EXP0011", Question: "¿Cuántas cuentas han sido canceladas en total en toda la historia?", Options: []string{"657", "535", "387", "678"}},
+	{ID: "EXP0012", Question: "¿Cuántos clientes con 35 años tienen cuentas activas actualmente?", Options: []string{"342", "543", "287", "567"}},
+	{ID: "EXP0013", Question: "¿Cuál es el segundo departamento con más clientes con cuentas activas?", Options: []string{"Escuintla", "Quetzaltenango", "Sacatepequez", "Chimaltenango"}},
+	{ID: "EXP0014", Question: "¿Cuál es el total de saldo en Chubales para las cuentas creadas entre el 1 de abril y el 30 de junio de 2025?", Options: []string{"3.450", "5.755", "3.234", "2.324"}},
+	{ID: "EXP0015", Question: "¿Cuál es el saldo total actual de todas las cuentas Nequi Guatemala?", Options: []string{"456.340", "760.450", "673.070", "560.912"}},
+	{ID: "EXP0016", Question: "¿Cuál fue el promedio de montos enviados entre cuentas Nequi durante mayo de 2025?", Options: []string{"56", "44", "96", "85"}},
+}
+
+var answerKey = []Answer{
+	{QuestionID: "CRD0001", Answer: "a", Description: "220.317.663.560"},
+	{QuestionID: "CRD0002", Answer: "c", Description: "2.112.445.004.347"},
+	{QuestionID: "CRD0003", Answer: "b", Description: "114.859"},
+	{QuestionID: "CRD0004", Answer: "b", Description: "Emprendedor"},
+	{QuestionID: "CRD0005", Answer: "b", Description: "2.412.512"},
+	{QuestionID: "CRD0006", Answer: "a", Description: "11.913"},
+	{QuestionID: "CRD0007", Answer: "d", Description: "247.897.010.598"},
+	{QuestionID: "CRD0008", Answer: "b", Description: "21.346"},
+	{QuestionID: "CRD0009", Answer: "c", Description: "10.053"},
+	{QuestionID: "CRD0010", Answer: "a", Description: "56.71%"},
+	{QuestionID: "CRD0011", Answer: "c", Description: "1.78%"},
+	{QuestionID: "CRD0012", Answer: "b", Description: "1.098.596"},
+	{QuestionID: "CRD0013", Answer: "b", Description: "24"},
+	{QuestionID: "CRD0014", Answer: "d", Description: "876.334.794.036"},
+	{QuestionID: "CRD0015", Answer: "d", Description: "9.821"},
+	{QuestionID: "CRD0016", Answer: "a", Description: "45.807"},
+
+	{QuestionID: "SRV0001", Answer: "b", Description: "8%"},
+	{QuestionID: "SRV0002", Answer: "b", Description: "Mayo 2025"},
+	{QuestionID: "SRV0003", Answer: "a", Description: "4.159.226"},
+	{QuestionID: "SRV0004", Answer: "c", Description: "40.856"},
+	{QuestionID: "SRV0005", Answer: "d", Description: "23.556"},
+	{QuestionID: "SRV0006", Answer: "c", Description: "40"},
+	{QuestionID: "SRV0007", Answer: "a", Description: "8"},
+	{QuestionID: "SRV0008", Answer: "b", Description: "59%"},
+	{QuestionID: "SRV0009", Answer: "b", Description: "28"},
+	{QuestionID: "SRV0010", Answer: "b", Description: "2.212.026,51"},
+	{QuestionID: "SRV0011", Answer: "a", Description: "1.422.837"},
+	{QuestionID: "SRV0012", Answer: "d", Description: "714.846"},
+	{QuestionID: "SRV0013", Answer: "a", Description: "36%"},
+	{QuestionID: "SRV0014", Answer: "a", Description: "4.246"},
+	{QuestionID: "SRV0015", Answer: "d", Description: "13.434"},
+	{QuestionID: "SRV0016", Answer: "b", Description: "9.208"},
+
+	{QuestionID: "EXP0001", Answer: "b", Description: "5.621"},
+	{QuestionID: "EXP0002", Answer: "a", Description: "2.089"},
+	{QuestionID: "EXP0003", Answer: "d", Description: "35%"},
+	{QuestionID: "EXP0004", Answer: "a", Description: "482"},
+	{QuestionID: "EXP0005", Answer: "c", Description: "389.919"},
+	{QuestionID: "EXP0006", Answer: "c", Description: "Fondos desde BAM"},
+	{QuestionID: "EXP0007", Answer: "b", Description: "2"},
+	{QuestionID: "EXP0008", Answer: "b", Description: "411"},
+	{QuestionID: "EXP0009", Answer: "a", Description: "1.304"},
+	{QuestionID: "EXP0010", Answer: "d", Description: "8.861"},
+	{QuestionID: "EXP0011", Answer: "b", Description: "535"},
+	{QuestionID: "EXP0012", Answer: "a", Description: "342"},
+	{QuestionID: "EXP0013", Answer: "a", Description: "Escuintla"},
+	{QuestionID: "EXP0014", Answer: "d", Description: "2.324"},
+	{QuestionID: "EXP0015", Answer: "c", Description: "673.070"},
+	{QuestionID: "EXP0016", Answer: "c", Description: "96"},
+}
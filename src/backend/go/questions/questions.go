@@ -0,0 +1,178 @@
+// Package questions is the quiz question bank
+//
+// Questions belong to a profile ("1" Créditos, "2" Servicio, "3" Expansión) and their IDs are the
+// profile prefix and a number, e.g. CRD0007; options are answered with the letters a, b, c, ...
+// in bank order, or in the display order a session was issued (see Shuffle)
+package questions
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Question is a multiple choice question
+type Question struct {
+	ID       string   `json:"id"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"` // Required field for multiple choice questions
+}
+
+// Answer is the correct option of a question
+type Answer struct {
+	QuestionID  string `json:"question_id"`
+	Answer      string `json:"answer"`
+	Description string `json:"description,omitempty"` // Optional field for additional context
+}
+
+// Prefix returns the question ID prefix for a profile ("1" CRD, "2" SRV, "3" EXP)
+// Unknown profiles use the CRD questions
+func Prefix(profile string) string {
+	switch profile {
+	case "2":
+		return "SRV"
+	case "3":
+		return "EXP"
+	default:
+		return "CRD"
+	}
+}
+
+// Profile returns the profile ("1", "2" or "3") a question ID belongs to
+func Profile(questionID string) string {
+	switch {
+	case strings.HasPrefix(questionID, "SRV"):
+		return "2"
+	case strings.HasPrefix(questionID, "EXP"):
+		return "3"
+	default:
+		return "1"
+	}
+}
+
+// FormatID builds a full question ID like CRD0007 from a profile and number
+func FormatID(profile string, number int) string {
+	return fmt.Sprintf("%s%04d", Prefix(profile), number)
+}
+
+// ParseID splits a full question ID like CRD0007 into its profile and number
+func ParseID(id string) (profile string, number int, ok bool) {
+	if len(id) != 7 {
+		return "", 0, false
+	}
+	profile = Profile(id)
+	if id[:3] != Prefix(profile) {
+		return "", 0, false
+	}
+	number, err := strconv.Atoi(id[3:])
+	if err != nil || number < 1 {
+		return "", 0, false
+	}
+	return profile, number, true
+}
+
+// Find looks up a question by its full ID
+func Find(id string) (Question, bool) {
+	for _, q := range bank {
+		if q.ID == id {
+			return q, true
+		}
+	}
+	return Question{}, false
+}
+
+// FindAnswer looks up the answer of a question by its full ID
+func FindAnswer(questionID string) (Answer, bool) {
+	for _, a := range answerKey {
+		if a.QuestionID == questionID {
+			return a, true
+		}
+	}
+	return Answer{}, false
+}
+
+// Count returns the number of questions of a profile; they are numbered from 1
+func Count(profile string) int {
+	prefix := Prefix(profile)
+	count := 0
+	for _, q := range bank {
+		if strings.HasPrefix(q.ID, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// Draw picks count unique random question numbers of a profile, or every number when the profile has fewer
+func Draw(profile string, count int) []int {
+	available := Count(profile)
+	count = min(count, available)
+
+	numbers := make([]int, 0, count)
+	for _, i := range rand.Perm(available)[:count] {
+		numbers = append(numbers, i+1)
+	}
+	return numbers
+}
+
+// Shuffle returns a random display order for n options: the bank letters in the order they are
+// shown, so "cadb" shows option c under the letter a
+func Shuffle(n int) string {
+	order := make([]byte, n)
+	for i, j := range rand.Perm(n) {
+		order[i] = byte('a' + j)
+	}
+	return string(order)
+}
+
+// Reorder returns the question with its options in a display order
+// An order that is not a permutation of the question's option letters keeps the bank order
+func Reorder(q Question, order string) Question {
+	if !validOrder(order, len(q.Options)) {
+		return q
+	}
+	options := make([]string, len(order))
+	for i := range len(order) {
+		options[i] = q.Options[order[i]-'a']
+	}
+	q.Options = options
+	return q
+}
+
+// CanonicalLetter maps an option letter shown in a display order to its letter in the bank
+// Case and spaces are ignored; letters outside the order are returned unchanged
+func CanonicalLetter(order, displayed string) string {
+	letter := strings.ToLower(strings.TrimSpace(displayed))
+	if len(letter) != 1 || letter[0] < 'a' || int(letter[0]-'a') >= len(order) {
+		return displayed
+	}
+	return order[letter[0]-'a' : letter[0]-'a'+1]
+}
+
+// DisplayedLetter maps an option letter of the bank to the letter it is shown under in a display order
+// Letters outside the order are returned unchanged
+func DisplayedLetter(order, canonical string) string {
+	letter := strings.ToLower(strings.TrimSpace(canonical))
+	i := strings.Index(order, letter)
+	if len(letter) != 1 || i < 0 {
+		return canonical
+	}
+	return string(rune('a' + i))
+}
+
+// validOrder reports whether order holds each of the first n option letters exactly once
+func validOrder(order string, n int) bool {
+	if len(order) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for i := range len(order) {
+		j := int(order[i]) - 'a'
+		if j < 0 || j >= n || seen[j] {
+			return false
+		}
+		seen[j] = true
+	}
+	return true
+}
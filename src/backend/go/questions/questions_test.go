@@ -0,0 +1,97 @@
+package questions
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBankIsConsistent(t *testing.T) {
+	for _, profile := range []string{"1", "2", "3"} {
+		count := Count(profile)
+		if count == 0 {
+			t.Fatalf("profile %s has no questions", profile)
+		}
+		for number := 1; number <= count; number++ {
+			id := FormatID(profile, number)
+			q, ok := Find(id)
+			if !ok {
+				t.Errorf("%s is missing, questions are numbered from 1", id)
+				continue
+			}
+			if Profile(q.ID) != profile {
+				t.Errorf("Profile(%s) = %s, want %s", q.ID, Profile(q.ID), profile)
+			}
+			if len(q.Options) < 2 {
+				t.Errorf("%s has %d options", id, len(q.Options))
+			}
+			answer, ok := FindAnswer(id)
+			if !ok {
+				t.Errorf("%s has no answer", id)
+				continue
+			}
+			if len(answer.Answer) != 1 || int(answer.Answer[0]-'a') >= len(q.Options) {
+				t.Errorf("%s answer %q is not one of its %d options", id, answer.Answer, len(q.Options))
+			}
+		}
+	}
+}
+
+func TestDraw(t *testing.T) {
+	available := Count("2")
+	tests := []struct {
+		name  string
+		count int
+		want  int
+	}{
+		{"fewer than available", 3, 3},
+		{"all", available, available},
+		{"more than available", available + 5, available},
+		{"none", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			numbers := Draw("2", tt.count)
+			if len(numbers) != tt.want {
+				t.Fatalf("Draw returned %d numbers, want %d", len(numbers), tt.want)
+			}
+			seen := map[int]bool{}
+			for _, number := range numbers {
+				if number < 1 || number > available || seen[number] {
+					t.Errorf("Draw returned %v, want unique numbers in 1..%d", numbers, available)
+				}
+				seen[number] = true
+			}
+		})
+	}
+}
+
+func TestShuffle(t *testing.T) {
+	q, _ := Find("CRD0001")
+	for range 20 {
+		order := Shuffle(len(q.Options))
+		if !validOrder(order, len(q.Options)) {
+			t.Fatalf("Shuffle(%d) = %q, want a permutation of the option letters", len(q.Options), order)
+		}
+		shown := Reorder(q, order)
+		for i, option := range shown.Options {
+			displayed := string(rune('a' + i))
+			canonical := CanonicalLetter(order, displayed)
+			if q.Options[canonical[0]-'a'] != option {
+				t.Errorf("order %q: %s maps to %s, which is not the option shown", order, displayed, canonical)
+			}
+			if got := DisplayedLetter(order, canonical); got != displayed {
+				t.Errorf("order %q: DisplayedLetter(%s) = %s, want %s", order, canonical, got, displayed)
+			}
+		}
+	}
+
+	if got := Reorder(q, "aab"); !slices.Equal(got.Options, q.Options) {
+		t.Errorf("Reorder with an invalid order = %v, want the bank order", got.Options)
+	}
+	if got := CanonicalLetter("cadb", " B "); got != "a" {
+		t.Errorf("CanonicalLetter ignores case and spaces: got %q, want a", got)
+	}
+	if got := CanonicalLetter("cadb", "z"); got != "z" {
+		t.Errorf("CanonicalLetter outside the order = %q, want it unchanged", got)
+	}
+}
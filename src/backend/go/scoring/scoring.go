@@ -0,0 +1,241 @@
+// Package scoring grades quiz answers against the answer key of the question bank
+package scoring
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"delfos/questions"
+)
+
+// AnswerEvaluationResult represents the result for a single question evaluation
+// UserAnswer and CorrectAnswer are bank letters; the displayed letters are only set when the
+// question's options were shown shuffled
+type AnswerEvaluationResult struct {
+	QuestionID             string  `json:"questionId"`
+	UserAnswer             string  `json:"userAnswer"`
+	CorrectAnswer          string  `json:"correctAnswer"`
+	DisplayedAnswer        string  `json:"displayedAnswer,omitempty"`        // Letter the player picked, as the options were shown
+	DisplayedCorrectAnswer string  `json:"displayedCorrectAnswer,omitempty"` // Letter the correct option was shown under
+	IsCorrect              bool    `json:"isCorrect"`
+	Description            string  `json:"description,omitempty"`
+	ResponseTimeMs         int64   `json:"responseTimeMs,omitempty"` // From serving the question to the answer, when both were recorded
+	TimeBonus              float64 `json:"timeBonus,omitempty"`      // Points earned for answering correctly fast
+}
+
+// Score is the graded quiz, embedded in the evaluation response
+type Score struct {
+	TotalQuestions      int                      `json:"totalQuestions"`
+	CorrectAnswers      int                      `json:"correctAnswers"`
+	IncorrectAnswers    int                      `json:"incorrectAnswers"`
+	ScorePercentage     float64                  `json:"scorePercentage"`
+	TimeBonus           float64                  `json:"timeBonus,omitempty"`           // Speed points on top of the percentage, which alone decides passing
+	TotalResponseTimeMs int64                    `json:"totalResponseTimeMs,omitempty"` // Sum of the response times when every question has one; lower breaks ties
+	Results             []AnswerEvaluationResult `json:"results"`
+}
+
+// Passed reports whether the score reaches the pass score, a percentage
+func (s Score) Passed(passScore float64) bool {
+	return s.ScorePercentage >= passScore
+}
+
+// Timing is when a question was served to the player and when they answered it
+type Timing struct {
+	ServedAt   time.Time
+	AnsweredAt time.Time
+}
+
+// ResponseTime returns the time the player took to answer, false when either time is unknown
+func (t Timing) ResponseTime() (time.Duration, bool) {
+	if t.ServedAt.IsZero() || t.AnsweredAt.IsZero() || t.AnsweredAt.Before(t.ServedAt) {
+		return 0, false
+	}
+	return t.AnsweredAt.Sub(t.ServedAt), true
+}
+
+// TimeBonus awards points to correct answers given fast: Points for an instant answer, falling
+// linearly to none at Window; zero Points disables it
+type TimeBonus struct {
+	Points float64
+	Window time.Duration
+}
+
+// AddTimings sets the response time of each question timed by timings, keyed by question ID, and
+// awards the time bonus to the correct ones; questions without a timing earn no bonus
+func (s *Score) AddTimings(timings map[string]Timing, bonus TimeBonus) {
+	var total int64
+	timed := 0
+	for i := range s.Results {
+		result := &s.Results[i]
+		elapsed, ok := timings[result.QuestionID].ResponseTime()
+		if !ok {
+			continue
+		}
+		timed++
+		result.ResponseTimeMs = elapsed.Milliseconds()
+		total += result.ResponseTimeMs
+		if result.IsCorrect && bonus.Points > 0 && elapsed < bonus.Window {
+			result.TimeBonus = roundPoints(bonus.Points * float64(bonus.Window-elapsed) / float64(bonus.Window))
+			s.TimeBonus += result.TimeBonus
+		}
+	}
+	s.TimeBonus = roundPoints(s.TimeBonus)
+
+	// A partial total would favour players with untimed questions
+	if timed == len(s.Results) {
+		s.TotalResponseTimeMs = total
+	}
+}
+
+// roundPoints rounds bonus points to two decimals, as results.txt stores them
+func roundPoints(points float64) float64 {
+	return math.Round(points*100) / 100
+}
+
+// ValidationError lists the indices of a submission that break a rule; empty lists are omitted
+// Indices point into questionIds, except ExtraAnswers which points into userAnswers
+type ValidationError struct {
+	QuestionCount       int   `json:"questionCount"`
+	AnswerCount         int   `json:"answerCount"`
+	UnansweredQuestions []int `json:"unansweredQuestions,omitempty"` // Questions past the end of userAnswers
+	ExtraAnswers        []int `json:"extraAnswers,omitempty"`        // Answers past the end of questionIds
+	UnknownQuestions    []int `json:"unknownQuestions,omitempty"`    // IDs that are not in the question bank
+	DuplicateQuestions  []int `json:"duplicateQuestions,omitempty"`  // Repeats of an ID listed earlier
+	InvalidAnswers      []int `json:"invalidAnswers,omitempty"`      // Answers that are not an option letter of their question
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	if e.QuestionCount != e.AnswerCount {
+		problems = append(problems, fmt.Sprintf("%d answers for %d questions", e.AnswerCount, e.QuestionCount))
+	}
+	if len(e.UnknownQuestions) > 0 {
+		problems = append(problems, fmt.Sprintf("unknown questions at %v", e.UnknownQuestions))
+	}
+	if len(e.DuplicateQuestions) > 0 {
+		problems = append(problems, fmt.Sprintf("duplicate questions at %v", e.DuplicateQuestions))
+	}
+	if len(e.InvalidAnswers) > 0 {
+		problems = append(problems, fmt.Sprintf("invalid answers at %v", e.InvalidAnswers))
+	}
+	return "invalid submission: " + strings.Join(problems, "; ")
+}
+
+// Validate checks a submission before it is scored: one answer per question, every question in the
+// bank and listed once, every answer an option letter of its question (case and spaces ignored)
+// It returns nil or a *ValidationError
+func Validate(questionIDs []string, userAnswers []string) error {
+	e := &ValidationError{QuestionCount: len(questionIDs), AnswerCount: len(userAnswers)}
+	for i := len(userAnswers); i < len(questionIDs); i++ {
+		e.UnansweredQuestions = append(e.UnansweredQuestions, i)
+	}
+	for i := len(questionIDs); i < len(userAnswers); i++ {
+		e.ExtraAnswers = append(e.ExtraAnswers, i)
+	}
+
+	seen := make(map[string]bool, len(questionIDs))
+	for i, questionID := range questionIDs {
+		if seen[questionID] {
+			e.DuplicateQuestions = append(e.DuplicateQuestions, i)
+		}
+		seen[questionID] = true
+
+		question, ok := questions.Find(questionID)
+		if !ok {
+			e.UnknownQuestions = append(e.UnknownQuestions, i)
+			continue
+		}
+		if i < len(userAnswers) && !isOptionLetter(userAnswers[i], len(question.Options)) {
+			e.InvalidAnswers = append(e.InvalidAnswers, i)
+		}
+	}
+
+	if e.QuestionCount == e.AnswerCount && len(e.UnknownQuestions) == 0 &&
+		len(e.DuplicateQuestions) == 0 && len(e.InvalidAnswers) == 0 {
+		return nil
+	}
+	return e
+}
+
+// isOptionLetter reports whether answer is one of the first options letters, a, b, c, ...
+func isOptionLetter(answer string, options int) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return len(answer) == 1 && answer[0] >= 'a' && int(answer[0]-'a') < options
+}
+
+// Evaluate compares user answers with the answer key and builds the evaluation report
+// Answers are compared case-insensitively; unknown question IDs have no correct answer and a missing
+// answer is wrong, but callers should reject what Validate reports instead of scoring it
+func Evaluate(questionIDs []string, userAnswers []string) Score {
+	// Evaluate answers
+	var results []AnswerEvaluationResult
+	correctCount := 0
+	incorrectCount := 0
+
+	for i, questionID := range questionIDs {
+		var result AnswerEvaluationResult
+		result.QuestionID = questionID
+		if i < len(userAnswers) {
+			result.UserAnswer = userAnswers[i]
+		}
+
+		// Find correct answer
+		if answer, ok := questions.FindAnswer(questionID); ok {
+			result.CorrectAnswer = answer.Answer
+		}
+
+		// Check if answer is correct (case-insensitive)
+		if strings.EqualFold(strings.TrimSpace(result.UserAnswer), strings.TrimSpace(result.CorrectAnswer)) {
+			result.IsCorrect = true
+			correctCount++
+		} else {
+			result.IsCorrect = false
+			incorrectCount++
+		}
+
+		results = append(results, result)
+	}
+
+	// Calculate score percentage
+	var scorePercentage float64
+	if len(questionIDs) > 0 {
+		scorePercentage = (float64(correctCount) / float64(len(questionIDs))) * 100
+	}
+
+	return Score{
+		TotalQuestions:   len(questionIDs),
+		CorrectAnswers:   correctCount,
+		IncorrectAnswers: incorrectCount,
+		ScorePercentage:  scorePercentage,
+		Results:          results,
+	}
+}
+
+// EvaluateDisplayed scores answers given as the letters the player saw
+// orders holds the display order of a question's options by ID (see questions.Shuffle); questions
+// without one were shown in bank order and are scored as Evaluate does
+func EvaluateDisplayed(questionIDs []string, userAnswers []string, orders map[string]string) Score {
+	canonical := make([]string, len(userAnswers))
+	for i, answer := range userAnswers {
+		canonical[i] = answer
+		if i < len(questionIDs) && orders[questionIDs[i]] != "" {
+			canonical[i] = questions.CanonicalLetter(orders[questionIDs[i]], answer)
+		}
+	}
+
+	score := Evaluate(questionIDs, canonical)
+	for i := range score.Results {
+		result := &score.Results[i]
+		order := orders[result.QuestionID]
+		if order == "" {
+			continue
+		}
+		if i < len(userAnswers) {
+			result.DisplayedAnswer = userAnswers[i]
+		}
+		result.DisplayedCorrectAnswer = questions.DisplayedLetter(order, result.CorrectAnswer)
+	}
+	return score
+}
@@ -0,0 +1,98 @@
+package scoring
+
+import (
+	"testing"
+	"time"
+
+	"delfos/questions"
+)
+
+func TestEvaluate(t *testing.T) {
+	answer, _ := questions.FindAnswer("CRD0001")
+	tests := []struct {
+		name        string
+		questionIDs []string
+		answers     []string
+		wantCorrect int
+		wantPercent float64
+	}{
+		{"correct", []string{"CRD0001"}, []string{answer.Answer}, 1, 100},
+		{"missing answer is wrong", []string{"CRD0001", "CRD0002"}, []string{answer.Answer}, 1, 50},
+		{"extra answers ignored", []string{"CRD0001"}, []string{answer.Answer, "a"}, 1, 100},
+		{"no questions", nil, []string{"a"}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := Evaluate(tt.questionIDs, tt.answers)
+			if score.CorrectAnswers != tt.wantCorrect || score.ScorePercentage != tt.wantPercent || len(score.Results) != len(tt.questionIDs) {
+				t.Errorf("score = %+v, want %d correct, %v%%", score, tt.wantCorrect, tt.wantPercent)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]string{"CRD0001", "SRV0002"}, []string{"a", " D "}); err != nil {
+		t.Errorf("valid submission: %v", err)
+	}
+	err := Validate([]string{"CRD0001", "CRD0001"}, []string{"a"})
+	if err == nil || err.Error() != "invalid submission: 1 answers for 2 questions; duplicate questions at [1]" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestEvaluateDisplayed(t *testing.T) {
+	answer, _ := questions.FindAnswer("CRD0001")
+	question, _ := questions.Find("CRD0001")
+	order := questions.Shuffle(len(question.Options))
+	shown := questions.DisplayedLetter(order, answer.Answer)
+	orders := map[string]string{"CRD0001": order}
+
+	score := EvaluateDisplayed([]string{"CRD0001", "CRD0002"}, []string{shown, "a"}, orders)
+	if !score.Results[0].IsCorrect {
+		t.Errorf("answer %s shown in order %q scored wrong: %+v", shown, order, score.Results[0])
+	}
+	want := AnswerEvaluationResult{QuestionID: "CRD0001", UserAnswer: answer.Answer, CorrectAnswer: answer.Answer,
+		DisplayedAnswer: shown, DisplayedCorrectAnswer: shown, IsCorrect: true}
+	if score.Results[0] != want {
+		t.Errorf("result = %+v, want %+v", score.Results[0], want)
+	}
+	if r := score.Results[1]; r.DisplayedAnswer != "" || r.DisplayedCorrectAnswer != "" || r.UserAnswer != "a" {
+		t.Errorf("question without an order = %+v, want bank letters only", r)
+	}
+}
+
+func TestAddTimings(t *testing.T) {
+	ids := []string{"CRD0001", "CRD0002"}
+	answer, _ := questions.FindAnswer("CRD0002")
+	served := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	timings := map[string]Timing{
+		"CRD0001": {ServedAt: served, AnsweredAt: served.Add(5 * time.Second)},
+		"CRD0002": {ServedAt: served, AnsweredAt: served.Add(2500 * time.Millisecond)},
+	}
+	bonus := TimeBonus{Points: 10, Window: 10 * time.Second}
+
+	score := Evaluate(ids, []string{"z", answer.Answer})
+	score.AddTimings(timings, bonus)
+	if got := []int64{score.Results[0].ResponseTimeMs, score.Results[1].ResponseTimeMs}; got[0] != 5000 || got[1] != 2500 {
+		t.Errorf("response times = %v, want [5000 2500]", got)
+	}
+	if score.Results[0].TimeBonus != 0 || score.Results[1].TimeBonus != 7.5 || score.TimeBonus != 7.5 {
+		t.Errorf("bonus = %v and %v, total %v, want only the correct answer to earn 7.5",
+			score.Results[0].TimeBonus, score.Results[1].TimeBonus, score.TimeBonus)
+	}
+	if score.TotalResponseTimeMs != 7500 {
+		t.Errorf("total response time = %d, want 7500", score.TotalResponseTimeMs)
+	}
+
+	// Untimed questions earn nothing and leave the total out
+	score = Evaluate(ids, []string{"z", answer.Answer})
+	score.AddTimings(map[string]Timing{"CRD0002": {ServedAt: served}}, bonus)
+	if score.TimeBonus != 0 || score.TotalResponseTimeMs != 0 || score.Results[1].ResponseTimeMs != 0 {
+		t.Errorf("score with unanswered timing = %+v", score)
+	}
+	score.AddTimings(map[string]Timing{"CRD0002": timings["CRD0002"]}, TimeBonus{})
+	if score.TimeBonus != 0 || score.TotalResponseTimeMs != 0 || score.Results[1].ResponseTimeMs != 2500 {
+		t.Errorf("score with one timed question and no bonus = %+v", score)
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"delfos/api"
+	"delfos/questions"
+	"delfos/scoring"
+	"delfos/sessions"
+)
+
+// submitAnswer handles recording one answer of a session, so a crashed or refreshed kiosk loses nothing
+// It expects a POST request to /session/{id}/answer with JSON body containing userEmail, questionId and answer
+func (srv *Server) submitAnswer(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.SubmitAnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid answer request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	logSession(r.Context(), req.UserEmail, sessionID)
+	if missing := missingFields("userEmail", req.UserEmail, "questionId", req.QuestionID, "answer", req.Answer); len(missing) > 0 {
+		writeMissingFields(w, r, missing)
+		return
+	}
+
+	var invalid *scoring.ValidationError
+	if err := scoring.Validate([]string{req.QuestionID}, []string{req.Answer}); errors.As(err, &invalid) {
+		slog.WarnContext(r.Context(), "invalid answer", "error", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidAnswers, "Answer does not match the question", invalid)
+		return
+	}
+
+	if closed := submissionError(event, time.Now()); closed != nil {
+		writeEventClosed(w, r, closed)
+		return
+	}
+
+	answer := strings.ToLower(strings.TrimSpace(req.Answer))
+	recorded, repeated, progress, err := srv.recordAnswer(r.Context(), event, req.UserEmail, sessionID, req.QuestionID, answer)
+	switch {
+	case errors.Is(err, sessions.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+		return
+	case errors.Is(err, sessions.ErrNotIssued):
+		slog.WarnContext(r.Context(), "answer for a question not issued to the session", "question_id", req.QuestionID)
+		writeError(w, r, http.StatusConflict, CodeQuestionSetMismatch, "Question was not issued to this session",
+			map[string][]string{"issued": progress.QuestionIDs})
+		return
+	case errors.Is(err, sessions.ErrExpired):
+		writeSessionExpired(w, r)
+		return
+	case errors.Is(err, sessions.ErrClosed):
+		writeError(w, r, http.StatusConflict, CodeSessionClosed, "Session no longer takes answers", nil)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "failed to record answer", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	// A retry carries the same answer; a different one would change an answer already given
+	if recorded != answer {
+		slog.WarnContext(r.Context(), "answer conflicts with the recorded one", "question_id", req.QuestionID)
+		writeError(w, r, http.StatusConflict, CodeAnswerConflict, "Question was already answered differently",
+			map[string]string{"questionId": req.QuestionID, "answer": recorded})
+		return
+	}
+
+	message := "Answer recorded"
+	if repeated {
+		message = "Answer was already recorded"
+	}
+	response := api.SubmitAnswerResponse{
+		Status:     "success",
+		Message:    message,
+		Event:      event.ID,
+		QuestionID: req.QuestionID,
+		Answer:     recorded,
+		Repeated:   repeated,
+		Answered:   progress.answered,
+		Total:      len(progress.QuestionIDs),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// answerProgress is the quiz issued to a session and how many of its questions hold an answer
+type answerProgress struct {
+	sessions.Issue
+	answered int
+}
+
+// recordAnswer records an answer of a session, timed by the server clock, and returns the answer
+// the session holds for the question with the session's progress; expired sessions take no answers
+func (srv *Server) recordAnswer(ctx context.Context, event *Event, userEmail, sessionID, questionID, answer string) (recorded string, repeated bool, progress answerProgress, err error) {
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+
+	now := time.Now()
+	content, err := sessions.Read(event.DataDir(), userEmail, sessionID)
+	if err != nil {
+		return "", false, progress, err
+	}
+	if sessions.Expired(content, time.Duration(srv.config.Quiz.SessionTTL), now) {
+		return "", false, progress, sessions.ErrExpired
+	}
+
+	recorded, repeated, err = sessions.RecordAnswer(ctx, event.DataDir(), userEmail, sessionID, questionID, answer, now)
+	if err != nil && !errors.Is(err, sessions.ErrNotIssued) {
+		return "", false, progress, err
+	}
+	content, readErr := sessions.Read(event.DataDir(), userEmail, sessionID)
+	if readErr != nil {
+		return "", false, progress, readErr
+	}
+	progress = answerProgress{Issue: sessions.Issued(content), answered: len(sessions.Answers(content))}
+	return recorded, repeated, progress, err
+}
+
+// evaluateSession handles scoring the answers a session recorded one at a time
+// It expects a POST request to /session/{id}/evaluate with JSON body containing userEmail; once every
+// issued question holds an answer the result is recorded and a receipt signed, and retries sign the
+// receipt again without recording a second result
+func (srv *Server) evaluateSession(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.EvaluateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid session evaluation request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	sessionID := r.PathValue("id")
+	logSession(r.Context(), req.UserEmail, sessionID)
+	if missing := missingFields("userEmail", req.UserEmail); len(missing) > 0 {
+		writeMissingFields(w, r, missing)
+		return
+	}
+
+	if closed := submissionError(event, time.Now()); closed != nil {
+		writeEventClosed(w, r, closed)
+		return
+	}
+
+	// Held for the whole evaluation, so concurrent retries record one result
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+
+	content, err := sessions.Read(event.DataDir(), req.UserEmail, sessionID)
+	if errors.Is(err, sessions.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read user file", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	issued, held := sessions.Issued(content), sessions.Answers(content)
+	if len(issued.QuestionIDs) == 0 {
+		writeError(w, r, http.StatusConflict, CodeQuestionSetMismatch, "No questions were issued to this session",
+			map[string][]string{"issued": issued.QuestionIDs})
+		return
+	}
+	answers := make([]string, len(issued.QuestionIDs))
+	var unanswered []string
+	for i, id := range issued.QuestionIDs {
+		answers[i] = held[id]
+		if answers[i] == "" {
+			unanswered = append(unanswered, id)
+		}
+	}
+	evaluated := sessions.Evaluated(content)
+	if !evaluated && sessions.Expired(content, time.Duration(srv.config.Quiz.SessionTTL), time.Now()) {
+		writeSessionExpired(w, r)
+		return
+	}
+	if len(unanswered) > 0 {
+		writeError(w, r, http.StatusConflict, CodeSessionIncomplete, "Session has unanswered questions",
+			map[string][]string{"unanswered": unanswered})
+		return
+	}
+	if !evaluated && !sessions.InProgress(content) {
+		writeError(w, r, http.StatusConflict, CodeSessionClosed, "Session no longer takes answers", nil)
+		return
+	}
+	addLogFields(r.Context(), "profile", questions.Profile(issued.QuestionIDs[0]))
+
+	score := srv.scoreAnswers(r.Context(), issued.QuestionIDs, answers, issued, sessions.Timings(content))
+	response := api.EvaluateAnswersResponse{
+		Status:  "success",
+		Message: "Answers evaluated successfully",
+		Event:   event.ID,
+		Score:   score,
+	}
+
+	if !evaluated {
+		recordQuizCompleted(event, issued.QuestionIDs, score, srv.config.Quiz.PassScore)
+		srv.auditAnswersSubmitted(r.Context(), event, sessionID, issued.QuestionIDs, answers)
+		if err := srv.recordResult(r.Context(), event, req.UserEmail, sessionID, issued.QuestionIDs, score); err != nil {
+			slog.ErrorContext(r.Context(), "failed to record evaluation result", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+		if err := sessions.MarkEvaluated(r.Context(), event.DataDir(), req.UserEmail, sessionID, nil, time.Now()); err != nil {
+			slog.ErrorContext(r.Context(), "failed to mark session evaluated", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+	}
+
+	receipt, err := srv.issueReceipt(event, req.UserEmail, sessionID, issued.QuestionIDs, score, srv.config.Quiz.PassScore, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to issue evaluation receipt", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	response.Receipt = receipt
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
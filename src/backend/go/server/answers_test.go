@@ -0,0 +1,127 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"delfos/api"
+)
+
+func TestSubmitAnswer(t *testing.T) {
+	answer := api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: "CRD0001", Answer: "a"}
+	runHandlerCases(t, []handlerCase{
+		{"no questions issued", http.MethodPost, apiPrefix + "/session/s-1/answer", answer, nil, http.StatusConflict, CodeQuestionSetMismatch},
+		{"question not issued", http.MethodPost, apiPrefix + "/session/s-1/answer",
+			api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: "SRV0001", Answer: "a"}, nil, http.StatusConflict, CodeQuestionSetMismatch},
+		{"unknown session", http.MethodPost, apiPrefix + "/session/s-2/answer", answer, nil, http.StatusNotFound, CodeUserNotFound},
+		{"missing fields", http.MethodPost, apiPrefix + "/session/s-1/answer",
+			api.SubmitAnswerRequest{UserEmail: "a@example.com"}, nil, http.StatusBadRequest, CodeMissingFields},
+		{"not an option", http.MethodPost, apiPrefix + "/session/s-1/answer",
+			api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: "CRD0001", Answer: "z"}, nil, http.StatusBadRequest, CodeInvalidAnswers},
+		{"unknown question", http.MethodPost, apiPrefix + "/session/s-1/answer",
+			api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: "XYZ0001", Answer: "a"}, nil, http.StatusBadRequest, CodeInvalidAnswers},
+		{"malformed body", http.MethodPost, apiPrefix + "/session/s-1/answer", "{", nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"event ended", http.MethodPost, apiPrefix + "/session/s-1/answer?event=ended", answer, nil, http.StatusForbidden, CodeEventEnded},
+		{"wrong method", http.MethodGet, apiPrefix + "/session/s-1/answer", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, func(s *testServer) {
+		createTestUser(s, "default", "a@example.com", "s-1")
+	})
+}
+
+func TestSubmitAnswerIdempotent(t *testing.T) {
+	s := newTestServer(t)
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	submit := func(path, answer string) *api.SubmitAnswerResponse {
+		t.Helper()
+		rec := s.do(http.MethodPost, path, api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: ids[0], Answer: answer}, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("answer %q: status = %d, body %q", answer, rec.Code, rec.Body.String())
+		}
+		response := decodeBody[api.SubmitAnswerResponse](t, rec)
+		return &response
+	}
+
+	if got := submit(apiPrefix+"/session/s-1/answer", "B"); got.Answer != "b" || got.Repeated || got.Answered != 1 || got.Total != len(ids) {
+		t.Errorf("first answer = %+v, want b recorded, 1 of %d answered", got, len(ids))
+	}
+
+	// A retry, here from another kiosk on the legacy path, gets the answer already held
+	if got := submit("/session/s-1/answer", " b "); got.Answer != "b" || !got.Repeated || got.Answered != 1 {
+		t.Errorf("retry = %+v, want b repeated, 1 answered", got)
+	}
+
+	rec := s.do(http.MethodPost, apiPrefix+"/session/s-1/answer", api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: ids[0], Answer: "c"}, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("different answer: status = %d, want 409 (body %q)", rec.Code, rec.Body.String())
+	}
+	if got := decodeBody[ErrorResponse](t, rec); got.Code != CodeAnswerConflict || got.Details.(map[string]any)["answer"] != "b" {
+		t.Errorf("different answer = %+v, want %s holding b", got, CodeAnswerConflict)
+	}
+
+	// Answers in bulk end the session
+	update := api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1}, UserAnswers: []string{"a"}}
+	if rec := s.do(http.MethodPost, apiPrefix+"/user/update", update, nil); rec.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	rec = s.do(http.MethodPost, apiPrefix+"/session/s-1/answer", api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: ids[1], Answer: "a"}, nil)
+	if rec.Code != http.StatusConflict || decodeBody[ErrorResponse](t, rec).Code != CodeSessionClosed {
+		t.Errorf("answer after the bulk answers: status = %d, body %q, want 409 %s", rec.Code, rec.Body.String(), CodeSessionClosed)
+	}
+}
+
+func TestEvaluateSession(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"no questions issued", http.MethodPost, apiPrefix + "/session/s-1/evaluate",
+			api.EvaluateSessionRequest{UserEmail: "a@example.com"}, nil, http.StatusConflict, CodeQuestionSetMismatch},
+		{"unknown session", http.MethodPost, apiPrefix + "/session/s-2/evaluate",
+			api.EvaluateSessionRequest{UserEmail: "a@example.com"}, nil, http.StatusNotFound, CodeUserNotFound},
+		{"missing email", http.MethodPost, apiPrefix + "/session/s-1/evaluate",
+			api.EvaluateSessionRequest{}, nil, http.StatusBadRequest, CodeMissingFields},
+		{"event ended", http.MethodPost, apiPrefix + "/session/s-1/evaluate?event=ended",
+			api.EvaluateSessionRequest{UserEmail: "a@example.com"}, nil, http.StatusForbidden, CodeEventEnded},
+	}, func(s *testServer) {
+		createTestUser(s, "default", "a@example.com", "s-1")
+	})
+
+	s := newTestServer(t)
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	answers := shownAnswers(s, "default", "a@example.com", "s-1", ids, correctAnswers(t, ids))
+
+	// Scoring waits for every issued question
+	for i := range ids[1:] {
+		s.do(http.MethodPost, apiPrefix+"/session/s-1/answer", api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: ids[i+1], Answer: answers[i+1]}, nil)
+	}
+	rec := s.do(http.MethodPost, apiPrefix+"/session/s-1/evaluate", api.EvaluateSessionRequest{UserEmail: "a@example.com"}, nil)
+	if got := decodeBody[ErrorResponse](t, rec); rec.Code != http.StatusConflict || got.Code != CodeSessionIncomplete {
+		t.Fatalf("evaluate with %s unanswered: status = %d, body %q, want 409 %s", ids[0], rec.Code, rec.Body.String(), CodeSessionIncomplete)
+	}
+	s.do(http.MethodPost, apiPrefix+"/session/s-1/answer", api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: ids[0], Answer: answers[0]}, nil)
+
+	// Resent arrays cannot change what the server holds
+	wrong := shownAnswers(s, "default", "a@example.com", "s-1", ids, wrongAnswers(t, ids))
+	bulk := api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: wrong, UserEmail: "a@example.com", SessionID: "s-1"}
+	rec = s.do(http.MethodPost, apiPrefix+"/evaluate-answers", bulk, nil)
+	if got := decodeBody[ErrorResponse](t, rec); rec.Code != http.StatusConflict || got.Code != CodeAnswerConflict {
+		t.Errorf("evaluate other answers: status = %d, body %q, want 409 %s", rec.Code, rec.Body.String(), CodeAnswerConflict)
+	}
+
+	// A retry signs the receipt again without recording a second result
+	for attempt := range 2 {
+		rec := s.do(http.MethodPost, apiPrefix+"/session/s-1/evaluate", api.EvaluateSessionRequest{UserEmail: "a@example.com"}, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("evaluation %d: status = %d, body %q", attempt+1, rec.Code, rec.Body.String())
+		}
+		result := decodeBody[api.EvaluateAnswersResponse](t, rec)
+		if result.ScorePercentage != 100 || result.TotalQuestions != len(ids) || result.Receipt == "" {
+			t.Errorf("evaluation %d = %v%% of %d questions, receipt %q, want 100%% of %d with a receipt",
+				attempt+1, result.ScorePercentage, result.TotalQuestions, result.Receipt, len(ids))
+		}
+	}
+	content, _ := os.ReadFile(filepath.Join(s.srv.config.DataDir, "results.txt"))
+	if lines := strings.Count(string(content), "|a@example.com|s-1|"); lines != 1 {
+		t.Errorf("results.txt = %q, want one result for s-1", content)
+	}
+}
@@ -0,0 +1,391 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"delfos/scoring"
+)
+
+// Audited actions
+const (
+	AuditUserCreate      = "user.create"
+	AuditAnswersSubmit   = "answers.submit"
+	AuditQuizEvaluate    = "quiz.evaluate"
+	AuditWinnerIncrement = "winner.increment"
+	AuditPrizeAward      = "prize.award"
+	AuditPrizeRedeem     = "prize.redeem"
+	AuditKioskRegister   = "kiosk.register"
+	AuditKioskDisable    = "kiosk.disable"
+	AuditAdminLogin      = "admin.login"
+	AuditEventClose      = "event.close"
+	AuditSessionExpire   = "session.expire"
+)
+
+const auditActorContextKey contextKey = "auditActor"
+
+// Entries returned by /admin/audit when no limit is given, and the most it returns
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+// AuditEntry is one line of the audit log
+// Hash is the SHA-256 of the entry serialized with an empty hash, and PrevHash links it to the
+// entry before it, so editing or removing a line breaks the chain from that point on
+type AuditEntry struct {
+	Seq       int64           `json:"seq"`
+	Time      string          `json:"time"`
+	Actor     string          `json:"actor"` // admin:<name>, kiosk:<id>, system or anonymous
+	Kiosk     string          `json:"kiosk,omitempty"`
+	Event     string          `json:"event,omitempty"`
+	Action    string          `json:"action"`
+	Subject   string          `json:"subject,omitempty"` // Session, kiosk or operator the action applies to
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash"`
+}
+
+// AuditQueryResponse represents the response for an audit log query
+type AuditQueryResponse struct {
+	Status  string       `json:"status"`
+	Count   int          `json:"count"`
+	Entries []AuditEntry `json:"entries"`
+}
+
+// auditFile returns the path of the audit log, shared by all events
+func (srv *Server) auditFile() string {
+	return filepath.Join(srv.config.DataDir, "audit.log")
+}
+
+// withAuditActor returns a context whose audited actions are attributed to actor
+// Used for actions the server takes by itself, such as closing an ended event
+func withAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorContextKey, actor)
+}
+
+// auditActor returns who is acting in ctx: an operator, a kiosk, the server itself or an anonymous client
+func auditActor(ctx context.Context) string {
+	if principal, ok := ctx.Value(adminContextKey).(*AdminPrincipal); ok {
+		return "admin:" + principal.Name
+	}
+	if actor, ok := ctx.Value(auditActorContextKey).(string); ok {
+		return actor
+	}
+	if kiosk, ok := ctx.Value(kioskContextKey).(*Kiosk); ok {
+		return "kiosk:" + kiosk.ID
+	}
+	return "anonymous"
+}
+
+// recordAudit appends an action to the audit log; before and after are any JSON-encodable values
+// The action has already happened, so a failure to audit it is logged and counted rather than returned
+func (srv *Server) recordAudit(ctx context.Context, event *Event, action, subject string, before, after any) {
+	entry := AuditEntry{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Actor:     auditActor(ctx),
+		Action:    action,
+		Subject:   subject,
+		RequestID: requestID(ctx),
+	}
+	if kiosk, ok := ctx.Value(kioskContextKey).(*Kiosk); ok {
+		entry.Kiosk = kiosk.ID
+	}
+	if event != nil {
+		entry.Event = event.ID
+	}
+
+	var err error
+	if entry.Before, err = auditValue(before); err == nil {
+		entry.After, err = auditValue(after)
+	}
+	if err == nil {
+		err = srv.appendAudit(ctx, entry)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record audit entry", "action", action, "error", err)
+	}
+}
+
+// auditUserCreated audits a new player session
+func (srv *Server) auditUserCreated(ctx context.Context, event *Event, userEmail, sessionID, createdAt string) {
+	srv.recordAudit(ctx, event, AuditUserCreate, sessionID, nil, map[string]string{
+		"emailHash": hashEmail(userEmail),
+		"createdAt": createdAt,
+	})
+}
+
+// auditAnswersSubmitted audits the answers stored for a session
+func (srv *Server) auditAnswersSubmitted(ctx context.Context, event *Event, sessionID string, questionIDs, userAnswers []string) {
+	srv.recordAudit(ctx, event, AuditAnswersSubmit, sessionID, nil, map[string][]string{
+		"questionIds": questionIDs,
+		"userAnswers": userAnswers,
+	})
+}
+
+// auditEvaluation audits an evaluation recorded in the event results
+func (srv *Server) auditEvaluation(ctx context.Context, event *Event, sessionID string, questionIDs []string, evaluation scoring.Score, passScore float64) {
+	srv.recordAudit(ctx, event, AuditQuizEvaluate, sessionID, nil, map[string]any{
+		"questionIds":     questionIDs,
+		"correctAnswers":  evaluation.CorrectAnswers,
+		"totalQuestions":  evaluation.TotalQuestions,
+		"scorePercentage": evaluation.ScorePercentage,
+		"passed":          evaluation.Passed(passScore),
+	})
+}
+
+// auditValue encodes a before or after value, leaving it out when nil
+func auditValue(value any) (json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit value: %w", err)
+	}
+	return encoded, nil
+}
+
+// appendAudit chains the entry to the last one and appends it to the log, synced to disk
+func (srv *Server) appendAudit(ctx context.Context, entry AuditEntry) (err error) {
+	defer traceStorage(ctx, "append_audit")(&err)
+
+	srv.auditMu.Lock()
+	defer srv.auditMu.Unlock()
+
+	path := srv.auditFile()
+	if srv.auditTail.path != path {
+		last, err := readLastAuditEntry(path)
+		if err != nil {
+			return err
+		}
+		srv.auditTail.path, srv.auditTail.seq, srv.auditTail.hash = path, last.Seq, last.Hash
+	}
+
+	entry.Seq = srv.auditTail.seq + 1
+	entry.PrevHash = srv.auditTail.hash
+	if entry.Hash, err = auditHash(entry); err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	srv.auditTail.seq, srv.auditTail.hash = entry.Seq, entry.Hash
+	return nil
+}
+
+// auditHash returns the hex SHA-256 of the entry serialized with an empty hash
+func auditHash(entry AuditEntry) (string, error) {
+	entry.Hash = ""
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readLastAuditEntry returns the last entry of the log, or a zero entry when there is none
+func readLastAuditEntry(path string) (AuditEntry, error) {
+	var last AuditEntry
+	err := scanAudit(path, func(entry AuditEntry) error {
+		last = entry
+		return nil
+	})
+	return last, err
+}
+
+// scanAudit calls fn with every entry of the log in order; a missing log has no entries
+func scanAudit(path string, fn func(AuditEntry) error) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var entry AuditEntry
+			if jsonErr := json.Unmarshal(line, &entry); jsonErr != nil {
+				return fmt.Errorf("audit log line %d is not a valid entry: %w", lineNumber, jsonErr)
+			}
+			if fnErr := fn(entry); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+}
+
+// verifyAuditLog checks the sequence numbers, links and hashes of every entry
+// It returns the number of entries checked and the first problem found
+func verifyAuditLog(path string) (int, error) {
+	count := 0
+	var prev AuditEntry
+	err := scanAudit(path, func(entry AuditEntry) error {
+		count++
+		if entry.Seq != prev.Seq+1 {
+			return fmt.Errorf("entry %d: sequence jumps from %d to %d", count, prev.Seq, entry.Seq)
+		}
+		if entry.PrevHash != prev.Hash {
+			return fmt.Errorf("entry seq %d: prevHash does not match the hash of entry seq %d", entry.Seq, prev.Seq)
+		}
+		hash, err := auditHash(entry)
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("entry seq %d: content does not match its hash", entry.Seq)
+		}
+		prev = entry
+		return nil
+	})
+	return count, err
+}
+
+// queryAudit handles querying the audit log for operators
+// It expects a GET request with optional action, actor, kiosk, event, subject, since and until
+// (RFC3339) filters and a limit; the most recent matching entries are returned oldest first
+func (srv *Server) queryAudit(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := auditDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > auditMaxLimit {
+			writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", auditMaxLimit),
+				map[string]string{"parameter": "limit"})
+			return
+		}
+		limit = parsed
+	}
+	var since, until time.Time
+	bounds := []struct {
+		name   string
+		target *time.Time
+	}{{"since", &since}, {"until", &until}}
+	for _, bound := range bounds {
+		if value := query.Get(bound.name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, bound.name+" must be an RFC3339 timestamp",
+					map[string]string{"parameter": bound.name})
+				return
+			}
+			*bound.target = parsed
+		}
+	}
+
+	filters := map[string]func(AuditEntry) string{
+		"action":  func(e AuditEntry) string { return e.Action },
+		"actor":   func(e AuditEntry) string { return e.Actor },
+		"kiosk":   func(e AuditEntry) string { return e.Kiosk },
+		"event":   func(e AuditEntry) string { return e.Event },
+		"subject": func(e AuditEntry) string { return e.Subject },
+	}
+
+	entries := []AuditEntry{}
+	srv.auditMu.Lock()
+	err := scanAudit(srv.auditFile(), func(entry AuditEntry) error {
+		for name, field := range filters {
+			if value := query.Get(name); value != "" && field(entry) != value {
+				return nil
+			}
+		}
+		if !since.IsZero() || !until.IsZero() {
+			at, err := time.Parse(time.RFC3339Nano, entry.Time)
+			if err != nil || (!since.IsZero() && at.Before(since)) || (!until.IsZero() && !at.Before(until)) {
+				return nil
+			}
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	srv.auditMu.Unlock()
+	if err != nil {
+		storageErrors.Inc("read_audit")
+		slog.ErrorContext(r.Context(), "failed to read audit log", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	response := AuditQueryResponse{
+		Status:  "success",
+		Count:   len(entries),
+		Entries: entries,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// runVerifyAudit checks the audit log chain of the configured data directory
+// It accepts the server flags (--config, --data-dir, ...) to find the log
+func runVerifyAudit(args []string, stdin io.Reader, stdout io.Writer) error {
+	cfg, _, err := LoadConfig(args, os.Getenv)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(cfg.DataDir, "audit.log")
+
+	count, err := verifyAuditLog(path)
+	if err != nil {
+		return fmt.Errorf("audit log %s is corrupted after %d valid entries: %w", path, max(count-1, 0), err)
+	}
+	if count == 0 {
+		if _, statErr := os.Stat(path); errors.Is(statErr, os.ErrNotExist) {
+			fmt.Fprintf(stdout, "%s does not exist yet, nothing to verify\n", path)
+			return nil
+		}
+	}
+	fmt.Fprintf(stdout, "%s: %d entries, chain intact\n", path, count)
+	return nil
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// setupAuditTest returns a server over a temporary data directory, with an empty audit log
+func setupAuditTest(t *testing.T) *Server {
+	t.Helper()
+	return newServer(t, testConfig(t))
+}
+
+func TestAuditChain(t *testing.T) {
+	srv := setupAuditTest(t)
+	ctx := context.Background()
+	srv.recordAudit(ctx, nil, AuditKioskRegister, "booth-1", nil, map[string]string{"name": "Booth 1"})
+	srv.recordAudit(ctx, nil, AuditKioskDisable, "booth-1", map[string]bool{"disabled": false}, map[string]bool{"disabled": true})
+
+	// A restart has to continue the chain from the log, not from memory
+	srv.auditTail.path = ""
+	srv.recordAudit(ctx, nil, AuditKioskDisable, "booth-1", map[string]bool{"disabled": true}, map[string]bool{"disabled": false})
+
+	count, err := verifyAuditLog(srv.auditFile())
+	if err != nil {
+		t.Fatalf("verifyAuditLog: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("entries = %d, want 3", count)
+	}
+
+	var out bytes.Buffer
+	if err := runVerifyAudit([]string{"--data-dir", srv.config.DataDir}, nil, &out); err != nil {
+		t.Fatalf("verify-audit: %v", err)
+	}
+	if !strings.Contains(out.String(), "3 entries, chain intact") {
+		t.Errorf("verify-audit output = %q", out.String())
+	}
+}
+
+func TestAuditTamperDetection(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(lines []string) []string
+	}{
+		{"edited entry", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"disabled":true`, `"disabled":false`, 1)
+			return lines
+		}},
+		{"removed entry", func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		}},
+		{"reordered entries", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}},
+		{"rehashed entry", func(lines []string) []string {
+			var entry AuditEntry
+			json.Unmarshal([]byte(lines[1]), &entry)
+			entry.Subject = "booth-2"
+			entry.Hash, _ = auditHash(entry)
+			encoded, _ := json.Marshal(entry)
+			lines[1] = string(encoded)
+			return lines
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupAuditTest(t)
+			for i := 0; i < 3; i++ {
+				srv.recordAudit(context.Background(), nil, AuditKioskDisable, "booth-1", nil, map[string]bool{"disabled": i%2 == 1})
+			}
+
+			content, err := os.ReadFile(srv.auditFile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := tt.tamper(strings.Split(strings.TrimSpace(string(content)), "\n"))
+			if err := os.WriteFile(srv.auditFile(), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := verifyAuditLog(srv.auditFile()); err == nil {
+				t.Error("verifyAuditLog accepted a tampered log")
+			}
+			if err := runVerifyAudit([]string{"--data-dir", srv.config.DataDir}, nil, &bytes.Buffer{}); err == nil {
+				t.Error("verify-audit accepted a tampered log")
+			}
+		})
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	ctx := context.Background()
+	kiosk := context.WithValue(ctx, kioskContextKey, &Kiosk{ID: "booth-1"})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{"anonymous", ctx, "anonymous"},
+		{"kiosk", kiosk, "kiosk:booth-1"},
+		{"system", withAuditActor(kiosk, "system"), "system"},
+		{"operator", context.WithValue(kiosk, adminContextKey, &AdminPrincipal{Name: "ana", Role: RoleAdmin}), "admin:ana"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auditActor(tt.ctx); got != tt.want {
+				t.Errorf("auditActor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryAudit(t *testing.T) {
+	srv := setupAuditTest(t)
+	kiosk := context.WithValue(context.Background(), kioskContextKey, &Kiosk{ID: "booth-1"})
+	srv.recordAudit(kiosk, nil, AuditUserCreate, "s-1", nil, nil)
+	srv.recordAudit(kiosk, nil, AuditQuizEvaluate, "s-1", nil, nil)
+	srv.recordAudit(context.Background(), nil, AuditUserCreate, "s-2", nil, nil)
+	srv.recordAudit(withAuditActor(context.Background(), "system"), nil, AuditEventClose, "expo", nil, nil)
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantActions []string
+	}{
+		{"all", "", http.StatusOK, []string{AuditUserCreate, AuditQuizEvaluate, AuditUserCreate, AuditEventClose}},
+		{"by action", "?action=user.create", http.StatusOK, []string{AuditUserCreate, AuditUserCreate}},
+		{"by actor", "?actor=system", http.StatusOK, []string{AuditEventClose}},
+		{"by kiosk and subject", "?kiosk=booth-1&subject=s-1", http.StatusOK, []string{AuditUserCreate, AuditQuizEvaluate}},
+		{"limit keeps the most recent", "?limit=2", http.StatusOK, []string{AuditUserCreate, AuditEventClose}},
+		{"until the epoch", "?until=1970-01-01T00:00:00Z", http.StatusOK, []string{}},
+		{"bad limit", "?limit=0", http.StatusBadRequest, nil},
+		{"bad since", "?since=yesterday", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.queryAudit(rec, httptest.NewRequest(http.MethodGet, "/admin/audit"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var response AuditQueryResponse
+			if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			actions := []string{}
+			for _, entry := range response.Entries {
+				actions = append(actions, entry.Action)
+			}
+			if strings.Join(actions, ",") != strings.Join(tt.wantActions, ",") {
+				t.Errorf("actions = %v, want %v", actions, tt.wantActions)
+			}
+		})
+	}
+}
+
+func TestQueryAuditRequiresStaff(t *testing.T) {
+	srv := setupAuditTest(t)
+	srv.config.Admin.APIKeys = []AdminAPIKey{
+		{Name: "dashboard", KeyHash: hashToken("viewer-key"), Role: RoleViewer},
+		{Name: "desk", KeyHash: hashToken("staff-key"), Role: RoleStaff},
+	}
+	handler := srv.withAdminMiddleware(RoleStaff, srv.queryAudit)
+
+	for key, want := range map[string]int{"": http.StatusUnauthorized, "viewer-key": http.StatusForbidden, "staff-key": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/audit", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			t.Errorf("key %q: status = %d, want %d", key, rec.Code, want)
+		}
+	}
+}
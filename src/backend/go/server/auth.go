@@ -0,0 +1,393 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Role is what an operator is allowed to do; each role includes the ones below it
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // Dashboards and read-only admin routes
+	RoleStaff  Role = "staff"  // Prize redemption at the booth
+	RoleAdmin  Role = "admin"  // Question, event and kiosk management
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleStaff: 2, RoleAdmin: 3}
+
+// Allows reports whether the role includes required
+func (r Role) Allows(required Role) bool {
+	return roleRank[r] >= roleRank[required] && roleRank[r] > 0
+}
+
+const (
+	adminContextKey    contextKey = "admin"
+	adminSessionCookie            = "delfos_admin"
+
+	// PBKDF2-SHA256 parameters for operator passwords (OWASP 2023 recommendation)
+	passwordIterations = 600000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+)
+
+// AdminPrincipal is the authenticated operator of a request
+type AdminPrincipal struct {
+	Name   string `json:"name"`
+	Role   Role   `json:"role"`
+	Method string `json:"method"` // password, session or apikey
+}
+
+// AdminLoginRequest represents the request body for operator login
+type AdminLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AdminLoginResponse represents the response for operator login
+type AdminLoginResponse struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	Token     string `json:"token"`
+	Role      Role   `json:"role"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// AdminLogoutResponse represents the response for operator logout
+type AdminLogoutResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// AdminWhoAmIResponse represents the authenticated operator
+type AdminWhoAmIResponse struct {
+	Status string          `json:"status"`
+	Admin  *AdminPrincipal `json:"admin"`
+}
+
+// adminClaims is the payload of an operator session token
+type adminClaims struct {
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// adminSessionKey returns the key signing operator sessions
+// Without admin.sessionSecret a random key is used, so sessions do not survive a restart
+func (srv *Server) adminSessionKey() []byte {
+	srv.sessionKeyOnce.Do(func() {
+		if srv.config.Admin.SessionSecret != "" {
+			srv.sessionKey = []byte(srv.config.Admin.SessionSecret)
+			return
+		}
+		srv.sessionKey = make([]byte, 32)
+		rand.Read(srv.sessionKey)
+		slog.Warn("admin.sessionSecret is not set, operator sessions end when the server restarts")
+	})
+	return srv.sessionKey
+}
+
+// withAdminMiddleware is withMiddleware for the /admin routes: the stricter admin CORS policy
+// and rate limit, then authentication; an empty role leaves the route open (login, logout)
+func (srv *Server) withAdminMiddleware(role Role, handler http.HandlerFunc) http.HandlerFunc {
+	if role != "" {
+		handler = srv.requireRole(role, handler)
+	}
+	return loggingMiddleware(tracingMiddleware(corsMiddleware(srv.adminCORSPolicy(), srv.kioskMiddleware(false, srv.rateLimitMiddleware(true, metricsMiddleware(handler))))))
+}
+
+// requireRole authenticates the operator and checks their role
+// Credentials are an API key or session token as "Authorization: Bearer", an X-API-Key header or the session cookie;
+// missing or invalid credentials get 401, a role that is too low gets 403
+func (srv *Server) requireRole(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, err := srv.authenticateAdmin(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "admin authentication failed", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="delfos-admin"`)
+			writeError(w, r, http.StatusUnauthorized, CodeAuthRequired, "Authentication required", nil)
+			return
+		}
+		addLogFields(r.Context(), "admin", principal.Name, "role", principal.Role)
+		if !principal.Role.Allows(role) {
+			slog.WarnContext(r.Context(), "admin role too low", "required_role", role)
+			writeError(w, r, http.StatusForbidden, CodeForbidden, "Forbidden",
+				map[string]Role{"role": principal.Role, "requiredRole": role})
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), adminContextKey, principal)))
+	}
+}
+
+// requestAdmin returns the operator authenticated for the request, or nil
+func requestAdmin(r *http.Request) *AdminPrincipal {
+	principal, _ := r.Context().Value(adminContextKey).(*AdminPrincipal)
+	return principal
+}
+
+// authenticateAdmin resolves the operator from the request credentials
+func (srv *Server) authenticateAdmin(r *http.Request) (*AdminPrincipal, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, credential, _ := strings.Cut(auth, " ")
+		if !strings.EqualFold(scheme, "Bearer") || credential == "" {
+			return nil, errors.New("unsupported authorization scheme")
+		}
+		if strings.Count(credential, ".") == 2 {
+			return srv.verifyAdminToken(credential, time.Now())
+		}
+		return srv.authenticateAPIKey(credential)
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return srv.authenticateAPIKey(key)
+	}
+	if cookie, err := r.Cookie(adminSessionCookie); err == nil {
+		return srv.verifyAdminToken(cookie.Value, time.Now())
+	}
+	return nil, errors.New("no credentials")
+}
+
+// authenticateAPIKey matches a key against the configured key hashes
+func (srv *Server) authenticateAPIKey(key string) (*AdminPrincipal, error) {
+	hash := []byte(hashToken(key))
+	for _, apiKey := range srv.config.Admin.APIKeys {
+		if subtle.ConstantTimeCompare(hash, []byte(strings.ToLower(apiKey.KeyHash))) == 1 {
+			return &AdminPrincipal{Name: apiKey.Name, Role: apiKey.Role, Method: "apikey"}, nil
+		}
+	}
+	return nil, errors.New("unknown api key")
+}
+
+// authenticatePassword checks a username and password against the configured operators
+func (srv *Server) authenticatePassword(username, password string) (*AdminPrincipal, error) {
+	for _, user := range srv.config.Admin.Users {
+		if user.Username == username {
+			if !verifyPassword(user.PasswordHash, password) {
+				return nil, errors.New("wrong password")
+			}
+			return &AdminPrincipal{Name: user.Username, Role: user.Role, Method: "password"}, nil
+		}
+	}
+	// Spend the same time as a real check so usernames cannot be probed
+	verifyPassword(dummyPasswordHash, password)
+	return nil, errors.New("unknown user")
+}
+
+// Hash checked for unknown usernames, its password is not used anywhere
+var dummyPasswordHash = "pbkdf2-sha256$" + strconv.Itoa(passwordIterations) + "$AAAAAAAAAAAAAAAAAAAAAA$AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+
+// hashPassword returns a PBKDF2-SHA256 hash formatted as pbkdf2-sha256$iterations$salt$key
+// bcrypt lives in golang.org/x/crypto and the module has no dependencies outside the standard
+// library, so crypto/pbkdf2 stands in for it; the prefix leaves room for another scheme later
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// parsePasswordHash splits a hash produced by hashPassword
+func parsePasswordHash(encoded string) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return 0, nil, nil, errors.New("password hash must look like pbkdf2-sha256$iterations$salt$key")
+	}
+	if iterations, err = strconv.Atoi(parts[1]); err != nil || iterations < 1 {
+		return 0, nil, nil, errors.New("invalid password hash iterations")
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return 0, nil, nil, fmt.Errorf("invalid password hash salt: %w", err)
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil || len(key) == 0 {
+		return 0, nil, nil, errors.New("invalid password hash key")
+	}
+	return iterations, salt, key, nil
+}
+
+// verifyPassword reports whether password matches a hash produced by hashPassword
+func verifyPassword(encoded, password string) bool {
+	iterations, salt, key, err := parsePasswordHash(encoded)
+	if err != nil {
+		return false
+	}
+	derived, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(key))
+	return err == nil && subtle.ConstantTimeCompare(derived, key) == 1
+}
+
+// issueAdminToken returns a signed HS256 JWT for the operator
+func (srv *Server) issueAdminToken(principal *AdminPrincipal, now time.Time) (string, time.Time, error) {
+	expires := now.Add(time.Duration(srv.config.Admin.SessionTTL))
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, err := json.Marshal(adminClaims{
+		Subject:   principal.Name,
+		Role:      principal.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to encode token claims: %w", err)
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + srv.signAdminToken(unsigned), expires, nil
+}
+
+// verifyAdminToken checks the signature and expiry of a session token
+// The role comes from the configuration when the operator still exists, so demotions apply at once
+func (srv *Server) verifyAdminToken(token string, now time.Time) (*AdminPrincipal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed session token")
+	}
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(srv.signAdminToken(unsigned))) {
+		return nil, errors.New("invalid session token signature")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &header) != nil || header.Alg != "HS256" {
+		return nil, errors.New("unsupported session token")
+	}
+	var claims adminClaims
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return nil, errors.New("malformed session token claims")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("session token expired")
+	}
+
+	for _, user := range srv.config.Admin.Users {
+		if user.Username == claims.Subject {
+			return &AdminPrincipal{Name: user.Username, Role: user.Role, Method: "session"}, nil
+		}
+	}
+	return nil, errors.New("session token for a removed operator")
+}
+
+func (srv *Server) signAdminToken(unsigned string) string {
+	mac := hmac.New(sha256.New, srv.adminSessionKey())
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// adminLogin handles operator login with username and password
+// It expects a POST request with JSON body containing username and password; the session token
+// is returned in the body for API clients and set as an HttpOnly cookie for the browser
+func (srv *Server) adminLogin(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req AdminLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid admin login", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	principal, err := srv.authenticatePassword(req.Username, req.Password)
+	if err != nil {
+		slog.WarnContext(r.Context(), "admin login failed", "admin", req.Username, "error", err)
+		writeError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password", nil)
+		return
+	}
+	addLogFields(r.Context(), "admin", principal.Name, "role", principal.Role)
+
+	token, expires, err := srv.issueAdminToken(principal, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to issue admin session", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    token,
+		Path:     adminCookiePath(r),
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	srv.recordAudit(context.WithValue(r.Context(), adminContextKey, principal), nil, AuditAdminLogin, principal.Name,
+		nil, map[string]any{"role": principal.Role, "expiresAt": expires.Format(time.RFC3339)})
+	slog.InfoContext(r.Context(), "admin logged in")
+
+	response := AdminLoginResponse{
+		Status:    "success",
+		Message:   "Logged in",
+		Token:     token,
+		Role:      principal.Role,
+		ExpiresAt: expires.Format(time.RFC3339),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// adminCookiePath scopes the session cookie to the admin routes under the prefix the login used,
+// /api/v1/admin or the legacy /admin
+func adminCookiePath(r *http.Request) string {
+	if strings.HasPrefix(r.URL.Path, apiPrefix+"/") {
+		return apiPrefix + "/admin"
+	}
+	return "/admin"
+}
+
+// adminLogout handles clearing the operator session cookie
+// It expects a POST request; bearer tokens stay valid until they expire
+func (srv *Server) adminLogout(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    "",
+		Path:     adminCookiePath(r),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AdminLogoutResponse{Status: "success", Message: "Logged out"})
+}
+
+// adminWhoAmI handles returning the authenticated operator
+// It expects a GET request
+func (srv *Server) adminWhoAmI(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(AdminWhoAmIResponse{
+		Status: "success",
+		Admin:  requestAdmin(r),
+	})
+}
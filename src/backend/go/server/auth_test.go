@@ -0,0 +1,185 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupAuthTest returns a server with a staff operator with password "correct horse" and a viewer API key "viewer-key"
+func setupAuthTest(t *testing.T) *Server {
+	t.Helper()
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	cfg := testConfig(t)
+	cfg.Admin.Users = []AdminUser{{Username: "ana", PasswordHash: hash, Role: RoleStaff}}
+	cfg.Admin.APIKeys = []AdminAPIKey{{Name: "grafana", KeyHash: hashToken("viewer-key"), Role: RoleViewer}}
+	return newServer(t, cfg)
+}
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$") {
+		t.Errorf("hash = %q, want pbkdf2-sha256 prefix", hash)
+	}
+	if !verifyPassword(hash, "correct horse") {
+		t.Error("correct password rejected")
+	}
+	if verifyPassword(hash, "correct horse ") {
+		t.Error("wrong password accepted")
+	}
+	if other, _ := hashPassword("correct horse"); other == hash {
+		t.Error("two hashes of the same password share a salt")
+	}
+	if verifyPassword("plain-text", "plain-text") {
+		t.Error("malformed hash accepted")
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, required Role
+		want           bool
+	}{
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleStaff, false},
+		{RoleStaff, RoleViewer, true},
+		{RoleStaff, RoleAdmin, false},
+		{RoleAdmin, RoleStaff, true},
+		{Role("root"), RoleViewer, false},
+		{Role(""), Role(""), false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.required); got != tt.want {
+			t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	srv := setupAuthTest(t)
+	now := time.Now()
+
+	token, expires, err := srv.issueAdminToken(&AdminPrincipal{Name: "ana", Role: RoleStaff}, now)
+	if err != nil {
+		t.Fatalf("issueAdminToken: %v", err)
+	}
+	if want := now.Add(12 * time.Hour); !expires.Equal(want) {
+		t.Errorf("expires = %v, want %v", expires, want)
+	}
+
+	principal, err := srv.verifyAdminToken(token, now)
+	if err != nil || principal.Name != "ana" || principal.Role != RoleStaff {
+		t.Fatalf("verifyAdminToken = %+v, %v", principal, err)
+	}
+	if _, err := srv.verifyAdminToken(token, expires); err == nil {
+		t.Error("expired token accepted")
+	}
+	if _, err := srv.verifyAdminToken(token[:len(token)-2]+"xx", now); err == nil {
+		t.Error("tampered signature accepted")
+	}
+
+	// Changing the role in the config applies to existing sessions
+	srv.config.Admin.Users[0].Role = RoleViewer
+	if principal, _ := srv.verifyAdminToken(token, now); principal == nil || principal.Role != RoleViewer {
+		t.Errorf("role after demotion = %+v, want viewer", principal)
+	}
+	srv.config.Admin.Users = nil
+	if _, err := srv.verifyAdminToken(token, now); err == nil {
+		t.Error("token of a removed operator accepted")
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	srv := setupAuthTest(t)
+	token, _, err := srv.issueAdminToken(&AdminPrincipal{Name: "ana", Role: RoleStaff}, time.Now())
+	if err != nil {
+		t.Fatalf("issueAdminToken: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		required   Role
+		setup      func(r *http.Request)
+		wantStatus int
+	}{
+		{"no credentials", RoleViewer, func(r *http.Request) {}, http.StatusUnauthorized},
+		{"api key bearer", RoleViewer, func(r *http.Request) { r.Header.Set("Authorization", "Bearer viewer-key") }, http.StatusOK},
+		{"api key header", RoleViewer, func(r *http.Request) { r.Header.Set("X-API-Key", "viewer-key") }, http.StatusOK},
+		{"api key role too low", RoleStaff, func(r *http.Request) { r.Header.Set("X-API-Key", "viewer-key") }, http.StatusForbidden},
+		{"unknown api key", RoleViewer, func(r *http.Request) { r.Header.Set("X-API-Key", "guess") }, http.StatusUnauthorized},
+		{"basic auth is not supported", RoleViewer, func(r *http.Request) { r.SetBasicAuth("ana", "correct horse") }, http.StatusUnauthorized},
+		{"session bearer", RoleStaff, func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }, http.StatusOK},
+		{"session cookie", RoleStaff, func(r *http.Request) { r.AddCookie(&http.Cookie{Name: adminSessionCookie, Value: token}) }, http.StatusOK},
+		{"session role too low", RoleAdmin, func(r *http.Request) { r.AddCookie(&http.Cookie{Name: adminSessionCookie, Value: token}) }, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen *AdminPrincipal
+			handler := srv.requireRole(tt.required, func(w http.ResponseWriter, r *http.Request) {
+				seen = requestAdmin(r)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/admin/kiosks", nil)
+			tt.setup(req)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && seen == nil {
+				t.Error("handler did not see the operator")
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestAdminLogin(t *testing.T) {
+	srv := setupAuthTest(t)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"username": "ana", "password": "correct horse"}`, http.StatusOK},
+		{"wrong password", `{"username": "ana", "password": "wrong"}`, http.StatusUnauthorized},
+		{"unknown user", `{"username": "bob", "password": "correct horse"}`, http.StatusUnauthorized},
+		{"invalid json", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.adminLogin(rec, httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			cookies := rec.Result().Cookies()
+			if tt.wantStatus != http.StatusOK {
+				if len(cookies) > 0 {
+					t.Error("failed login set a cookie")
+				}
+				return
+			}
+			if len(cookies) != 1 || cookies[0].Name != adminSessionCookie || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteStrictMode {
+				t.Fatalf("cookies = %+v, want one HttpOnly SameSite=Strict session cookie", cookies)
+			}
+			if _, err := srv.verifyAdminToken(cookies[0].Value, time.Now()); err != nil {
+				t.Errorf("session cookie does not verify: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a maintenance task run as "delfos <name> [args]" instead of starting the server
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"hash-password": {"read a password from stdin and print its hash for admin.users", runHashPassword},
+	"new-api-key":   {"print a new admin API key and the keyHash for admin.apiKeys", runNewAPIKey},
+	"openapi":       {"print the OpenAPI document, to refresh docs/openapi.json", runOpenAPI},
+	"verify-audit":  {"check the audit log hash chain (accepts --config and --data-dir)", runVerifyAudit},
+}
+
+// RunCommand runs the command named by the first argument
+// It returns false when the arguments are meant for the server (flags or nothing)
+func RunCommand(args []string) (bool, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return false, nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return true, fmt.Errorf("unknown command %q\n\n%s", args[0], commandUsage())
+	}
+	return true, cmd.run(args[1:], os.Stdin, os.Stdout)
+}
+
+// commandUsage lists the available commands
+func commandUsage() string {
+	var b strings.Builder
+	b.WriteString("Commands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-16s %s\n", name, commands[name].usage)
+	}
+	return b.String()
+}
+
+// runHashPassword prints the PBKDF2 hash of the password on the first line of stdin
+func runHashPassword(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("hash-password reads the password from stdin, not from arguments")
+	}
+	password, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, hash)
+	return nil
+}
+
+// runNewAPIKey prints a random API key and the hash to put in the config
+func runNewAPIKey(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("new-api-key takes no arguments")
+	}
+	key, err := randomHex(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate api key: %w", err)
+	}
+	fmt.Fprintf(stdout, "key:     %s\nkeyHash: %s\n", key, hashToken(key))
+	return nil
+}
@@ -0,0 +1,560 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"delfos/questions"
+)
+
+// Config is the server configuration
+// Values come from the defaults, then the config file, then DELFOS_* environment variables,
+// then command line flags; each source overrides the previous one
+type Config struct {
+	Addr      string          `json:"addr"`      // Listen address, e.g. ":8080"
+	DataDir   string          `json:"dataDir"`   // Sessions, results, prizes and kiosks
+	EventsDir string          `json:"eventsDir"` // Event definitions overriding the built-in ones
+	FlowsDir  string          `json:"flowsDir"`  // Conversation flows overriding the built-in ones
+	Server    ServerConfig    `json:"server"`
+	Quiz      QuizConfig      `json:"quiz"`
+	Limits    LimitsConfig    `json:"limits"`
+	Kiosks    KiosksConfig    `json:"kiosks"`
+	CORS      CORSConfig      `json:"cors"`
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	Admin     AdminConfig     `json:"admin"`
+	Log       LogConfig       `json:"log"`
+}
+
+// ServerConfig holds the HTTP server timeouts
+type ServerConfig struct {
+	ReadTimeout     Duration `json:"readTimeout"`     // Reading a whole request, body included
+	WriteTimeout    Duration `json:"writeTimeout"`    // Writing the response; WebSocket connections are exempt
+	IdleTimeout     Duration `json:"idleTimeout"`     // Keep-alive connections waiting for the next request
+	ShutdownTimeout Duration `json:"shutdownTimeout"` // Grace period for in-flight requests on SIGINT/SIGTERM
+}
+
+// Duration is a time.Duration written as "15s" or "2m" in the config file
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"15s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// QuizConfig holds the quiz settings
+type QuizConfig struct {
+	QuestionsPerQuiz int      `json:"questionsPerQuiz"`
+	PassScore        float64  `json:"passScore"`       // Minimum score percentage counted as passed
+	ReceiptSecret    string   `json:"receiptSecret"`   // HMAC key for evaluation receipts, random per start when empty
+	ReceiptTTL       Duration `json:"receiptTTL"`      // Time after the evaluation a receipt opens the prize routes, 0 never expires them
+	TimeBonusPoints  float64  `json:"timeBonusPoints"` // Bonus for an instant correct answer, 0 disables time bonuses
+	TimeBonusWindow  Duration `json:"timeBonusWindow"` // Response time from which a correct answer earns no bonus
+	SessionTTL       Duration `json:"sessionTTL"`      // Time from registration after which an unfinished session expires, 0 keeps them open
+}
+
+// LimitsConfig holds the limits applied to events that do not set their own
+type LimitsConfig struct {
+	MaxWinners int `json:"maxWinners"` // Physical prize winners per event, 0 means unlimited
+}
+
+// KiosksConfig holds the kiosk identity settings
+type KiosksConfig struct {
+	Required   bool   `json:"required"`   // Quiz routes refuse requests without X-Kiosk-Token
+	SyncSecret string `json:"syncSecret"` // HMAC key deriving the sync keys from the device tokens
+}
+
+// CORSConfig holds the cross-origin settings
+// Entries are exact origins or "https://*.example.com" for any subdomain
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowedOrigins"` // Kiosk facing routes, "*" allows any origin without credentials
+	AdminOrigins   []string `json:"adminOrigins"`   // /admin routes, empty allows same-origin and non-browser clients only
+}
+
+// RateLimitConfig holds the request rate limits
+type RateLimitConfig struct {
+	Keys           []string      `json:"keys"`           // Client key, first known of kiosk, session and ip
+	TrustedProxies []string      `json:"trustedProxies"` // Addresses or CIDR ranges whose X-Forwarded-For is trusted
+	Read           RateLimitRule `json:"read"`           // GET requests on public routes
+	Write          RateLimitRule `json:"write"`          // Other requests on public routes
+	Admin          RateLimitRule `json:"admin"`          // /admin routes
+}
+
+// RateLimitRule is a token bucket: burst requests at once, refilled at perMinute
+type RateLimitRule struct {
+	PerMinute float64 `json:"perMinute"` // 0 disables the limit
+	Burst     int     `json:"burst"`
+}
+
+// rule returns the limit of a route class
+func (c RateLimitConfig) rule(class string) RateLimitRule {
+	switch class {
+	case rateClassRead:
+		return c.Read
+	case rateClassAdmin:
+		return c.Admin
+	default:
+		return c.Write
+	}
+}
+
+// AdminConfig holds the operator accounts and sessions
+type AdminConfig struct {
+	Users         []AdminUser   `json:"users"`
+	APIKeys       []AdminAPIKey `json:"apiKeys"`
+	SessionSecret string        `json:"sessionSecret"` // HMAC key for session tokens, random per start when empty
+	SessionTTL    Duration      `json:"sessionTTL"`
+}
+
+// AdminUser is an operator who logs in with a password
+type AdminUser struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"passwordHash"` // From "delfos hash-password"
+	Role         Role   `json:"role"`
+}
+
+// AdminAPIKey is a key for scripts and dashboards, sent as "Authorization: Bearer <key>"
+type AdminAPIKey struct {
+	Name    string `json:"name"`
+	KeyHash string `json:"keyHash"` // SHA-256 hex of the key, from "delfos new-api-key"
+	Role    Role   `json:"role"`
+}
+
+// LogConfig holds the logging settings
+type LogConfig struct {
+	Level  string `json:"level"`  // debug, info, warn or error
+	Format string `json:"format"` // json or text
+}
+
+// configOption is a setting that can be overridden from the environment and the command line
+type configOption struct {
+	flag  string
+	env   string
+	usage string
+	get   func(cfg *Config) string
+	set   func(cfg *Config, value string) error
+}
+
+// DefaultConfig returns the configuration used when nothing overrides it
+func DefaultConfig() Config {
+	return Config{
+		Addr:      ":8080",
+		DataDir:   "data",
+		EventsDir: "events",
+		FlowsDir:  "flows",
+		Server: ServerConfig{
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			IdleTimeout:     Duration(2 * time.Minute),
+			ShutdownTimeout: Duration(20 * time.Second),
+		},
+		Quiz: QuizConfig{
+			QuestionsPerQuiz: 8,
+			PassScore:        75,
+			ReceiptTTL:       Duration(time.Hour),
+			TimeBonusWindow:  Duration(30 * time.Second),
+			SessionTTL:       Duration(30 * time.Minute),
+		},
+		Limits: LimitsConfig{
+			MaxWinners: 40,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:3000", "http://localhost:5173"},
+			AdminOrigins:   []string{},
+		},
+		RateLimit: RateLimitConfig{
+			Keys:           []string{"kiosk", "ip"},
+			TrustedProxies: []string{},
+			Read:           RateLimitRule{PerMinute: 300, Burst: 60},
+			Write:          RateLimitRule{PerMinute: 60, Burst: 20},
+			Admin:          RateLimitRule{PerMinute: 30, Burst: 10},
+		},
+		Admin: AdminConfig{
+			Users:      []AdminUser{},
+			APIKeys:    []AdminAPIKey{},
+			SessionTTL: Duration(12 * time.Hour),
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+// configOptions lists the settings that have an environment variable and a flag
+func configOptions() []configOption {
+	return []configOption{
+		{"addr", "DELFOS_ADDR", "listen address",
+			func(c *Config) string { return c.Addr },
+			func(c *Config, v string) error { c.Addr = v; return nil }},
+		{"data-dir", "DELFOS_DATA_DIR", "data directory",
+			func(c *Config) string { return c.DataDir },
+			func(c *Config, v string) error { c.DataDir = v; return nil }},
+		{"events-dir", "DELFOS_EVENTS_DIR", "directory with event definitions",
+			func(c *Config) string { return c.EventsDir },
+			func(c *Config, v string) error { c.EventsDir = v; return nil }},
+		{"flows-dir", "DELFOS_FLOWS_DIR", "directory with conversation flows",
+			func(c *Config) string { return c.FlowsDir },
+			func(c *Config, v string) error { c.FlowsDir = v; return nil }},
+		{"read-timeout", "DELFOS_READ_TIMEOUT", "time allowed to read a request",
+			func(c *Config) string { return time.Duration(c.Server.ReadTimeout).String() },
+			func(c *Config, v string) error { return setDuration(&c.Server.ReadTimeout, v) }},
+		{"write-timeout", "DELFOS_WRITE_TIMEOUT", "time allowed to write a response",
+			func(c *Config) string { return time.Duration(c.Server.WriteTimeout).String() },
+			func(c *Config, v string) error { return setDuration(&c.Server.WriteTimeout, v) }},
+		{"idle-timeout", "DELFOS_IDLE_TIMEOUT", "time an idle keep-alive connection stays open",
+			func(c *Config) string { return time.Duration(c.Server.IdleTimeout).String() },
+			func(c *Config, v string) error { return setDuration(&c.Server.IdleTimeout, v) }},
+		{"shutdown-timeout", "DELFOS_SHUTDOWN_TIMEOUT", "grace period for in-flight requests on shutdown",
+			func(c *Config) string { return time.Duration(c.Server.ShutdownTimeout).String() },
+			func(c *Config, v string) error { return setDuration(&c.Server.ShutdownTimeout, v) }},
+		{"questions-per-quiz", "DELFOS_QUESTIONS_PER_QUIZ", "questions drawn for each quiz",
+			func(c *Config) string { return strconv.Itoa(c.Quiz.QuestionsPerQuiz) },
+			func(c *Config, v string) (err error) { c.Quiz.QuestionsPerQuiz, err = strconv.Atoi(v); return err }},
+		{"pass-score", "DELFOS_PASS_SCORE", "minimum score percentage counted as passed",
+			func(c *Config) string { return strconv.FormatFloat(c.Quiz.PassScore, 'g', -1, 64) },
+			func(c *Config, v string) (err error) { c.Quiz.PassScore, err = strconv.ParseFloat(v, 64); return err }},
+		{"receipt-secret", "DELFOS_RECEIPT_SECRET", "HMAC key for evaluation receipts (at least 32 characters)",
+			func(c *Config) string { return redact(c.Quiz.ReceiptSecret) },
+			func(c *Config, v string) error { c.Quiz.ReceiptSecret = v; return nil }},
+		{"receipt-ttl", "DELFOS_RECEIPT_TTL", "time after the evaluation a receipt opens the prize routes (0 = never expire)",
+			func(c *Config) string { return time.Duration(c.Quiz.ReceiptTTL).String() },
+			func(c *Config, v string) error { return setDuration(&c.Quiz.ReceiptTTL, v) }},
+		{"time-bonus-points", "DELFOS_TIME_BONUS_POINTS", "bonus points for an instant correct answer (0 = no time bonus)",
+			func(c *Config) string { return strconv.FormatFloat(c.Quiz.TimeBonusPoints, 'g', -1, 64) },
+			func(c *Config, v string) (err error) {
+				c.Quiz.TimeBonusPoints, err = strconv.ParseFloat(v, 64)
+				return err
+			}},
+		{"time-bonus-window", "DELFOS_TIME_BONUS_WINDOW", "response time from which a correct answer earns no bonus",
+			func(c *Config) string { return time.Duration(c.Quiz.TimeBonusWindow).String() },
+			func(c *Config, v string) error { return setDuration(&c.Quiz.TimeBonusWindow, v) }},
+		{"session-ttl", "DELFOS_SESSION_TTL", "time from registration after which an unfinished session expires (0 = never)",
+			func(c *Config) string { return time.Duration(c.Quiz.SessionTTL).String() },
+			func(c *Config, v string) error { return setDuration(&c.Quiz.SessionTTL, v) }},
+		{"max-winners", "DELFOS_MAX_WINNERS", "winner cap for events that do not set one (0 = unlimited)",
+			func(c *Config) string { return strconv.Itoa(c.Limits.MaxWinners) },
+			func(c *Config, v string) (err error) { c.Limits.MaxWinners, err = strconv.Atoi(v); return err }},
+		{"kiosks-required", "DELFOS_KIOSKS_REQUIRED", "refuse quiz requests without X-Kiosk-Token (true or false)",
+			func(c *Config) string { return strconv.FormatBool(c.Kiosks.Required) },
+			func(c *Config, v string) (err error) { c.Kiosks.Required, err = strconv.ParseBool(v); return err }},
+		{"kiosk-sync-secret", "DELFOS_KIOSK_SYNC_SECRET", "HMAC key deriving kiosk sync keys (at least 32 characters)",
+			func(c *Config) string { return redact(c.Kiosks.SyncSecret) },
+			func(c *Config, v string) error { c.Kiosks.SyncSecret = v; return nil }},
+		{"cors-origins", "DELFOS_CORS_ORIGINS", "comma separated allowed origins (https://*.example.com matches subdomains, * allows any)",
+			func(c *Config) string { return strings.Join(c.CORS.AllowedOrigins, ",") },
+			func(c *Config, v string) error { c.CORS.AllowedOrigins = splitList(v); return nil }},
+		{"cors-admin-origins", "DELFOS_CORS_ADMIN_ORIGINS", "comma separated origins allowed on /admin routes",
+			func(c *Config) string { return strings.Join(c.CORS.AdminOrigins, ",") },
+			func(c *Config, v string) error { c.CORS.AdminOrigins = splitList(v); return nil }},
+		{"rate-limit-keys", "DELFOS_RATE_LIMIT_KEYS", "comma separated rate limit keys in order of preference: kiosk, session, ip",
+			func(c *Config) string { return strings.Join(c.RateLimit.Keys, ",") },
+			func(c *Config, v string) error { c.RateLimit.Keys = splitList(v); return nil }},
+		{"trusted-proxies", "DELFOS_TRUSTED_PROXIES", "comma separated proxy addresses or CIDR ranges whose X-Forwarded-For is trusted",
+			func(c *Config) string { return strings.Join(c.RateLimit.TrustedProxies, ",") },
+			func(c *Config, v string) error { c.RateLimit.TrustedProxies = splitList(v); return nil }},
+		{"admin-session-secret", "DELFOS_ADMIN_SESSION_SECRET", "HMAC key for operator session tokens (at least 32 characters)",
+			func(c *Config) string { return redact(c.Admin.SessionSecret) },
+			func(c *Config, v string) error { c.Admin.SessionSecret = v; return nil }},
+		{"admin-session-ttl", "DELFOS_ADMIN_SESSION_TTL", "lifetime of operator sessions",
+			func(c *Config) string { return time.Duration(c.Admin.SessionTTL).String() },
+			func(c *Config, v string) error { return setDuration(&c.Admin.SessionTTL, v) }},
+		{"log-level", "DELFOS_LOG_LEVEL", "log level: debug, info, warn or error",
+			func(c *Config) string { return c.Log.Level },
+			func(c *Config, v string) error { c.Log.Level = v; return nil }},
+		{"log-format", "DELFOS_LOG_FORMAT", "log format: json or text",
+			func(c *Config) string { return c.Log.Format },
+			func(c *Config, v string) error { c.Log.Format = v; return nil }},
+	}
+}
+
+// LoadConfig builds the configuration from the config file, the environment and the arguments
+// It returns printOnly when --print-config was given
+func LoadConfig(args []string, getenv func(string) string) (cfg Config, printOnly bool, err error) {
+	cfg = DefaultConfig()
+	options := configOptions()
+
+	flags := flag.NewFlagSet("delfos", flag.ContinueOnError)
+	configPath := flags.String("config", getenv("DELFOS_CONFIG"), "path to a JSON config file (default config.json when present)")
+	flags.BoolVar(&printOnly, "print-config", false, "print the effective configuration as JSON and exit")
+	values := map[string]*string{}
+	for _, option := range options {
+		usage := fmt.Sprintf("%s (env %s, default %q)", option.usage, option.env, option.get(&cfg))
+		values[option.flag] = flags.String(option.flag, "", usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, false, err
+	}
+	if flags.NArg() > 0 {
+		return cfg, false, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	// 1. Config file
+	path, required := *configPath, *configPath != ""
+	if !required {
+		path = "config.json"
+	}
+	if err := readConfigFile(path, &cfg); err != nil {
+		if required || !errors.Is(err, os.ErrNotExist) {
+			return cfg, false, err
+		}
+	}
+
+	// 2. Environment variables
+	for _, option := range options {
+		if value := getenv(option.env); value != "" {
+			if err := option.set(&cfg, value); err != nil {
+				return cfg, false, fmt.Errorf("invalid %s %q: %w", option.env, value, err)
+			}
+		}
+	}
+
+	// 3. Flags, only the ones given on the command line
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		value, ok := values[f.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		for _, option := range options {
+			if option.flag == f.Name {
+				if err := option.set(&cfg, *value); err != nil {
+					flagErr = fmt.Errorf("invalid --%s %q: %w", f.Name, *value, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, false, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, false, err
+	}
+	return cfg, printOnly, nil
+}
+
+// readConfigFile overlays the JSON config file at path onto cfg
+// Fields missing from the file keep their current value
+func readConfigFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var problems []string
+
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		problems = append(problems, fmt.Sprintf("addr %q must be host:port", c.Addr))
+	}
+	if c.DataDir == "" {
+		problems = append(problems, "dataDir must not be empty")
+	}
+	if c.EventsDir == "" {
+		problems = append(problems, "eventsDir must not be empty")
+	}
+	if c.FlowsDir == "" {
+		problems = append(problems, "flowsDir must not be empty")
+	}
+
+	timeouts := []struct {
+		name  string
+		value Duration
+	}{
+		{"server.readTimeout", c.Server.ReadTimeout},
+		{"server.writeTimeout", c.Server.WriteTimeout},
+		{"server.idleTimeout", c.Server.IdleTimeout},
+		{"server.shutdownTimeout", c.Server.ShutdownTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be positive", timeout.name))
+		}
+	}
+
+	if available := minQuestionsPerProfile(); c.Quiz.QuestionsPerQuiz < 1 || c.Quiz.QuestionsPerQuiz > available {
+		problems = append(problems, fmt.Sprintf("quiz.questionsPerQuiz must be between 1 and %d", available))
+	}
+	if c.Quiz.PassScore < 0 || c.Quiz.PassScore > 100 {
+		problems = append(problems, "quiz.passScore must be between 0 and 100")
+	}
+	if c.Quiz.TimeBonusPoints < 0 {
+		problems = append(problems, "quiz.timeBonusPoints must not be negative")
+	}
+	if c.Quiz.TimeBonusPoints > 0 && c.Quiz.TimeBonusWindow <= 0 {
+		problems = append(problems, "quiz.timeBonusWindow must be positive when quiz.timeBonusPoints is set")
+	}
+	if c.Quiz.ReceiptTTL < 0 {
+		problems = append(problems, "quiz.receiptTTL must not be negative")
+	}
+	if c.Quiz.SessionTTL < 0 {
+		problems = append(problems, "quiz.sessionTTL must not be negative")
+	}
+	if c.Limits.MaxWinners < 0 {
+		problems = append(problems, "limits.maxWinners must not be negative")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin != "*" && !validOriginPattern(origin) {
+			problems = append(problems, fmt.Sprintf("cors origin %q must look like https://host[:port] or https://*.host", origin))
+		}
+	}
+	for _, origin := range c.CORS.AdminOrigins {
+		if !validOriginPattern(origin) {
+			problems = append(problems, fmt.Sprintf("cors admin origin %q must look like https://host[:port] or https://*.host", origin))
+		}
+	}
+
+	if len(c.RateLimit.Keys) == 0 {
+		problems = append(problems, "rateLimit.keys must not be empty")
+	}
+	for _, key := range c.RateLimit.Keys {
+		if key != "kiosk" && key != "session" && key != "ip" {
+			problems = append(problems, fmt.Sprintf("rateLimit key %q must be kiosk, session or ip", key))
+		}
+	}
+	for _, proxy := range c.RateLimit.TrustedProxies {
+		if _, err := parseProxy(proxy); err != nil {
+			problems = append(problems, fmt.Sprintf("rateLimit trusted proxy %q must be an IP address or CIDR range", proxy))
+		}
+	}
+	for _, class := range []string{rateClassRead, rateClassWrite, rateClassAdmin} {
+		if rule := c.RateLimit.rule(class); rule.PerMinute < 0 || rule.Burst < 0 {
+			problems = append(problems, fmt.Sprintf("rateLimit.%s must not be negative", class))
+		}
+	}
+
+	if c.Quiz.ReceiptSecret != "" && len(c.Quiz.ReceiptSecret) < 32 {
+		problems = append(problems, "quiz.receiptSecret must be at least 32 characters")
+	}
+	if c.Admin.SessionSecret != "" && len(c.Admin.SessionSecret) < 32 {
+		problems = append(problems, "admin.sessionSecret must be at least 32 characters")
+	}
+	if c.Kiosks.SyncSecret != "" && len(c.Kiosks.SyncSecret) < 32 {
+		problems = append(problems, "kiosks.syncSecret must be at least 32 characters")
+	}
+	if c.Admin.SessionTTL <= 0 {
+		problems = append(problems, "admin.sessionTTL must be positive")
+	}
+	usernames := map[string]bool{}
+	for i, user := range c.Admin.Users {
+		if user.Username == "" || usernames[user.Username] {
+			problems = append(problems, fmt.Sprintf("admin.users[%d] needs a unique username", i))
+		}
+		usernames[user.Username] = true
+		if _, _, _, err := parsePasswordHash(user.PasswordHash); err != nil {
+			problems = append(problems, fmt.Sprintf("admin.users[%d]: %v", i, err))
+		}
+		if roleRank[user.Role] == 0 {
+			problems = append(problems, fmt.Sprintf("admin.users[%d] role %q must be viewer, staff or admin", i, user.Role))
+		}
+	}
+	for i, key := range c.Admin.APIKeys {
+		if key.Name == "" {
+			problems = append(problems, fmt.Sprintf("admin.apiKeys[%d] needs a name", i))
+		}
+		if decoded, err := hex.DecodeString(key.KeyHash); err != nil || len(decoded) != sha256.Size {
+			problems = append(problems, fmt.Sprintf("admin.apiKeys[%d] keyHash must be a SHA-256 hex digest", i))
+		}
+		if roleRank[key.Role] == 0 {
+			problems = append(problems, fmt.Sprintf("admin.apiKeys[%d] role %q must be viewer, staff or admin", i, key.Role))
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		problems = append(problems, fmt.Sprintf("log.format %q must be json or text", c.Log.Format))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// Print writes the configuration as indented JSON, without the session, receipt and sync secrets
+func (c Config) Print(w io.Writer) error {
+	c.Admin.SessionSecret = redact(c.Admin.SessionSecret)
+	c.Quiz.ReceiptSecret = redact(c.Quiz.ReceiptSecret)
+	c.Kiosks.SyncSecret = redact(c.Kiosks.SyncSecret)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(c)
+}
+
+// minQuestionsPerProfile returns the size of the smallest profile question bank
+func minQuestionsPerProfile() int {
+	smallest := 0
+	for _, profile := range []string{"1", "2", "3"} {
+		if count := questions.Count(profile); smallest == 0 || count < smallest {
+			smallest = count
+		}
+	}
+	return smallest
+}
+
+// redact hides a secret value in help and printed output
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "<redacted>"
+}
+
+// setDuration parses a duration such as "15s" into target
+func setDuration(target *Duration, value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*target = Duration(parsed)
+	return nil
+}
+
+// splitList splits a comma separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsPolicy is the cross-origin policy applied to a group of routes
+type corsPolicy struct {
+	origins []string // Exact origins, "https://*.example.com" patterns or "*"
+	methods string
+	headers string
+	strict  bool // Refuse requests from origins outside the list instead of only omitting the CORS headers
+}
+
+// publicCORSPolicy is the policy of the kiosk facing routes
+func (srv *Server) publicCORSPolicy() *corsPolicy {
+	return &corsPolicy{
+		origins: srv.config.CORS.AllowedOrigins,
+		methods: "GET, POST, OPTIONS",
+		headers: "Content-Type, Authorization, X-Requested-With, Accept, X-Event-ID, X-Kiosk-Token, X-Request-ID",
+	}
+}
+
+// adminCORSPolicy is the policy of the /admin routes: its own origin list, no "*",
+// and cross-origin requests from any other origin are refused before reaching the handler
+func (srv *Server) adminCORSPolicy() *corsPolicy {
+	return &corsPolicy{
+		origins: srv.config.CORS.AdminOrigins,
+		methods: "GET, POST, OPTIONS",
+		headers: "Content-Type, Authorization, X-Request-ID",
+		strict:  true,
+	}
+}
+
+// corsMiddleware handles cross-origin requests with the given policy
+// Matching origins are echoed back; credentials are allowed only for origins listed explicitly, never through "*"
+func corsMiddleware(policy *corsPolicy, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Origin header, caches must key on it
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		allowed, credentials := policy.match(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin != "" && allowed == "" && (policy.strict || preflight) {
+			slog.WarnContext(r.Context(), "cross-origin request refused", "origin", origin)
+			writeError(w, r, http.StatusForbidden, CodeOriginNotAllowed, "Origin not allowed", map[string]string{"origin": origin})
+			return
+		}
+
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+			if credentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		// Handle preflight OPTIONS request
+		if r.Method == http.MethodOptions {
+			if allowed != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", policy.methods)
+				w.Header().Set("Access-Control-Allow-Headers", policy.headers)
+				w.Header().Set("Access-Control-Max-Age", "3600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// match returns the Access-Control-Allow-Origin value for a request origin, or "" to refuse it,
+// and whether credentials may be sent with it
+func (p *corsPolicy) match(origin string) (allowed string, credentials bool) {
+	if origin == "" {
+		return "", false
+	}
+	wildcard := false
+	for _, pattern := range p.origins {
+		if pattern == "*" {
+			wildcard = true
+			continue
+		}
+		if originMatches(pattern, origin) {
+			return origin, true
+		}
+	}
+	if wildcard {
+		return "*", false
+	}
+	return "", false
+}
+
+// originMatches reports whether origin matches an allowlist entry
+// An entry is an exact origin or has a "*." host prefix matching any subdomain (not the bare domain)
+func originMatches(pattern, origin string) bool {
+	if strings.EqualFold(pattern, origin) {
+		return true
+	}
+	scheme, host, ok := strings.Cut(pattern, "://*.")
+	if !ok {
+		return false
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(parsed.Scheme, scheme) || parsed.Path != "" {
+		return false
+	}
+	// The port must match exactly, a pattern without a port only matches the default port
+	patternHost, patternPort, _ := strings.Cut(host, ":")
+	if !strings.EqualFold(parsed.Port(), patternPort) {
+		return false
+	}
+	hostname := strings.ToLower(parsed.Hostname())
+	suffix := "." + strings.ToLower(patternHost)
+	return strings.HasSuffix(hostname, suffix) && len(hostname) > len(suffix)
+}
+
+// validOriginPattern reports whether an allowlist entry is an origin, optionally with a "*." subdomain wildcard
+func validOriginPattern(pattern string) bool {
+	candidate := strings.Replace(pattern, "://*.", "://", 1)
+	parsed, err := url.Parse(candidate)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") &&
+		parsed.Host != "" && parsed.Path == "" && parsed.RawQuery == "" && parsed.User == nil &&
+		!strings.Contains(candidate, "*")
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setupCORSTest returns a server with the given allowlists over temporary directories
+func setupCORSTest(t *testing.T, origins, adminOrigins []string) *Server {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.CORS.AllowedOrigins = origins
+	cfg.CORS.AdminOrigins = adminOrigins
+	return newServer(t, cfg)
+}
+
+func serveCORS(handler http.HandlerFunc, method, path, origin, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+var preflightHeaders = map[string]string{
+	"Access-Control-Request-Method":  "POST",
+	"Access-Control-Request-Headers": "Content-Type",
+}
+
+// The checks cors-test.sh and cors-test-evaluate.sh run by hand against a live server
+func TestCORSScriptChecks(t *testing.T) {
+	srv := setupCORSTest(t, []string{"http://localhost:3000", "http://localhost:5173"}, nil)
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		path       string
+		origin     string
+		body       string
+		header     map[string]string
+		wantStatus int
+	}{
+		{"user create preflight", srv.createUser, http.MethodOptions, "/user/create", "http://localhost:3000", "", preflightHeaders, http.StatusNoContent},
+		{"user create post", srv.createUser, http.MethodPost, "/user/create", "http://localhost:3000",
+			`{"userEmail": "cors-test@example.com", "sessionId": "cors-test-session"}`,
+			map[string]string{"Content-Type": "application/json"}, http.StatusCreated},
+		{"evaluate preflight", srv.evaluateAnswers, http.MethodOptions, "/evaluate-answers", "http://localhost:5173", "", preflightHeaders, http.StatusNoContent},
+		{"evaluate post", srv.evaluateAnswers, http.MethodPost, "/evaluate-answers", "http://localhost:5173",
+			`{"questionIds": ["CRD0001", "CRD0002"], "userAnswers": ["a", "c"]}`,
+			map[string]string{"Content-Type": "application/json"}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveCORS(srv.withMiddleware(false, tt.handler), tt.method, tt.path, tt.origin, tt.body, tt.header)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+			}
+			if !headerContains(rec.Header(), "Vary", "Origin") {
+				t.Errorf("Vary = %q, want it to contain Origin", rec.Header().Values("Vary"))
+			}
+			if tt.method == http.MethodOptions {
+				if !strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "POST") {
+					t.Errorf("Access-Control-Allow-Methods = %q, want POST", rec.Header().Get("Access-Control-Allow-Methods"))
+				}
+				if !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Content-Type") {
+					t.Errorf("Access-Control-Allow-Headers = %q, want Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+				}
+			}
+		})
+	}
+}
+
+func TestCORSPublicPolicy(t *testing.T) {
+	tests := []struct {
+		name            string
+		origins         []string
+		method          string
+		origin          string
+		wantStatus      int
+		wantOrigin      string
+		wantCredentials bool
+	}{
+		{"exact origin", []string{"https://kiosk.example.com"}, http.MethodGet, "https://kiosk.example.com", http.StatusOK, "https://kiosk.example.com", true},
+		{"exact origin is case-insensitive", []string{"https://kiosk.example.com"}, http.MethodGet, "https://KIOSK.example.com", http.StatusOK, "https://KIOSK.example.com", true},
+		{"wildcard subdomain", []string{"https://*.example.com"}, http.MethodGet, "https://booth-3.example.com", http.StatusOK, "https://booth-3.example.com", true},
+		{"wildcard nested subdomain", []string{"https://*.example.com"}, http.MethodGet, "https://a.b.example.com", http.StatusOK, "https://a.b.example.com", true},
+		{"wildcard excludes bare domain", []string{"https://*.example.com"}, http.MethodGet, "https://example.com", http.StatusOK, "", false},
+		{"wildcard excludes lookalike domain", []string{"https://*.example.com"}, http.MethodGet, "https://evilexample.com", http.StatusOK, "", false},
+		{"wildcard excludes other scheme", []string{"https://*.example.com"}, http.MethodGet, "http://booth.example.com", http.StatusOK, "", false},
+		{"wildcard excludes other port", []string{"https://*.example.com"}, http.MethodGet, "https://booth.example.com:8443", http.StatusOK, "", false},
+		{"wildcard with port", []string{"https://*.example.com:8443"}, http.MethodGet, "https://booth.example.com:8443", http.StatusOK, "https://booth.example.com:8443", true},
+		{"unlisted origin gets no headers", []string{"https://kiosk.example.com"}, http.MethodGet, "https://evil.test", http.StatusOK, "", false},
+		{"unlisted origin preflight is refused", []string{"https://kiosk.example.com"}, http.MethodOptions, "https://evil.test", http.StatusForbidden, "", false},
+		{"star allows any origin without credentials", []string{"*"}, http.MethodGet, "https://anywhere.test", http.StatusOK, "*", false},
+		{"listed origin keeps credentials next to star", []string{"*", "https://kiosk.example.com"}, http.MethodGet, "https://kiosk.example.com", http.StatusOK, "https://kiosk.example.com", true},
+		{"no origin", []string{"https://kiosk.example.com"}, http.MethodGet, "", http.StatusOK, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupCORSTest(t, tt.origins, nil)
+
+			header := map[string]string{}
+			if tt.method == http.MethodOptions {
+				header = preflightHeaders
+			}
+			rec := serveCORS(srv.withMiddleware(false, srv.getWinnerCount), tt.method, "/winner/count", tt.origin, "", header)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Errorf("credentials allowed = %v, want %v", got, tt.wantCredentials)
+			}
+			if !headerContains(rec.Header(), "Vary", "Origin") {
+				t.Errorf("Vary = %q, want it to contain Origin", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSAdminPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		adminOrigins []string
+		method       string
+		origin       string
+		wantStatus   int
+		wantOrigin   string
+	}{
+		{"same-origin or curl", nil, http.MethodGet, "", http.StatusOK, ""},
+		{"public origin is refused", nil, http.MethodGet, "https://kiosk.example.com", http.StatusForbidden, ""},
+		{"public origin preflight is refused", nil, http.MethodOptions, "https://kiosk.example.com", http.StatusForbidden, ""},
+		{"admin origin", []string{"https://ops.example.com"}, http.MethodGet, "https://ops.example.com", http.StatusOK, "https://ops.example.com"},
+		{"admin origin preflight", []string{"https://ops.example.com"}, http.MethodOptions, "https://ops.example.com", http.StatusNoContent, "https://ops.example.com"},
+		{"admin wildcard subdomain", []string{"https://*.ops.example.com"}, http.MethodGet, "https://eu.ops.example.com", http.StatusOK, "https://eu.ops.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The public list allows everything, the admin routes must not inherit it
+			srv := setupCORSTest(t, []string{"*", "https://kiosk.example.com"}, tt.adminOrigins)
+			srv.config.Admin.APIKeys = []AdminAPIKey{{Name: "dashboard", KeyHash: hashToken("test-key"), Role: RoleViewer}}
+
+			// Browsers send preflights without credentials
+			header := map[string]string{"X-API-Key": "test-key"}
+			if tt.method == http.MethodOptions {
+				header = preflightHeaders
+			}
+			rec := serveCORS(srv.withAdminMiddleware(RoleViewer, srv.listKiosks), tt.method, "/admin/kiosks", tt.origin, "", header)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if !headerContains(rec.Header(), "Vary", "Origin") {
+				t.Errorf("Vary = %q, want it to contain Origin", rec.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCORSConfigValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		admin   []string
+		wantErr bool
+	}{
+		{"defaults", DefaultConfig().CORS.AllowedOrigins, nil, false},
+		{"wildcard subdomain", []string{"https://*.example.com"}, []string{"https://*.ops.example.com"}, false},
+		{"star", []string{"*"}, nil, false},
+		{"star on admin", nil, []string{"*"}, true},
+		{"path", []string{"https://example.com/app"}, nil, true},
+		{"wildcard in the middle", []string{"https://kiosk.*.example.com"}, nil, true},
+		{"no scheme", []string{"example.com"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.CORS.AllowedOrigins = tt.origins
+			cfg.CORS.AdminOrigins = tt.admin
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
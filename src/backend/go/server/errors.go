@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// apiPrefix is the versioned path every route is served under; the unversioned paths are legacy aliases
+const apiPrefix = "/api/v1"
+
+// Machine readable error codes; the event schedule codes (EVENT_ENDED, ...) are in schedule.go
+const (
+	CodeInvalidJSON         = "INVALID_JSON"
+	CodeMissingFields       = "MISSING_FIELDS"
+	CodeInvalidParameter    = "INVALID_PARAMETER"
+	CodeInvalidInput        = "INVALID_INPUT"
+	CodeInvalidAnswers      = "INVALID_ANSWERS"
+	CodeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	CodeRouteNotFound       = "ROUTE_NOT_FOUND"
+	CodeQuestionNotFound    = "QUESTION_NOT_FOUND"
+	CodeAnswerNotFound      = "ANSWER_NOT_FOUND"
+	CodeEventNotFound       = "EVENT_NOT_FOUND"
+	CodeUserNotFound        = "USER_NOT_FOUND"
+	CodeKioskNotFound       = "KIOSK_NOT_FOUND"
+	CodeSessionClosed       = "SESSION_CLOSED"
+	CodeSessionExists       = "SESSION_EXISTS"
+	CodeQuestionSetMismatch = "QUESTION_SET_MISMATCH"
+	CodeAnswerConflict      = "ANSWER_CONFLICT"
+	CodeSessionIncomplete   = "SESSION_INCOMPLETE"
+	CodeSessionExpired      = "SESSION_EXPIRED"
+	CodeInvalidSessionToken = "INVALID_SESSION_TOKEN"
+	CodeInvalidReceipt      = "INVALID_RECEIPT"
+	CodeProfileNotEnabled   = "PROFILE_NOT_ENABLED"
+	CodeAttemptLimit        = "ATTEMPT_LIMIT_REACHED"
+	CodeWinnerLimit         = "WINNER_LIMIT_REACHED"
+	CodePrizeNotFound       = "PRIZE_NOT_FOUND"
+	CodePrizeRedeemed       = "PRIZE_ALREADY_REDEEMED"
+	CodeTooManyRecords      = "TOO_MANY_RECORDS"
+	CodeAuthRequired        = "AUTHENTICATION_REQUIRED"
+	CodeInvalidCredentials  = "INVALID_CREDENTIALS"
+	CodeForbidden           = "FORBIDDEN"
+	CodeKioskTokenRequired  = "KIOSK_TOKEN_REQUIRED"
+	CodeUnknownKiosk        = "UNKNOWN_KIOSK"
+	CodeKioskDisabled       = "KIOSK_DISABLED"
+	CodeOriginNotAllowed    = "ORIGIN_NOT_ALLOWED"
+	CodeRateLimited         = "RATE_LIMITED"
+	CodeUpgradeRequired     = "WEBSOCKET_UPGRADE_REQUIRED"
+	CodeInternal            = "INTERNAL_ERROR"
+)
+
+// ErrorResponse is the body of every error response
+// Code is stable and meant for programs, Message is for people and may change; Details is null or an
+// object whose fields depend on the code, and RequestID matches the X-Request-ID header and the logs
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details"`
+	RequestID string `json:"requestId"`
+}
+
+// writeError writes an error envelope with the given status
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string, details any) {
+	response := ErrorResponse{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestID(r.Context()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeInternalError writes the 500 response; the cause is logged by the caller, never sent to the client
+func writeInternalError(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusInternalServerError, CodeInternal, "Internal server error", nil)
+}
+
+// writeInvalidJSON writes the 400 response for a request body that does not decode
+func writeInvalidJSON(w http.ResponseWriter, r *http.Request, err error) {
+	writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "Invalid JSON body", map[string]string{"reason": err.Error()})
+}
+
+// allowMethods reports whether the request uses one of the given methods
+// Otherwise it writes a 405 with the Allow header; preflight OPTIONS requests never get here, corsMiddleware answers them
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed",
+		map[string][]string{"allowed": methods})
+	return false
+}
+
+// missingFields returns the names of the required fields that are empty, given as name, value pairs
+func missingFields(namesAndValues ...string) []string {
+	var missing []string
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		if namesAndValues[i+1] == "" {
+			missing = append(missing, namesAndValues[i])
+		}
+	}
+	return missing
+}
+
+// writeMissingFields writes the 400 response listing the required fields a request left empty
+func writeMissingFields(w http.ResponseWriter, r *http.Request, missing []string) {
+	verb := " is required"
+	if len(missing) > 1 {
+		verb = " are required"
+	}
+	writeError(w, r, http.StatusBadRequest, CodeMissingFields, strings.Join(missing, " and ")+verb,
+		map[string][]string{"fields": missing})
+}
+
+// notFound handles paths that match no route
+func notFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, CodeRouteNotFound, "No route for "+r.URL.Path, nil)
+}
+
+// routePath returns the route pattern that matched the request without the version prefix, so both
+// paths of a route share one name and unknown paths all count as "/"
+func routePath(r *http.Request) string {
+	path := r.Pattern
+	if path == "" {
+		path = r.URL.Path
+	}
+	if trimmed, ok := strings.CutPrefix(path, apiPrefix); ok && strings.HasPrefix(trimmed, "/") {
+		return trimmed
+	}
+	return path
+}
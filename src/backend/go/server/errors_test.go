@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRouteServer returns a server over temporary directories with no rate limits
+// and a viewer API key "viewer-key"
+func newRouteServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.Admin.APIKeys = []AdminAPIKey{{Name: "dashboard", KeyHash: hashToken("viewer-key"), Role: RoleViewer}}
+	cfg.RateLimit.Read, cfg.RateLimit.Write, cfg.RateLimit.Admin = RateLimitRule{}, RateLimitRule{}, RateLimitRule{}
+	return newServer(t, cfg)
+}
+
+func TestVersionedRoutesAndLegacyAliases(t *testing.T) {
+	srv := newRouteServer(t)
+
+	for _, rt := range routes {
+		if rt.path == "/ws" {
+			continue // Needs a hijackable connection
+		}
+		for _, path := range []string{apiPrefix + rt.path, rt.path} {
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, path, nil))
+
+			// Every route exists at both paths and rejects PUT before doing anything else, or asks for credentials
+			switch rec.Code {
+			case http.StatusMethodNotAllowed, http.StatusUnauthorized:
+			default:
+				t.Errorf("PUT %s: status = %d, want 405 or 401", path, rec.Code)
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiPrefix+"/question?id=CRD0001", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id":"CRD0001"`) {
+		t.Errorf("GET %s/question: status = %d, body %q", apiPrefix, rec.Code, rec.Body.String())
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		header      map[string]string
+		wantStatus  int
+		wantCode    string
+		wantAllow   string
+		wantDetails string // Substring of the encoded details
+	}{
+		{"wrong method", http.MethodGet, apiPrefix + "/user/create", "", nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "POST", `{"allowed":["POST"]}`},
+		{"wrong method on legacy path", http.MethodPost, "/winner/count", "", nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "GET", `{"allowed":["GET"]}`},
+		{"unknown route", http.MethodGet, apiPrefix + "/nope", "", nil, http.StatusNotFound, CodeRouteNotFound, "", "null"},
+		{"unknown admin route", http.MethodGet, "/admin/nope", "", nil, http.StatusNotFound, CodeRouteNotFound, "", "null"},
+		{"invalid json", http.MethodPost, apiPrefix + "/user/create", "{", nil, http.StatusBadRequest, CodeInvalidJSON, "", `"reason"`},
+		{"missing fields", http.MethodPost, apiPrefix + "/user/create", `{"userEmail": "a@example.com"}`, nil, http.StatusBadRequest, CodeMissingFields, "", `{"fields":["sessionId"]}`},
+		{"missing answers", http.MethodPost, apiPrefix + "/evaluate-answers", `{}`, nil, http.StatusBadRequest, CodeMissingFields, "", `{"fields":["questionIds","userAnswers"]}`},
+		{"question not found", http.MethodGet, apiPrefix + "/question?id=XYZ0001", "", nil, http.StatusNotFound, CodeQuestionNotFound, "", `{"id":"XYZ0001"}`},
+		{"answer not found", http.MethodGet, "/answer?question_id=XYZ0001", "", nil, http.StatusNotFound, CodeAnswerNotFound, "", `{"question_id":"XYZ0001"}`},
+		{"event not found", http.MethodGet, apiPrefix + "/event/status?event=nope", "", nil, http.StatusNotFound, CodeEventNotFound, "", `{"event":"nope"}`},
+		{"profile not enabled", http.MethodGet, apiPrefix + "/choose-questions?profile=9", "", nil, http.StatusBadRequest, CodeProfileNotEnabled, "", `"profile":"9"`},
+		{"user not found", http.MethodPost, apiPrefix + "/user/update", `{"userEmail": "a@example.com", "sessionId": "s-1"}`, nil, http.StatusNotFound, CodeUserNotFound, "", "null"},
+		{"unknown kiosk", http.MethodGet, apiPrefix + "/winner/count", "", map[string]string{"X-Kiosk-Token": "nope"}, http.StatusUnauthorized, CodeUnknownKiosk, "", "null"},
+		{"kiosk token required", http.MethodPost, apiPrefix + "/sync", `{}`, nil, http.StatusUnauthorized, CodeKioskTokenRequired, "", "null"},
+		{"origin not allowed", http.MethodOptions, apiPrefix + "/user/create", "", map[string]string{"Origin": "https://evil.test", "Access-Control-Request-Method": "POST"}, http.StatusForbidden, CodeOriginNotAllowed, "", `{"origin":"https://evil.test"}`},
+		{"authentication required", http.MethodGet, apiPrefix + "/admin/kiosks", "", nil, http.StatusUnauthorized, CodeAuthRequired, "", "null"},
+		{"role too low", http.MethodGet, apiPrefix + "/admin/audit", "", map[string]string{"X-API-Key": "viewer-key"}, http.StatusForbidden, CodeForbidden, "", `"requiredRole":"staff"`},
+		{"invalid credentials", http.MethodPost, apiPrefix + "/admin/login", `{"username": "ana", "password": "wrong"}`, nil, http.StatusUnauthorized, CodeInvalidCredentials, "", "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newRouteServer(t)
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("X-Request-ID", "req-42")
+			for name, value := range tt.header {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+
+			var envelope struct {
+				Code      string          `json:"code"`
+				Message   string          `json:"message"`
+				Details   json.RawMessage `json:"details"`
+				RequestID string          `json:"requestId"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("body is not a JSON envelope: %v (%q)", err, rec.Body.String())
+			}
+			if envelope.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", envelope.Code, tt.wantCode)
+			}
+			if envelope.Message == "" {
+				t.Error("message is empty")
+			}
+			if !strings.Contains(string(envelope.Details), tt.wantDetails) {
+				t.Errorf("details = %s, want it to contain %s", envelope.Details, tt.wantDetails)
+			}
+			if envelope.RequestID != "req-42" {
+				t.Errorf("requestId = %q, want the X-Request-ID req-42", envelope.RequestID)
+			}
+		})
+	}
+}
+
+func TestRateLimitedEnvelope(t *testing.T) {
+	srv := newRouteServer(t)
+	srv.config.RateLimit.Read = RateLimitRule{PerMinute: 1, Burst: 1}
+
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiPrefix+"/question?id=CRD0001", nil))
+	}
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	var envelope ErrorResponse
+	json.Unmarshal(rec.Body.Bytes(), &envelope)
+	if envelope.Code != CodeRateLimited {
+		t.Errorf("code = %q, want %q", envelope.Code, CodeRateLimited)
+	}
+	if details, _ := envelope.Details.(map[string]any); details["retryAfterSeconds"] != float64(60) {
+		t.Errorf("details = %v, want retryAfterSeconds 60", envelope.Details)
+	}
+}
+
+func TestAdminCookiePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{apiPrefix + "/admin/login", apiPrefix + "/admin"},
+		{"/admin/login", "/admin"},
+		{"/admin/logout", "/admin"},
+	}
+	for _, tt := range tests {
+		if got := adminCookiePath(httptest.NewRequest(http.MethodPost, tt.path, nil)); got != tt.want {
+			t.Errorf("adminCookiePath(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
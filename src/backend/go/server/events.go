@@ -0,0 +1,447 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"delfos/api"
+	"delfos/prizes"
+	"delfos/storage"
+)
+
+// Built-in event definitions, used when no override exists in the events directory
+//
+//go:embed events/*.json
+var builtinEvents embed.FS
+
+// AttemptRules represents the participation limits of an event
+type AttemptRules struct {
+	MaxAttemptsPerEmail int `json:"maxAttemptsPerEmail,omitempty"` // 0 means unlimited
+	MaxWinners          int `json:"maxWinners,omitempty"`          // 0 uses the server's limits.maxWinners, -1 means unlimited
+}
+
+// Event represents a fair or booth where the quiz runs with its own data, limits and prizes
+type Event struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	StartsAt    time.Time      `json:"startsAt,omitzero"`
+	EndsAt      time.Time      `json:"endsAt,omitzero"`
+	Timezone    string         `json:"timezone,omitempty"`   // IANA zone of the opening hours, defaults to the server zone
+	Hours       *OpeningHours  `json:"hours,omitempty"`      // Daily registration hours, always open when empty
+	PrizeCutoff time.Time      `json:"prizeCutoff,omitzero"` // No physical prizes are awarded after this time
+	Profiles    []string       `json:"profiles"`             // Enabled question profiles ("1" CRD, "2" SRV, "3" EXP)
+	Flow        string         `json:"flow,omitempty"`       // Conversation flow used by /process, defaults to the booth flow
+	Attempts    AttemptRules   `json:"attempts"`
+	Prizes      []prizes.Prize `json:"prizes"`
+
+	location *time.Location
+	openAt   time.Duration
+	closeAt  time.Duration
+	dataDir  string
+}
+
+// EventListResponse represents the response for listing events
+type EventListResponse struct {
+	Status string   `json:"status"`
+	Events []*Event `json:"events"`
+}
+
+// RedeemPrizeRequest represents the request body for handing over a session's prize
+type RedeemPrizeRequest struct {
+	UserEmail string `json:"userEmail"`
+	SessionID string `json:"sessionId"`
+}
+
+// RedeemPrizeResponse represents the response for handing over a session's prize
+type RedeemPrizeResponse struct {
+	Status     string       `json:"status"`
+	Message    string       `json:"message"`
+	Event      string       `json:"event"`
+	Prize      prizes.Prize `json:"prize"`
+	AwardedAt  string       `json:"awardedAt"`
+	RedeemedAt string       `json:"redeemedAt"`
+	RedeemedBy string       `json:"redeemedBy"`
+}
+
+const defaultEventID = "default"
+
+// loadEvents loads the built-in events and then any overrides found in dir
+func (srv *Server) loadEvents(dir string) error {
+	loaded := map[string]*Event{}
+
+	entries, err := builtinEvents.ReadDir("events")
+	if err != nil {
+		return fmt.Errorf("failed to read built-in events: %w", err)
+	}
+	for _, entry := range entries {
+		content, err := builtinEvents.ReadFile("events/" + entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read built-in event %s: %w", entry.Name(), err)
+		}
+		event, err := srv.parseEvent(content)
+		if err != nil {
+			return fmt.Errorf("invalid built-in event %s: %w", entry.Name(), err)
+		}
+		loaded[event.ID] = event
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list events directory: %w", err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read event file %s: %w", path, err)
+		}
+		event, err := srv.parseEvent(content)
+		if err != nil {
+			return fmt.Errorf("invalid event file %s: %w", path, err)
+		}
+		loaded[event.ID] = event
+	}
+
+	if _, ok := loaded[defaultEventID]; !ok {
+		return fmt.Errorf("default event %q is not defined", defaultEventID)
+	}
+
+	srv.eventsMu.Lock()
+	srv.events = loaded
+	srv.eventsMu.Unlock()
+
+	for id, event := range loaded {
+		slog.Info("loaded event", "event", id, "name", event.Name, "profiles", event.Profiles)
+	}
+	return nil
+}
+
+// parseEvent decodes an event definition and validates it
+func (srv *Server) parseEvent(content []byte) (*Event, error) {
+	var event Event
+	if err := json.Unmarshal(content, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	if event.ID == "" {
+		return nil, fmt.Errorf("event id is required")
+	}
+	if strings.ContainsAny(event.ID, `/\.`) {
+		return nil, fmt.Errorf("event id %q must not contain path characters", event.ID)
+	}
+	if !event.StartsAt.IsZero() && !event.EndsAt.IsZero() && !event.EndsAt.After(event.StartsAt) {
+		return nil, fmt.Errorf("event %q ends before it starts", event.ID)
+	}
+	if err := event.prepareSchedule(); err != nil {
+		return nil, err
+	}
+	if len(event.Profiles) == 0 {
+		return nil, fmt.Errorf("event %q has no enabled profiles", event.ID)
+	}
+	if event.Flow == "" {
+		event.Flow = defaultFlowID
+	}
+	event.dataDir = filepath.Join(srv.config.DataDir, "events", event.ID)
+	if event.ID == defaultEventID {
+		event.dataDir = srv.config.DataDir
+	}
+
+	// Events without their own winner cap use the server-wide one
+	switch {
+	case event.Attempts.MaxWinners == 0:
+		event.Attempts.MaxWinners = srv.config.Limits.MaxWinners
+	case event.Attempts.MaxWinners < 0:
+		event.Attempts.MaxWinners = 0
+	}
+	for _, prize := range event.Prizes {
+		if prize.SKU == "" || prize.Weight <= 0 {
+			return nil, fmt.Errorf("event %q has a prize without sku or positive weight", event.ID)
+		}
+	}
+
+	return &event, nil
+}
+
+// getEvent returns a loaded event by ID
+func (srv *Server) getEvent(id string) (*Event, bool) {
+	srv.eventsMu.RLock()
+	defer srv.eventsMu.RUnlock()
+	event, ok := srv.events[id]
+	return event, ok
+}
+
+// requestEvent resolves the event for a request from the "event" query parameter, the
+// X-Event-ID header or the registered kiosk's event, falling back to the default event
+// It writes a 404 response and returns false when the event does not exist
+func (srv *Server) requestEvent(w http.ResponseWriter, r *http.Request) (*Event, bool) {
+	id := r.URL.Query().Get("event")
+	if id == "" {
+		id = r.Header.Get("X-Event-ID")
+	}
+	if kiosk := requestKiosk(r); id == "" && kiosk != nil {
+		id = kiosk.Event
+	}
+	if id == "" {
+		id = defaultEventID
+	}
+
+	event, ok := srv.getEvent(id)
+	if !ok {
+		slog.WarnContext(r.Context(), "unknown event requested", "event", id)
+		writeError(w, r, http.StatusNotFound, CodeEventNotFound, "Event not found", map[string]string{"event": id})
+		return nil, false
+	}
+	addLogFields(r.Context(), "event", event.ID)
+	return event, true
+}
+
+// DataDir returns the directory holding the event's sessions, results and prizes
+// The default event keeps using the legacy data directory
+func (e *Event) DataDir() string {
+	return e.dataDir
+}
+
+// ProfileEnabled reports whether a question profile is enabled for the event
+func (e *Event) ProfileEnabled(profile string) bool {
+	for _, p := range e.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// listEvents handles listing the configured events
+// It expects a GET request and returns the public event definitions
+func (srv *Server) listEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	srv.eventsMu.RLock()
+	list := make([]*Event, 0, len(srv.events))
+	for _, event := range srv.events {
+		list = append(list, event)
+	}
+	srv.eventsMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(EventListResponse{
+		Status: "success",
+		Events: list,
+	})
+}
+
+// drawPrize handles drawing a prize from the event's prize table
+// It expects a POST request with JSON body containing userEmail, sessionId and the evaluation receipt
+func (srv *Server) drawPrize(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.DrawPrizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid prize draw request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	logSession(r.Context(), req.UserEmail, req.SessionID)
+	if !srv.requireReceipt(w, r, event, req.UserEmail, req.SessionID, req.Receipt) {
+		return
+	}
+
+	response, err := srv.awardPrize(r.Context(), event, req.UserEmail, req.SessionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to draw prize", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// awardPrize draws and records a prize for a session
+// A session that already has a prize gets the same award back instead of a new draw
+func (srv *Server) awardPrize(ctx context.Context, event *Event, userEmail, sessionID string) (response api.DrawPrizeResponse, err error) {
+	ctx, span := startSpan(ctx, "prize.draw", "event.id", event.ID)
+	defer func() {
+		span.SetAttributes("prize.sku", response.Prize.SKU)
+		span.SetError(err)
+		span.End()
+	}()
+
+	srv.winnerMu.Lock()
+	defer srv.winnerMu.Unlock()
+
+	dataDir := event.DataDir()
+	awards, err := prizes.ReadAwards(ctx, dataDir)
+	if err != nil {
+		return api.DrawPrizeResponse{}, err
+	}
+	winnerCount, err := storage.ReadWinnerCount(ctx, dataDir)
+	if err != nil {
+		return api.DrawPrizeResponse{}, err
+	}
+
+	if award, ok := prizes.ForSession(awards, userEmail, sessionID); ok {
+		prize, _ := prizes.Find(event.Prizes, award.SKU)
+		return api.DrawPrizeResponse{
+			Status:      "success",
+			Message:     "Prize already awarded for this session",
+			Event:       event.ID,
+			Prize:       prize,
+			WinnerCount: winnerCount,
+			AwardedAt:   award.AwardedAt,
+		}, nil
+	}
+
+	// Physical prizes stop at the winner cap and after the prize cutoff
+	capReached := event.Attempts.MaxWinners > 0 && winnerCount >= event.Attempts.MaxWinners
+	prize, ok := prizes.Draw(event.Prizes, awards, capReached || !event.PrizesOpen(time.Now()))
+	if !ok {
+		return api.DrawPrizeResponse{}, fmt.Errorf("event %s has no prizes left to draw", event.ID)
+	}
+
+	awardedAt := time.Now().Format(time.RFC3339)
+	award := prizes.Award{AwardedAt: awardedAt, SKU: prize.SKU, UserEmail: userEmail, SessionID: sessionID}
+	winnerCount, err = srv.recordAwardLocked(ctx, event, prize, award, winnerCount)
+	if err != nil {
+		return api.DrawPrizeResponse{}, err
+	}
+
+	return api.DrawPrizeResponse{
+		Status:      "success",
+		Message:     "Prize drawn successfully",
+		Event:       event.ID,
+		Prize:       prize,
+		WinnerCount: winnerCount,
+		AwardedAt:   awardedAt,
+	}, nil
+}
+
+// redeemPrize handles the staff handing over the prize awarded to a session
+// It expects a POST request with JSON body containing userEmail and sessionId; each award is
+// redeemed once
+func (srv *Server) redeemPrize(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req RedeemPrizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid prize redemption request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+	logSession(r.Context(), req.UserEmail, req.SessionID)
+	if missing := missingFields("userEmail", req.UserEmail, "sessionId", req.SessionID); len(missing) > 0 {
+		writeMissingFields(w, r, missing)
+		return
+	}
+
+	// Held like for draws, so two redemptions of the same award cannot both succeed
+	srv.winnerMu.Lock()
+	defer srv.winnerMu.Unlock()
+
+	dataDir := event.DataDir()
+	awards, err := prizes.ReadAwards(r.Context(), dataDir)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read prizes", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	award, ok := prizes.ForSession(awards, req.UserEmail, req.SessionID)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, CodePrizeNotFound, "No prize was awarded to this session", nil)
+		return
+	}
+	redemptions, err := prizes.ReadRedemptions(r.Context(), dataDir)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read redemptions", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	for _, redemption := range redemptions {
+		if redemption.UserEmail == req.UserEmail && redemption.SessionID == req.SessionID {
+			writeError(w, r, http.StatusConflict, CodePrizeRedeemed, "Prize was already handed over",
+				map[string]string{"redeemedAt": redemption.RedeemedAt, "redeemedBy": redemption.RedeemedBy})
+			return
+		}
+	}
+
+	redemption := prizes.Redemption{
+		RedeemedAt: time.Now().Format(time.RFC3339),
+		SKU:        award.SKU,
+		UserEmail:  req.UserEmail,
+		SessionID:  req.SessionID,
+		RedeemedBy: requestAdmin(r).Name,
+	}
+	if err := prizes.AppendRedemption(r.Context(), dataDir, redemption); err != nil {
+		slog.ErrorContext(r.Context(), "failed to record redemption", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	srv.recordAudit(r.Context(), event, AuditPrizeRedeem, req.SessionID, nil,
+		map[string]string{"sku": award.SKU, "redeemedAt": redemption.RedeemedAt})
+	slog.InfoContext(r.Context(), "prize redeemed", "sku", award.SKU)
+
+	prize, _ := prizes.Find(event.Prizes, award.SKU)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RedeemPrizeResponse{
+		Status:     "success",
+		Message:    "Prize redeemed",
+		Event:      event.ID,
+		Prize:      prize,
+		AwardedAt:  award.AwardedAt,
+		RedeemedAt: redemption.RedeemedAt,
+		RedeemedBy: redemption.RedeemedBy,
+	})
+}
+
+// recordAwardLocked appends a prize award and bumps the winner count for physical prizes
+// It must be called with winnerMu held and returns the updated winner count
+func (srv *Server) recordAwardLocked(ctx context.Context, event *Event, prize prizes.Prize, award prizes.Award, winnerCount int) (int, error) {
+	dataDir := event.DataDir()
+	if err := prizes.AppendAward(ctx, dataDir, award); err != nil {
+		return winnerCount, err
+	}
+	prizesAwarded.Inc(event.ID, prize.SKU)
+
+	// Physical prizes count as winners
+	before := winnerCount
+	if prize.Physical() {
+		winnerCount++
+		if err := storage.WriteWinnerCount(ctx, dataDir, winnerCount); err != nil {
+			return winnerCount, err
+		}
+		slog.InfoContext(ctx, "winner recorded", "winner_count", winnerCount, "sku", prize.SKU)
+	} else {
+		slog.InfoContext(ctx, "prize drawn", "sku", prize.SKU)
+	}
+	srv.recordAudit(ctx, event, AuditPrizeAward, award.SessionID,
+		map[string]int{"winnerCount": before},
+		map[string]any{"sku": award.SKU, "awardedAt": award.AwardedAt, "winnerCount": winnerCount})
+	return winnerCount, nil
+}
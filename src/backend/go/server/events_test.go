@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"delfos/api"
+	"delfos/prizes"
+)
+
+func TestListEvents(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"listed", http.MethodGet, apiPrefix + "/events", nil, nil, http.StatusOK, ""},
+		{"wrong method", http.MethodPost, apiPrefix + "/events", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	s := newTestServer(t)
+	list := decodeBody[EventListResponse](t, s.do(http.MethodGet, apiPrefix+"/events", nil, nil))
+	got := map[string]*Event{}
+	for _, event := range list.Events {
+		got[event.ID] = event
+	}
+	for _, id := range []string{"default", "fair", "ended", "upcoming"} {
+		if got[id] == nil {
+			t.Errorf("events = %v, want %s", got, id)
+		}
+	}
+	if fair := got["fair"]; fair != nil && (fair.Attempts.MaxWinners != 3 || len(fair.Prizes) != 2) {
+		t.Errorf("fair = %+v", fair)
+	}
+}
+
+func TestDrawPrize(t *testing.T) {
+	draw := func(event string) api.DrawPrizeRequest {
+		return api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: testReceipt(t, event, "a@example.com", "s-1", true)}
+	}
+	runHandlerCases(t, []handlerCase{
+		{"drawn", http.MethodPost, apiPrefix + "/prize/draw", draw("default"), nil, http.StatusOK, ""},
+		{"drawn in event", http.MethodPost, apiPrefix + "/prize/draw?event=fair", draw("fair"), nil, http.StatusOK, ""},
+		{"malformed json", http.MethodPost, apiPrefix + "/prize/draw", `{"userEmail": "a@example.com",}`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"missing session", http.MethodPost, apiPrefix + "/prize/draw", `{"userEmail": "a@example.com"}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"missing receipt", http.MethodPost, apiPrefix + "/prize/draw", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1"}, nil, http.StatusBadRequest, CodeMissingFields},
+		{"tampered receipt", http.MethodPost, apiPrefix + "/prize/draw", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1",
+			Receipt: "x" + draw("default").Receipt[1:]}, nil, http.StatusForbidden, CodeInvalidReceipt},
+		{"another event's receipt", http.MethodPost, apiPrefix + "/prize/draw?event=fair", draw("default"), nil, http.StatusForbidden, CodeInvalidReceipt},
+		{"wrong method", http.MethodGet, apiPrefix + "/prize/draw", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"unknown event", http.MethodPost, apiPrefix + "/prize/draw?event=nope", draw("nope"), nil, http.StatusNotFound, CodeEventNotFound},
+		{"empty prize table", http.MethodPost, apiPrefix + "/prize/draw?event=ended", draw("ended"), nil, http.StatusInternalServerError, CodeInternal},
+	}, nil)
+
+	s := newTestServer(t)
+	first := decodeBody[api.DrawPrizeResponse](t, s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", draw("fair"), nil))
+	if first.Event != "fair" || (first.Prize.SKU != "MUG" && first.Prize.SKU != "HONOR") || first.AwardedAt == "" {
+		t.Fatalf("draw = %+v", first)
+	}
+	again := decodeBody[api.DrawPrizeResponse](t, s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", draw("fair"), nil))
+	if again.Prize != first.Prize || again.AwardedAt != first.AwardedAt || again.Message != "Prize already awarded for this session" {
+		t.Errorf("second draw = %+v, want the first award %+v", again, first)
+	}
+
+	// Another player with the same session ID gets their own draw
+	other := decodeBody[api.DrawPrizeResponse](t, s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", api.DrawPrizeRequest{
+		UserEmail: "b@example.com", SessionID: "s-1", Receipt: testReceipt(t, "fair", "b@example.com", "s-1", true)}, nil))
+	if other.Message != "Prize drawn successfully" {
+		t.Errorf("draw for b@example.com = %+v, want a new award", other)
+	}
+}
+
+func TestDrawPrizeConcurrent(t *testing.T) {
+	s := newTestServer(t)
+
+	// Different sessions race for the two mugs; one session retries its draw
+	const sessions = 30
+	results := make(chan api.DrawPrizeResponse, sessions+5)
+	var wg sync.WaitGroup
+	for i := range sessions + 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sessionID := fmt.Sprintf("s-%d", i)
+			if i >= sessions {
+				sessionID = "s-0"
+			}
+			rec := s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: sessionID,
+				Receipt: testReceipt(t, "fair", "a@example.com", sessionID, true)}, nil)
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, body %q", rec.Code, rec.Body.String())
+				return
+			}
+			results <- decodeBody[api.DrawPrizeResponse](t, rec)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	if len(results) != sessions+5 {
+		t.Fatalf("%d draws succeeded, want %d", len(results), sessions+5)
+	}
+	event, _ := s.srv.getEvent("fair")
+	awards, err := prizes.ReadAwards(t.Context(), event.DataDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(awards) != sessions {
+		t.Errorf("%d awards recorded, want one per session (%d)", len(awards), sessions)
+	}
+	mugs := 0
+	for _, award := range awards {
+		if award.SKU == "MUG" {
+			mugs++
+		}
+	}
+	count := decodeBody[api.WinnerCountResponse](t, s.do(http.MethodGet, apiPrefix+"/winner/count?event=fair", nil, nil))
+	if mugs > 2 || count.WinnerCount != mugs {
+		t.Errorf("%d mugs awarded for a stock of 2, winner count %d", mugs, count.WinnerCount)
+	}
+}
+
+func TestRedeemPrize(t *testing.T) {
+	staff := map[string]string{"X-API-Key": "staff-key"}
+	redeem := func(email, sessionID string) RedeemPrizeRequest {
+		return RedeemPrizeRequest{UserEmail: email, SessionID: sessionID}
+	}
+	setup := func(s *testServer) {
+		s.srv.config.Admin.APIKeys = append(s.srv.config.Admin.APIKeys,
+			AdminAPIKey{Name: "booth", KeyHash: hashToken("staff-key"), Role: RoleStaff},
+			AdminAPIKey{Name: "grafana", KeyHash: hashToken("viewer-key"), Role: RoleViewer})
+		rec := s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1",
+			Receipt: testReceipt(t, "fair", "a@example.com", "s-1", true)}, nil)
+		if rec.Code != http.StatusOK {
+			s.t.Fatalf("draw: status = %d, body %q", rec.Code, rec.Body.String())
+		}
+	}
+	runHandlerCases(t, []handlerCase{
+		{"redeemed", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-1"), staff, http.StatusOK, ""},
+		{"by an admin", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-1"), map[string]string{"X-API-Key": "admin-key"}, http.StatusOK, ""},
+		{"viewer", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-1"), map[string]string{"X-API-Key": "viewer-key"}, http.StatusForbidden, CodeForbidden},
+		{"no credentials", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-1"), nil, http.StatusUnauthorized, CodeAuthRequired},
+		{"other email", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", redeem("b@example.com", "s-1"), staff, http.StatusNotFound, CodePrizeNotFound},
+		{"no prize", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-2"), staff, http.StatusNotFound, CodePrizeNotFound},
+		{"other event", http.MethodPost, apiPrefix + "/admin/prizes/redeem", redeem("a@example.com", "s-1"), staff, http.StatusNotFound, CodePrizeNotFound},
+		{"missing session", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", `{"userEmail": "a@example.com"}`, staff, http.StatusBadRequest, CodeMissingFields},
+		{"malformed json", http.MethodPost, apiPrefix + "/admin/prizes/redeem?event=fair", `{"userEmail":`, staff, http.StatusBadRequest, CodeInvalidJSON},
+		{"wrong method", http.MethodGet, apiPrefix + "/admin/prizes/redeem?event=fair", nil, staff, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, setup)
+
+	s := newTestServer(t)
+	setup(s)
+	first := decodeBody[RedeemPrizeResponse](t, s.do(http.MethodPost, apiPrefix+"/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-1"), staff))
+	if first.RedeemedBy != "booth" || first.RedeemedAt == "" || first.Prize.SKU == "" {
+		t.Fatalf("redemption = %+v", first)
+	}
+	rec := s.do(http.MethodPost, apiPrefix+"/admin/prizes/redeem?event=fair", redeem("a@example.com", "s-1"), staff)
+	if got := decodeBody[ErrorResponse](t, rec); rec.Code != http.StatusConflict || got.Code != CodePrizeRedeemed {
+		t.Errorf("second redemption = %d %+v, want 409 %s", rec.Code, got, CodePrizeRedeemed)
+	}
+	event, _ := s.srv.getEvent("fair")
+	redemptions, err := prizes.ReadRedemptions(t.Context(), event.DataDir())
+	if err != nil || len(redemptions) != 1 || redemptions[0].SessionID != "s-1" || redemptions[0].RedeemedBy != "booth" {
+		t.Errorf("redemptions = %+v, %v, want one by booth", redemptions, err)
+	}
+}
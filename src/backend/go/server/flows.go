@@ -0,0 +1,674 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"delfos/questions"
+	"delfos/scoring"
+	"delfos/sessions"
+	"delfos/storage"
+)
+
+// Built-in conversation flows, used when no override exists in the flows directory
+//
+//go:embed flows/*.json
+var builtinFlows embed.FS
+
+// FlowOption represents a selectable choice offered by a flow node
+type FlowOption struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Next  string `json:"next,omitempty"` // Optional node to jump to, overrides the node's next mapping
+}
+
+// FlowNode represents a single step of a conversation flow
+type FlowNode struct {
+	Type     string            `json:"type,omitempty"` // "input" (default), "quiz" or "end"
+	Prompt   []string          `json:"prompt"`         // Lines shown on entering the node, {{var}} placeholders are expanded
+	Options  []FlowOption      `json:"options,omitempty"`
+	Validate string            `json:"validate,omitempty"` // Regex the input must match
+	Invalid  string            `json:"invalid,omitempty"`  // Message shown when the input is rejected
+	Store    string            `json:"store,omitempty"`    // Session variable the accepted input is saved under
+	Hooks    []string          `json:"hooks,omitempty"`    // Side effects run once the input is accepted
+	Next     map[string]string `json:"next,omitempty"`     // Outcome or input -> next node, "*" is the fallback
+
+	pattern *regexp.Regexp
+}
+
+// Flow represents a scripted conversation graph
+type Flow struct {
+	ID        string               `json:"id"`
+	Start     string               `json:"start"`
+	PassScore float64              `json:"passScore,omitempty"` // Minimum score percentage for the "passed" outcome (default 75)
+	Nodes     map[string]*FlowNode `json:"nodes"`
+}
+
+// FlowSession holds the conversation state of a single terminal session
+type FlowSession struct {
+	SessionID    string
+	EventID      string
+	KioskID      string
+	FlowID       string
+	Node         string
+	Vars         map[string]string
+	QuestionIDs  []string
+	OptionOrders map[string]string         // Display order of each question's options, answers use its letters
+	Timings      map[string]scoring.Timing // When each question was shown and answered
+	Answers      []string
+	Receipt      string // Signed once the quiz is evaluated, for the prize routes
+	Done         bool
+	Closed       bool // Set when the event ended while the session was in progress
+	UpdatedAt    time.Time
+}
+
+// ProcessRequest represents the request body for advancing a conversation
+type ProcessRequest struct {
+	SessionID string `json:"session_id"`
+	Input     string `json:"input"`
+	Flow      string `json:"flow,omitempty"`      // Optional flow ID, only used when the session starts
+	Timestamp int64  `json:"timestamp,omitempty"` // Optional frontend timestamp in milliseconds
+}
+
+// ProcessResponse represents the next conversation step sent back to the terminal
+type ProcessResponse struct {
+	Type      string   `json:"type"`
+	SessionID string   `json:"sessionId"`
+	Node      string   `json:"node"`
+	Content   string   `json:"content"`
+	Prompt    string   `json:"prompt,omitempty"`
+	Options   []string `json:"options,omitempty"`
+	Receipt   string   `json:"receipt,omitempty"` // Evaluation receipt, once the quiz is evaluated
+	Done      bool     `json:"done"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// flowHook runs a side effect for a session and returns an outcome used for routing ("" keeps the input)
+type flowHook func(srv *Server, ctx context.Context, s *FlowSession, flow *Flow, event *Event) (outcome string, messages []string, err error)
+
+var flowHooks = map[string]flowHook{
+	"create_user":    (*Server).hookCreateUser,
+	"draw_questions": (*Server).hookDrawQuestions,
+	"evaluate":       (*Server).hookEvaluate,
+}
+
+const defaultFlowID = "booth"
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// loadFlows loads the built-in flows and then any overrides found in dir
+func (srv *Server) loadFlows(dir string) error {
+	loaded := map[string]*Flow{}
+
+	entries, err := builtinFlows.ReadDir("flows")
+	if err != nil {
+		return fmt.Errorf("failed to read built-in flows: %w", err)
+	}
+	for _, entry := range entries {
+		content, err := builtinFlows.ReadFile("flows/" + entry.Name())
+		if err != nil {
+			return fmt.Errorf("failed to read built-in flow %s: %w", entry.Name(), err)
+		}
+		flow, err := srv.parseFlow(content)
+		if err != nil {
+			return fmt.Errorf("invalid built-in flow %s: %w", entry.Name(), err)
+		}
+		loaded[flow.ID] = flow
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list flows directory: %w", err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read flow file %s: %w", path, err)
+		}
+		flow, err := srv.parseFlow(content)
+		if err != nil {
+			return fmt.Errorf("invalid flow file %s: %w", path, err)
+		}
+		loaded[flow.ID] = flow
+	}
+
+	srv.flowsMu.Lock()
+	srv.flows = loaded
+	srv.flowsMu.Unlock()
+
+	for id, flow := range loaded {
+		slog.Info("loaded conversation flow", "flow", id, "nodes", len(flow.Nodes))
+	}
+	return nil
+}
+
+// parseFlow decodes a flow definition and validates its graph
+func (srv *Server) parseFlow(content []byte) (*Flow, error) {
+	var flow Flow
+	if err := json.Unmarshal(content, &flow); err != nil {
+		return nil, fmt.Errorf("failed to decode flow: %w", err)
+	}
+
+	if flow.ID == "" {
+		return nil, fmt.Errorf("flow id is required")
+	}
+	if flow.PassScore == 0 {
+		flow.PassScore = srv.config.Quiz.PassScore
+	}
+	if _, ok := flow.Nodes[flow.Start]; !ok {
+		return nil, fmt.Errorf("start node %q does not exist", flow.Start)
+	}
+
+	for id, node := range flow.Nodes {
+		switch node.Type {
+		case "", "input", "quiz", "end":
+		default:
+			return nil, fmt.Errorf("node %q has unknown type %q", id, node.Type)
+		}
+		if node.Validate != "" {
+			pattern, err := regexp.Compile(node.Validate)
+			if err != nil {
+				return nil, fmt.Errorf("node %q has invalid validation regex: %w", id, err)
+			}
+			node.pattern = pattern
+		}
+		for _, hook := range node.Hooks {
+			if _, ok := flowHooks[hook]; !ok {
+				return nil, fmt.Errorf("node %q uses unknown hook %q", id, hook)
+			}
+		}
+		for outcome, target := range node.Next {
+			if _, ok := flow.Nodes[target]; !ok {
+				return nil, fmt.Errorf("node %q routes %q to missing node %q", id, outcome, target)
+			}
+		}
+		for _, option := range node.Options {
+			if option.Next == "" {
+				continue
+			}
+			if _, ok := flow.Nodes[option.Next]; !ok {
+				return nil, fmt.Errorf("node %q option %q routes to missing node %q", id, option.Key, option.Next)
+			}
+		}
+		if node.Type != "end" && len(node.Next) == 0 && !optionsRouteAll(node.Options) {
+			return nil, fmt.Errorf("node %q has no next node", id)
+		}
+	}
+	if id, ok := undrawnQuiz(&flow); ok {
+		return nil, fmt.Errorf("quiz node %q can be reached without a draw_questions hook before it", id)
+	}
+
+	return &flow, nil
+}
+
+// undrawnQuiz returns a quiz node some path reaches without drawing questions first, from the start
+// node or from a quiz whose questions were all answered
+func undrawnQuiz(flow *Flow) (string, bool) {
+	pending := []string{flow.Start}
+	for _, node := range flow.Nodes {
+		if node.Type == "quiz" && !slices.Contains(node.Hooks, "draw_questions") {
+			pending = append(pending, nodeTargets(node)...)
+		}
+	}
+
+	seen := map[string]bool{}
+	for len(pending) > 0 {
+		id := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		node := flow.Nodes[id]
+		if node.Type == "quiz" {
+			return id, true
+		}
+		if !slices.Contains(node.Hooks, "draw_questions") {
+			pending = append(pending, nodeTargets(node)...)
+		}
+	}
+	return "", false
+}
+
+// nodeTargets returns the nodes a node can route to
+func nodeTargets(node *FlowNode) []string {
+	var targets []string
+	for _, target := range node.Next {
+		targets = append(targets, target)
+	}
+	for _, option := range node.Options {
+		if option.Next != "" {
+			targets = append(targets, option.Next)
+		}
+	}
+	return targets
+}
+
+// optionsRouteAll reports whether every option carries its own next node
+func optionsRouteAll(options []FlowOption) bool {
+	if len(options) == 0 {
+		return false
+	}
+	for _, option := range options {
+		if option.Next == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// processInput handles advancing a conversation flow by one step
+// It expects a POST request with JSON body containing session_id and input
+func (srv *Server) processInput(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req ProcessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid process request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	if req.SessionID == "" {
+		writeMissingFields(w, r, []string{"session_id"})
+		return
+	}
+	addLogFields(r.Context(), "session_id", req.SessionID)
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	flowID := req.Flow
+	if flowID == "" {
+		flowID = r.URL.Query().Get("flow")
+	}
+
+	response, err := srv.advanceFlow(r.Context(), req.SessionID, event, requestKioskID(r), flowID, req.Input)
+	var closed *EventClosedError
+	if errors.As(err, &closed) {
+		writeEventClosed(w, r, closed)
+		return
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to process input", "error", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidInput, err.Error(), nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// advanceFlow feeds one input into a session's flow and returns the resulting step
+// A session that does not exist yet is started in the event from the kiosk (using the
+// event's flow unless flowID is set) and its first node is returned
+func (srv *Server) advanceFlow(ctx context.Context, sessionID string, event *Event, kioskID, flowID, input string) (_ ProcessResponse, err error) {
+	ctx, span := startSpan(ctx, "dialogue.advance", "session.id", sessionID, "event.id", event.ID)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	srv.sessionsMu.Lock()
+	defer srv.sessionsMu.Unlock()
+
+	session, exists := srv.flowSessions[sessionID]
+	if !exists {
+		if flowID == "" {
+			flowID = event.Flow
+		}
+		flow, ok := srv.getFlow(flowID)
+		if !ok {
+			return ProcessResponse{}, fmt.Errorf("unknown flow %q", flowID)
+		}
+		if closed := registrationError(event, time.Now()); closed != nil {
+			return ProcessResponse{}, closed
+		}
+
+		session = &FlowSession{
+			SessionID: sessionID,
+			EventID:   event.ID,
+			KioskID:   kioskID,
+			FlowID:    flow.ID,
+			Node:      flow.Start,
+			Vars:      map[string]string{"sessionId": sessionID},
+			UpdatedAt: time.Now(),
+		}
+		srv.flowSessions[sessionID] = session
+		slog.InfoContext(ctx, "conversation started", "flow", flow.ID)
+
+		return renderStep(session, flow, nil), nil
+	}
+
+	flow, ok := srv.getFlow(session.FlowID)
+	if !ok {
+		return ProcessResponse{}, fmt.Errorf("flow %q is no longer available", session.FlowID)
+	}
+	session.UpdatedAt = time.Now()
+	defer logFlowSession(ctx, session)
+	logFlowSession(ctx, session)
+
+	event, ok = srv.getEvent(session.EventID)
+	if !ok {
+		return ProcessResponse{}, fmt.Errorf("event %q is no longer available", session.EventID)
+	}
+	if closed := submissionError(event, time.Now()); closed != nil && !session.Done {
+		session.Done = true
+		session.Closed = true
+	}
+	if session.Closed {
+		return ProcessResponse{}, &EventClosedError{Event: event, Code: CodeEventEnded}
+	}
+
+	if session.Done {
+		return renderStep(session, flow, nil), nil
+	}
+
+	node := flow.Nodes[session.Node]
+	input = strings.TrimSpace(input)
+
+	if !acceptsInput(node, input) {
+		message := node.Invalid
+		if message == "" {
+			message = "Entrada no válida, intenta de nuevo."
+		}
+		return renderStep(session, flow, []string{message}), nil
+	}
+
+	if node.Type == "quiz" {
+		if len(session.Answers) >= len(session.QuestionIDs) || session.Timings == nil {
+			return ProcessResponse{}, fmt.Errorf("quiz node %q has no question to answer, %d answers for %d questions", session.Node, len(session.Answers), len(session.QuestionIDs))
+		}
+		questionID := session.QuestionIDs[len(session.Answers)]
+		timing := session.Timings[questionID]
+		timing.AnsweredAt = time.Now()
+		session.Timings[questionID] = timing
+		session.Answers = append(session.Answers, strings.ToLower(input))
+		if len(session.Answers) < len(session.QuestionIDs) {
+			return renderStep(session, flow, []string{"Respuesta registrada: " + strings.ToUpper(input)}), nil
+		}
+	} else if node.Store != "" {
+		session.Vars[node.Store] = input
+		if label := optionLabel(node, input); label != "" {
+			session.Vars[node.Store+"Label"] = label
+		}
+	}
+
+	outcome := input
+	var messages []string
+	for _, name := range node.Hooks {
+		hookCtx, hookSpan := startSpan(ctx, "dialogue.hook."+name, "flow.node", session.Node)
+		hookOutcome, hookMessages, err := flowHooks[name](srv, hookCtx, session, flow, event)
+		hookSpan.SetAttributes("flow.outcome", hookOutcome)
+		hookSpan.SetError(err)
+		hookSpan.End()
+		var closed *EventClosedError
+		if errors.As(err, &closed) {
+			return ProcessResponse{}, closed
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "flow hook failed", "hook", name, "error", err)
+			return ProcessResponse{}, fmt.Errorf("failed to run %s", name)
+		}
+		messages = append(messages, hookMessages...)
+		if hookOutcome != "" {
+			outcome = hookOutcome
+		}
+	}
+
+	session.Node = nextNode(node, outcome, input)
+	if flow.Nodes[session.Node].Type == "end" {
+		// Finished conversations are forgotten, the same session ID starts a new one
+		session.Done = true
+		delete(srv.flowSessions, sessionID)
+	}
+
+	return renderStep(session, flow, messages), nil
+}
+
+// getFlow returns a loaded flow by ID
+func (srv *Server) getFlow(id string) (*Flow, bool) {
+	srv.flowsMu.RLock()
+	defer srv.flowsMu.RUnlock()
+	flow, ok := srv.flows[id]
+	return flow, ok
+}
+
+// acceptsInput checks the input against the node's validation regex or option keys
+func acceptsInput(node *FlowNode, input string) bool {
+	if node.pattern != nil {
+		return node.pattern.MatchString(input)
+	}
+	if len(node.Options) > 0 {
+		return optionFor(node, input) != nil
+	}
+	return input != ""
+}
+
+// optionFor returns the option whose key matches the input (case-insensitive)
+func optionFor(node *FlowNode, input string) *FlowOption {
+	for i := range node.Options {
+		if strings.EqualFold(node.Options[i].Key, input) {
+			return &node.Options[i]
+		}
+	}
+	return nil
+}
+
+// optionLabel returns the label of the option matching the input, if any
+func optionLabel(node *FlowNode, input string) string {
+	if option := optionFor(node, input); option != nil {
+		return option.Label
+	}
+	return ""
+}
+
+// nextNode resolves the node that follows, checking option routes, outcome, input and fallback in order
+func nextNode(node *FlowNode, outcome, input string) string {
+	if option := optionFor(node, input); option != nil && option.Next != "" {
+		return option.Next
+	}
+	if target, ok := node.Next[outcome]; ok {
+		return target
+	}
+	if target, ok := node.Next[strings.ToLower(input)]; ok {
+		return target
+	}
+	return node.Next["*"]
+}
+
+// renderStep builds the response for the session's current node
+func renderStep(session *FlowSession, flow *Flow, messages []string) ProcessResponse {
+	node := flow.Nodes[session.Node]
+
+	var prompt []string
+	for _, line := range node.Prompt {
+		prompt = append(prompt, expandPlaceholders(line, session.Vars))
+	}
+
+	var options []string
+	for _, option := range node.Options {
+		options = append(options, fmt.Sprintf("[%s] %s", option.Key, option.Label))
+	}
+
+	if node.Type == "quiz" && len(session.Answers) < len(session.QuestionIDs) {
+		index := len(session.Answers)
+		if index > 0 {
+			// Only show the node introduction before the first question
+			prompt = nil
+		}
+		prompt = append(prompt, fmt.Sprintf("Pregunta %d de %d:", index+1, len(session.QuestionIDs)))
+		if question, ok := questions.Find(session.QuestionIDs[index]); ok {
+			question = questions.Reorder(question, session.OptionOrders[question.ID])
+			if _, served := session.Timings[question.ID]; !served {
+				session.Timings[question.ID] = scoring.Timing{ServedAt: time.Now()}
+			}
+			prompt = append(prompt, question.Question)
+			for i, option := range question.Options {
+				options = append(options, fmt.Sprintf("%c) %s", 'a'+i, option))
+			}
+		}
+	}
+
+	return ProcessResponse{
+		Type:      "ai_response",
+		SessionID: session.SessionID,
+		Node:      session.Node,
+		Content:   strings.Join(messages, "\n"),
+		Prompt:    strings.Join(prompt, "\n"),
+		Options:   options,
+		Receipt:   session.Receipt,
+		Done:      session.Done,
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
+
+// expandPlaceholders replaces {{var}} placeholders with session variables
+func expandPlaceholders(line string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(line, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		return vars[name]
+	})
+}
+
+// logFlowSession adds the session's email hash and profile to the request's log fields
+func logFlowSession(ctx context.Context, s *FlowSession) {
+	logSession(ctx, s.Vars["userEmail"], s.SessionID)
+	if profile := s.Vars["profile"]; profile != "" {
+		addLogFields(ctx, "profile", profile)
+	}
+}
+
+// hookCreateUser creates the user session file from the stored userEmail
+func (srv *Server) hookCreateUser(ctx context.Context, s *FlowSession, flow *Flow, event *Event) (string, []string, error) {
+	if closed := registrationError(event, time.Now()); closed != nil {
+		return "", nil, closed
+	}
+	user, _, err := srv.saveUser(ctx, event, s.Vars["userEmail"], s.SessionID, s.KioskID, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to register %s in event %s: %w", s.Vars["userEmail"], event.ID, err)
+	}
+	srv.recordKioskSession(ctx, s.KioskID)
+	srv.auditUserCreated(ctx, event, user.UserEmail, s.SessionID, user.CreatedAt)
+	s.Vars["createdAt"] = user.CreatedAt
+	logFlowSession(ctx, s)
+	slog.InfoContext(ctx, "user registered", "server_timestamp", user.CreatedAt)
+
+	return "", []string{
+		"Correo registrado: " + user.UserEmail,
+		"Confirmación del servidor: " + user.CreatedAt,
+	}, nil
+}
+
+// hookDrawQuestions draws the quiz questions for the stored profile
+func (srv *Server) hookDrawQuestions(ctx context.Context, s *FlowSession, flow *Flow, event *Event) (string, []string, error) {
+	profile := s.Vars["profile"]
+	if profile == "" {
+		profile = "1"
+	}
+	if !event.ProfileEnabled(profile) {
+		return "", nil, fmt.Errorf("profile %s is not enabled for event %s", profile, event.ID)
+	}
+
+	var drawn []string
+	for _, number := range questions.Draw(profile, srv.config.Quiz.QuestionsPerQuiz) {
+		drawn = append(drawn, questions.FormatID(profile, number))
+	}
+
+	// Record the set in the session file like /choose-questions; flows without create_user have none
+	issued, err := srv.issueQuestions(ctx, event, s.Vars["userEmail"], s.SessionID, shuffleOptions(drawn))
+	if errors.Is(err, sessions.ErrNotFound) {
+		issued = shuffleOptions(drawn)
+	} else if err != nil {
+		return "", nil, err
+	}
+
+	s.QuestionIDs = issued.QuestionIDs
+	s.OptionOrders = issued.Orders()
+	s.Timings = map[string]scoring.Timing{}
+	s.Answers = nil
+	s.Vars["questionCount"] = strconv.Itoa(len(s.QuestionIDs))
+	if slices.Equal(issued.QuestionIDs, drawn) {
+		quizzesStarted.Inc(event.ID, profile)
+	}
+
+	return "", nil, nil
+}
+
+// hookEvaluate scores the collected answers and routes to "passed" or "failed"
+func (srv *Server) hookEvaluate(ctx context.Context, s *FlowSession, flow *Flow, event *Event) (string, []string, error) {
+	if len(s.QuestionIDs) == 0 || len(s.Answers) != len(s.QuestionIDs) {
+		return "", nil, fmt.Errorf("session has %d answers for %d questions", len(s.Answers), len(s.QuestionIDs))
+	}
+
+	// Held for the whole evaluation like /evaluate-answers, so a session is scored once; flows
+	// without create_user have no session file and nothing else can evaluate them
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+	content, err := sessions.Read(event.DataDir(), s.Vars["userEmail"], s.SessionID)
+	registered := !errors.Is(err, sessions.ErrNotFound)
+	if err != nil && registered {
+		return "", nil, err
+	}
+	if registered {
+		switch {
+		case sessions.Evaluated(content):
+			return "", nil, fmt.Errorf("session %s was already evaluated", s.SessionID)
+		case sessions.Expired(content, time.Duration(srv.config.Quiz.SessionTTL), time.Now()):
+			return "", nil, sessions.ErrExpired
+		case sessions.Closed(content):
+			return "", nil, sessions.ErrClosed
+		}
+	}
+
+	evaluation := scoring.EvaluateDisplayed(s.QuestionIDs, s.Answers, s.OptionOrders)
+	evaluation.AddTimings(s.Timings, srv.timeBonus())
+	if err := storage.AppendResult(ctx, event.DataDir(), s.Vars["userEmail"], s.SessionID, evaluation); err != nil {
+		return "", nil, err
+	}
+	if registered {
+		answers := make(map[string]string, len(s.QuestionIDs))
+		for i, id := range s.QuestionIDs {
+			answers[id] = s.Answers[i]
+		}
+		if err := sessions.MarkEvaluated(ctx, event.DataDir(), s.Vars["userEmail"], s.SessionID, answers, time.Now()); err != nil {
+			return "", nil, err
+		}
+	}
+	srv.auditAnswersSubmitted(ctx, event, s.SessionID, s.QuestionIDs, s.Answers)
+	srv.auditEvaluation(ctx, event, s.SessionID, s.QuestionIDs, evaluation, flow.PassScore)
+	recordQuizCompleted(event, s.QuestionIDs, evaluation, flow.PassScore)
+	s.Vars["correctAnswers"] = strconv.Itoa(evaluation.CorrectAnswers)
+	s.Vars["totalQuestions"] = strconv.Itoa(evaluation.TotalQuestions)
+	s.Vars["scorePercentage"] = strconv.FormatFloat(evaluation.ScorePercentage, 'f', 0, 64)
+	receipt, err := srv.issueReceipt(event, s.Vars["userEmail"], s.SessionID, s.QuestionIDs, evaluation, flow.PassScore, time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	s.Receipt = receipt
+
+	slog.InfoContext(ctx, "conversation evaluated", "correct", evaluation.CorrectAnswers, "total", evaluation.TotalQuestions)
+
+	messages := []string{"¡Evaluación completada!"}
+	if evaluation.Passed(flow.PassScore) {
+		return "passed", messages, nil
+	}
+	return "failed", messages, nil
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"delfos/api"
+)
+
+func TestProcessInput(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"started", http.MethodPost, apiPrefix + "/process", ProcessRequest{SessionID: "c-1"}, nil, http.StatusOK, ""},
+		{"malformed json", http.MethodPost, apiPrefix + "/process", `{"session_id": "c-1"`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"missing session", http.MethodPost, apiPrefix + "/process", `{"input": "hola"}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"wrong method", http.MethodGet, apiPrefix + "/process", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"unknown flow", http.MethodPost, apiPrefix + "/process", ProcessRequest{SessionID: "c-1", Flow: "nope"}, nil, http.StatusBadRequest, CodeInvalidInput},
+		{"unknown event", http.MethodPost, apiPrefix + "/process?event=nope", ProcessRequest{SessionID: "c-1"}, nil, http.StatusNotFound, CodeEventNotFound},
+		{"event ended", http.MethodPost, apiPrefix + "/process?event=ended", ProcessRequest{SessionID: "c-1"}, nil, http.StatusForbidden, CodeEventEnded},
+	}, nil)
+}
+
+func TestProcessConversation(t *testing.T) {
+	tests := []struct {
+		name     string
+		correct  bool
+		wantNode string
+	}{
+		{"passed", true, "roulette"},
+		{"failed", false, "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			step := func(input, wantNode string) ProcessResponse {
+				t.Helper()
+				rec := s.do(http.MethodPost, apiPrefix+"/process?event=fair", ProcessRequest{SessionID: "c-1", Input: input}, nil)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("input %q: status = %d, body %q", input, rec.Code, rec.Body.String())
+				}
+				response := decodeBody[ProcessResponse](t, rec)
+				if response.Node != wantNode {
+					t.Fatalf("input %q: node = %s, want %s (%+v)", input, response.Node, wantNode, response)
+				}
+				return response
+			}
+
+			step("", "email")
+			step("not an email", "email")
+			step("a@example.com", "profile")
+			rec := s.do(http.MethodPost, apiPrefix+"/process?event=fair", ProcessRequest{SessionID: "c-1", Input: "3"}, nil)
+			if rec.Code != http.StatusBadRequest || decodeBody[ErrorResponse](t, rec).Code != CodeInvalidInput {
+				t.Errorf("profile not enabled in the event: status = %d, body %q", rec.Code, rec.Body.String())
+			}
+			quiz := step("2", "quiz")
+			if !strings.Contains(quiz.Prompt, "Pregunta 1 de") || len(quiz.Options) < 2 {
+				t.Errorf("first question = %+v", quiz)
+			}
+
+			s.srv.sessionsMu.Lock()
+			ids, orders := s.srv.flowSessions["c-1"].QuestionIDs, s.srv.flowSessions["c-1"].OptionOrders
+			s.srv.sessionsMu.Unlock()
+			answers := correctAnswers(t, ids)
+			if !tt.correct {
+				answers = wrongAnswers(t, ids)
+			}
+			answers = displayedAnswers(orders, ids, answers)
+			var last ProcessResponse
+			for i, answer := range answers {
+				wantNode := "quiz"
+				if i == len(answers)-1 {
+					wantNode = tt.wantNode
+				}
+				last = step(answer, wantNode)
+			}
+			s.srv.sessionsMu.Lock()
+			_, kept := s.srv.flowSessions["c-1"]
+			s.srv.sessionsMu.Unlock()
+			if !last.Done || kept {
+				t.Errorf("finished conversation: done %v, kept %v, want it done and forgotten", last.Done, kept)
+			}
+
+			// The receipt only opens the prize routes after a passed quiz
+			fair, _ := s.srv.getEvent("fair")
+			if _, err := s.srv.verifyReceipt(last.Receipt, fair, "a@example.com", "c-1"); (err == nil) != tt.correct {
+				t.Errorf("receipt %q: %v, want it valid only when passed", last.Receipt, err)
+			}
+			session, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", "a@example.com_c-1.txt"))
+			if err != nil || !strings.Contains(string(session), "Questions: "+strings.Join(ids, ",")+"\n") {
+				t.Errorf("session file = %q, %v, want the issued questions", session, err)
+			}
+
+			if !strings.Contains(string(session), "Evaluated: ") {
+				t.Errorf("session file = %q, want it marked evaluated", session)
+			}
+
+			// The evaluated session cannot be scored again with other answers
+			other := make([]string, len(answers))
+			for i, answer := range answers {
+				other[i] = "a"
+				if answer == "a" {
+					other[i] = "b"
+				}
+			}
+			rec = s.do(http.MethodPost, apiPrefix+"/evaluate-answers?event=fair", api.EvaluateAnswersRequest{
+				QuestionIds: ids, UserAnswers: other, UserEmail: "a@example.com", SessionID: "c-1",
+			}, nil)
+			if rec.Code != http.StatusConflict || decodeBody[ErrorResponse](t, rec).Code != CodeAnswerConflict {
+				t.Errorf("second evaluation: status = %d, body %q", rec.Code, rec.Body.String())
+			}
+
+			content, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", "results.txt"))
+			if err != nil || strings.Count(string(content), "|a@example.com|c-1|") != 1 {
+				t.Errorf("results.txt = %q, %v, want one result", content, err)
+			}
+		})
+	}
+}
+
+func TestExpireFlowSessions(t *testing.T) {
+	s := newTestServer(t)
+	for _, id := range []string{"c-1", "c-2"} {
+		s.do(http.MethodPost, apiPrefix+"/process", ProcessRequest{SessionID: id}, nil)
+	}
+	s.srv.sessionsMu.Lock()
+	s.srv.flowSessions["c-1"].UpdatedAt = time.Now().Add(-time.Duration(s.srv.config.Quiz.SessionTTL) - time.Second)
+	s.srv.sessionsMu.Unlock()
+
+	s.srv.expireFlowSessions(time.Now())
+	if _, ok := s.srv.flowSessions["c-1"]; ok {
+		t.Error("idle conversation c-1 was kept past quiz.sessionTTL")
+	}
+	if _, ok := s.srv.flowSessions["c-2"]; !ok {
+		t.Error("active conversation c-2 was forgotten")
+	}
+
+	// Without a TTL conversations are only forgotten once finished
+	s.srv.config.Quiz.SessionTTL = 0
+	s.srv.expireFlowSessions(time.Now().Add(24 * time.Hour))
+	if len(s.srv.flowSessions) != 1 {
+		t.Errorf("conversations = %d, want c-2 kept with quiz.sessionTTL 0", len(s.srv.flowSessions))
+	}
+}
+
+func TestParseFlowQuizNeedsQuestions(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes string
+		valid bool
+	}{
+		{"drawn", `"pick": {"hooks": ["draw_questions"], "next": {"*": "quiz"}}, "quiz": {"type": "quiz", "next": {"*": "end"}}, "end": {"type": "end"}`, true},
+		{"start", `"quiz": {"type": "quiz", "next": {"*": "end"}}, "end": {"type": "end"}`, false},
+		{"no draw", `"pick": {"next": {"*": "quiz"}}, "quiz": {"type": "quiz", "next": {"*": "end"}}, "end": {"type": "end"}`, false},
+		{"other branch", `"pick": {"options": [{"key": "1", "next": "draw"}, {"key": "2", "next": "quiz"}]}, "draw": {"hooks": ["draw_questions"], "next": {"*": "quiz"}}, "quiz": {"type": "quiz", "next": {"*": "end"}}, "end": {"type": "end"}`, false},
+		{"again after quiz", `"pick": {"hooks": ["draw_questions"], "next": {"*": "quiz"}}, "quiz": {"type": "quiz", "next": {"*": "quiz"}}`, false},
+		{"redrawn after quiz", `"pick": {"hooks": ["draw_questions"], "next": {"*": "quiz"}}, "quiz": {"type": "quiz", "next": {"*": "pick"}}`, true},
+	}
+	s := newTestServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := "pick"
+			if tt.name == "start" {
+				start = "quiz"
+			}
+			_, err := s.srv.parseFlow([]byte(`{"id": "f", "start": "` + start + `", "nodes": {` + tt.nodes + `}}`))
+			if (err == nil) != tt.valid {
+				t.Errorf("parseFlow error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestProcessQuizWithoutQuestions(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodPost, apiPrefix+"/process", ProcessRequest{SessionID: "c-1"}, nil)
+	s.srv.sessionsMu.Lock()
+	s.srv.flowSessions["c-1"].Node = "quiz"
+	s.srv.sessionsMu.Unlock()
+
+	rec := s.do(http.MethodPost, apiPrefix+"/process", ProcessRequest{SessionID: "c-1", Input: "a"}, nil)
+	if rec.Code != http.StatusBadRequest || decodeBody[ErrorResponse](t, rec).Code != CodeInvalidInput {
+		t.Errorf("answer without questions: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,446 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"delfos/storage"
+)
+
+// Kiosk represents a registered physical terminal
+type Kiosk struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Event         string    `json:"event,omitempty"` // Event used by the kiosk when a request does not name one
+	TokenHash     string    `json:"tokenHash"`
+	RegisteredAt  time.Time `json:"registeredAt"`
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitzero"`
+	LastAddress   string    `json:"lastAddress,omitempty"`
+	Sessions      int       `json:"sessions"`
+	Disabled      bool      `json:"disabled"`
+}
+
+// KioskStatus represents a kiosk as shown to admins
+type KioskStatus struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Event         string `json:"event,omitempty"`
+	Online        bool   `json:"online"`
+	LastHeartbeat string `json:"lastHeartbeat,omitempty"`
+	LastAddress   string `json:"lastAddress,omitempty"`
+	Sessions      int    `json:"sessions"`
+	Disabled      bool   `json:"disabled"`
+	RegisteredAt  string `json:"registeredAt"`
+}
+
+// KioskListResponse represents the response for listing kiosks
+type KioskListResponse struct {
+	Status string        `json:"status"`
+	Kiosks []KioskStatus `json:"kiosks"`
+}
+
+// RegisterKioskRequest represents the request body for kiosk registration
+type RegisterKioskRequest struct {
+	Name  string `json:"name"`
+	Event string `json:"event,omitempty"`
+}
+
+// RegisterKioskResponse represents the response for kiosk registration
+// The token is only returned once and must be sent as X-Kiosk-Token on every request;
+// the sync key signs the records the kiosk queues while offline
+type RegisterKioskResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	KioskID string `json:"kioskId"`
+	Token   string `json:"token"`
+	SyncKey string `json:"syncKey"`
+	Event   string `json:"event,omitempty"`
+}
+
+// HeartbeatResponse represents the response for a kiosk heartbeat
+type HeartbeatResponse struct {
+	Status     string `json:"status"`
+	KioskID    string `json:"kioskId"`
+	Event      string `json:"event,omitempty"`
+	ServerTime string `json:"serverTime"`
+}
+
+// SetKioskDisabledRequest represents the request body for enabling or disabling a kiosk
+type SetKioskDisabledRequest struct {
+	KioskID  string `json:"kioskId"`
+	Disabled bool   `json:"disabled"`
+}
+
+// SetKioskDisabledResponse represents the response for enabling or disabling a kiosk
+type SetKioskDisabledResponse struct {
+	Status   string `json:"status"`
+	KioskID  string `json:"kioskId"`
+	Disabled bool   `json:"disabled"`
+}
+
+type contextKey string
+
+const kioskContextKey contextKey = "kiosk"
+
+// Kiosks without a heartbeat for this long are reported offline
+const kioskOfflineAfter = 90 * time.Second
+
+// kiosksFile returns the path of the kiosk registry
+func (srv *Server) kiosksFile() string {
+	return filepath.Join(srv.config.DataDir, "kiosks.json")
+}
+
+// loadKiosks reads the kiosk registry from disk
+func (srv *Server) loadKiosks() error {
+	content, err := os.ReadFile(srv.kiosksFile())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read kiosks file: %w", err)
+	}
+
+	var list []*Kiosk
+	if err := json.Unmarshal(content, &list); err != nil {
+		return fmt.Errorf("failed to decode kiosks file: %w", err)
+	}
+
+	srv.kiosksMu.Lock()
+	defer srv.kiosksMu.Unlock()
+	srv.kiosks = map[string]*Kiosk{}
+	for _, kiosk := range list {
+		srv.kiosks[kiosk.ID] = kiosk
+	}
+	slog.Info("loaded registered kiosks", "count", len(srv.kiosks))
+	return nil
+}
+
+// saveKiosksLocked writes the kiosk registry to disk, kiosksMu must be held
+func (srv *Server) saveKiosksLocked(ctx context.Context) (err error) {
+	defer traceStorage(ctx, "save_kiosks")(&err)
+
+	list := make([]*Kiosk, 0, len(srv.kiosks))
+	for _, kiosk := range srv.kiosks {
+		list = append(list, kiosk)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode kiosks: %w", err)
+	}
+	if err := os.MkdirAll(srv.config.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := storage.WriteFileAtomic(srv.kiosksFile(), content, 0644); err != nil {
+		return fmt.Errorf("failed to write kiosks file: %w", err)
+	}
+	return nil
+}
+
+// kioskSyncKey returns the hex key a kiosk signs its sync records with, the HMAC-SHA256 of its
+// device token with kiosks.syncSecret; only the token hash is stored, so kiosks.json cannot sign
+func (srv *Server) kioskSyncKey(token string) string {
+	mac := hmac.New(sha256.New, []byte(srv.config.Kiosks.SyncSecret))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashToken returns the hex SHA-256 of a device token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// kioskMiddleware resolves the kiosk from the X-Kiosk-Token header and stores it in the request context
+// Requests without a token are let through (e.g. a browser in development) unless kiosks.required
+// is set and player marks a quiz route; unknown tokens get 401 and disabled kiosks get 403
+func (srv *Server) kioskMiddleware(player bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("X-Kiosk-Token")
+		if token == "" {
+			if player && srv.config.Kiosks.Required {
+				slog.WarnContext(r.Context(), "request without kiosk token refused", "method", r.Method, "path", r.URL.Path)
+				writeError(w, r, http.StatusUnauthorized, CodeKioskTokenRequired, "X-Kiosk-Token header is required", nil)
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		hash := hashToken(token)
+		srv.kiosksMu.Lock()
+		var kiosk *Kiosk
+		for _, k := range srv.kiosks {
+			if k.TokenHash == hash {
+				kiosk = k
+				break
+			}
+		}
+		var snapshot Kiosk
+		if kiosk != nil {
+			kiosk.LastAddress = r.RemoteAddr
+			snapshot = *kiosk
+		}
+		srv.kiosksMu.Unlock()
+
+		if kiosk == nil {
+			slog.WarnContext(r.Context(), "unknown kiosk token", "remote_addr", r.RemoteAddr)
+			writeError(w, r, http.StatusUnauthorized, CodeUnknownKiosk, "Unknown kiosk token", nil)
+			return
+		}
+		addLogFields(r.Context(), "kiosk_id", snapshot.ID)
+		if snapshot.Disabled {
+			slog.WarnContext(r.Context(), "disabled kiosk refused", "method", r.Method, "path", r.URL.Path)
+			writeError(w, r, http.StatusForbidden, CodeKioskDisabled, "Kiosk disabled", map[string]string{"kioskId": snapshot.ID})
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), kioskContextKey, &snapshot)))
+	}
+}
+
+// requestKiosk returns the kiosk that sent the request, or nil if it did not identify itself
+func requestKiosk(r *http.Request) *Kiosk {
+	kiosk, _ := r.Context().Value(kioskContextKey).(*Kiosk)
+	return kiosk
+}
+
+// requestKioskID returns the ID of the kiosk that sent the request, or "" if unknown
+func requestKioskID(r *http.Request) string {
+	if kiosk := requestKiosk(r); kiosk != nil {
+		return kiosk.ID
+	}
+	return ""
+}
+
+// recordKioskSession counts a new session for a kiosk
+func (srv *Server) recordKioskSession(ctx context.Context, kioskID string) {
+	if kioskID == "" {
+		return
+	}
+
+	srv.kiosksMu.Lock()
+	defer srv.kiosksMu.Unlock()
+	kiosk, ok := srv.kiosks[kioskID]
+	if !ok {
+		return
+	}
+	kiosk.Sessions++
+	if err := srv.saveKiosksLocked(ctx); err != nil {
+		slog.Error("failed to save kiosks", "kiosk_id", kioskID, "error", err)
+	}
+}
+
+// registerKiosk handles registering a new kiosk and issuing its device token
+// It expects a POST request with JSON body containing name and optional event
+func (srv *Server) registerKiosk(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req RegisterKioskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid kiosk registration", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	if req.Name == "" {
+		writeMissingFields(w, r, []string{"name"})
+		return
+	}
+	if req.Event != "" {
+		if _, ok := srv.getEvent(req.Event); !ok {
+			writeError(w, r, http.StatusNotFound, CodeEventNotFound, "Event not found", map[string]string{"event": req.Event})
+			return
+		}
+	}
+
+	id, err := randomHex(4)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to generate kiosk id", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	token, err := randomHex(32)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to generate kiosk token", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	now := time.Now()
+	kiosk := &Kiosk{
+		ID:            "kiosk-" + id,
+		Name:          req.Name,
+		Event:         req.Event,
+		TokenHash:     hashToken(token),
+		RegisteredAt:  now,
+		LastHeartbeat: now,
+		LastAddress:   r.RemoteAddr,
+	}
+
+	srv.kiosksMu.Lock()
+	srv.kiosks[kiosk.ID] = kiosk
+	err = srv.saveKiosksLocked(r.Context())
+	srv.kiosksMu.Unlock()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to save kiosks", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	srv.recordAudit(r.Context(), nil, AuditKioskRegister, kiosk.ID, nil, map[string]string{"name": kiosk.Name, "event": kiosk.Event})
+	slog.InfoContext(r.Context(), "kiosk registered", "kiosk_id", kiosk.ID, "name", kiosk.Name)
+
+	response := RegisterKioskResponse{
+		Status:  "success",
+		Message: "Kiosk registered successfully",
+		KioskID: kiosk.ID,
+		Token:   token,
+		SyncKey: srv.kioskSyncKey(token),
+		Event:   kiosk.Event,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// kioskHeartbeat handles a kiosk reporting that it is online
+// It expects a POST request from a kiosk identified by X-Kiosk-Token
+func (srv *Server) kioskHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	current := requestKiosk(r)
+	if current == nil {
+		writeError(w, r, http.StatusUnauthorized, CodeKioskTokenRequired, "X-Kiosk-Token header is required", nil)
+		return
+	}
+
+	now := time.Now()
+	srv.kiosksMu.Lock()
+	if kiosk, ok := srv.kiosks[current.ID]; ok {
+		kiosk.LastHeartbeat = now
+	}
+	srv.kiosksMu.Unlock()
+
+	response := HeartbeatResponse{
+		Status:     "success",
+		KioskID:    current.ID,
+		Event:      current.Event,
+		ServerTime: now.Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// listKiosks handles listing kiosks with their online status for admins
+// It expects a GET request
+func (srv *Server) listKiosks(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	now := time.Now()
+	srv.kiosksMu.Lock()
+	list := make([]KioskStatus, 0, len(srv.kiosks))
+	for _, kiosk := range srv.kiosks {
+		status := KioskStatus{
+			ID:           kiosk.ID,
+			Name:         kiosk.Name,
+			Event:        kiosk.Event,
+			Online:       !kiosk.Disabled && now.Sub(kiosk.LastHeartbeat) < kioskOfflineAfter,
+			LastAddress:  kiosk.LastAddress,
+			Sessions:     kiosk.Sessions,
+			Disabled:     kiosk.Disabled,
+			RegisteredAt: kiosk.RegisteredAt.Format(time.RFC3339),
+		}
+		if !kiosk.LastHeartbeat.IsZero() {
+			status.LastHeartbeat = kiosk.LastHeartbeat.Format(time.RFC3339)
+		}
+		list = append(list, status)
+	}
+	srv.kiosksMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(KioskListResponse{
+		Status: "success",
+		Kiosks: list,
+	})
+}
+
+// setKioskDisabled handles remotely disabling or re-enabling a kiosk
+// It expects a POST request with JSON body containing kioskId and disabled
+func (srv *Server) setKioskDisabled(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	var req SetKioskDisabledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid kiosk update", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	srv.kiosksMu.Lock()
+	kiosk, ok := srv.kiosks[req.KioskID]
+	var err error
+	var wasDisabled bool
+	if ok {
+		wasDisabled = kiosk.Disabled
+		kiosk.Disabled = req.Disabled
+		err = srv.saveKiosksLocked(r.Context())
+	}
+	srv.kiosksMu.Unlock()
+
+	if !ok {
+		writeError(w, r, http.StatusNotFound, CodeKioskNotFound, "Kiosk not found", map[string]string{"kioskId": req.KioskID})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to save kiosks", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	srv.recordAudit(r.Context(), nil, AuditKioskDisable, req.KioskID,
+		map[string]bool{"disabled": wasDisabled}, map[string]bool{"disabled": req.Disabled})
+	slog.InfoContext(r.Context(), "kiosk updated", "target_kiosk_id", req.KioskID, "disabled", req.Disabled)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(SetKioskDisabledResponse{
+		Status:   "success",
+		KioskID:  req.KioskID,
+		Disabled: req.Disabled,
+	})
+}
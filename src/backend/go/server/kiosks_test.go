@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRegisterKiosk(t *testing.T) {
+	admin := map[string]string{"X-API-Key": "admin-key"}
+	runHandlerCases(t, []handlerCase{
+		{"registered", http.MethodPost, apiPrefix + "/admin/kiosks/register", RegisterKioskRequest{Name: "Booth 1", Event: "fair"}, admin, http.StatusCreated, ""},
+		{"legacy path", http.MethodPost, "/kiosk/register", RegisterKioskRequest{Name: "Booth 1"}, admin, http.StatusCreated, ""},
+		{"malformed json", http.MethodPost, apiPrefix + "/admin/kiosks/register", `{"name": }`, admin, http.StatusBadRequest, CodeInvalidJSON},
+		{"missing name", http.MethodPost, apiPrefix + "/admin/kiosks/register", `{"event": "fair"}`, admin, http.StatusBadRequest, CodeMissingFields},
+		{"unknown event", http.MethodPost, apiPrefix + "/admin/kiosks/register", RegisterKioskRequest{Name: "Booth 1", Event: "nope"}, admin, http.StatusNotFound, CodeEventNotFound},
+		{"wrong method", http.MethodGet, apiPrefix + "/admin/kiosks/register", nil, admin, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"no credentials", http.MethodPost, apiPrefix + "/admin/kiosks/register", RegisterKioskRequest{Name: "Booth 1"}, nil, http.StatusUnauthorized, CodeAuthRequired},
+	}, nil)
+
+	s := newTestServer(t)
+	first, _ := s.registerKiosk("fair")
+	second, _ := s.registerKiosk("")
+	if first == second {
+		t.Errorf("both kiosks got the ID %s", first)
+	}
+	if err := s.srv.loadKiosks(); err != nil || len(s.srv.kiosks) != 2 {
+		t.Errorf("reloaded %d kiosks from disk (%v), want 2", len(s.srv.kiosks), err)
+	}
+}
+
+func TestKioskHeartbeat(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"no token", http.MethodPost, apiPrefix + "/kiosk/heartbeat", nil, nil, http.StatusUnauthorized, CodeKioskTokenRequired},
+		{"unknown token", http.MethodPost, apiPrefix + "/kiosk/heartbeat", nil, map[string]string{"X-Kiosk-Token": "nope"}, http.StatusUnauthorized, CodeUnknownKiosk},
+		{"wrong method", http.MethodGet, apiPrefix + "/kiosk/heartbeat", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	s := newTestServer(t)
+	id, kiosk := s.registerKiosk("fair")
+	rec := s.do(http.MethodPost, apiPrefix+"/kiosk/heartbeat", nil, kiosk)
+	beat := decodeBody[HeartbeatResponse](t, rec)
+	if rec.Code != http.StatusOK || beat.KioskID != id || beat.Event != "fair" || beat.ServerTime == "" {
+		t.Errorf("heartbeat = %d %+v", rec.Code, beat)
+	}
+}
+
+func TestListAndDisableKiosks(t *testing.T) {
+	admin := map[string]string{"X-API-Key": "admin-key"}
+	runHandlerCases(t, []handlerCase{
+		{"listed", http.MethodGet, apiPrefix + "/admin/kiosks", nil, admin, http.StatusOK, ""},
+		{"list wrong method", http.MethodDelete, apiPrefix + "/admin/kiosks", nil, admin, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"disable unknown kiosk", http.MethodPost, apiPrefix + "/admin/kiosks/disable", SetKioskDisabledRequest{KioskID: "kiosk-nope", Disabled: true}, admin, http.StatusNotFound, CodeKioskNotFound},
+		{"disable malformed json", http.MethodPost, apiPrefix + "/admin/kiosks/disable", `{"kioskId": 1}`, admin, http.StatusBadRequest, CodeInvalidJSON},
+		{"disable wrong method", http.MethodGet, apiPrefix + "/admin/kiosks/disable", nil, admin, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	s := newTestServer(t)
+	id, kiosk := s.registerKiosk("fair")
+	s.do(http.MethodPost, apiPrefix+"/kiosk/heartbeat", nil, kiosk)
+
+	list := decodeBody[KioskListResponse](t, s.do(http.MethodGet, apiPrefix+"/admin/kiosks", nil, admin))
+	if len(list.Kiosks) != 1 || list.Kiosks[0].ID != id || !list.Kiosks[0].Online || list.Kiosks[0].Disabled {
+		t.Fatalf("kiosks = %+v, want %s online", list.Kiosks, id)
+	}
+
+	rec := s.do(http.MethodPost, apiPrefix+"/admin/kiosks/disable", SetKioskDisabledRequest{KioskID: id, Disabled: true}, admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("disable: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	rec = s.do(http.MethodGet, apiPrefix+"/choose-questions", nil, kiosk)
+	if rec.Code != http.StatusForbidden || decodeBody[ErrorResponse](t, rec).Code != CodeKioskDisabled {
+		t.Errorf("disabled kiosk request: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	list = decodeBody[KioskListResponse](t, s.do(http.MethodGet, apiPrefix+"/admin/kiosks", nil, admin))
+	if len(list.Kiosks) != 1 || list.Kiosks[0].Online || !list.Kiosks[0].Disabled {
+		t.Errorf("kiosks = %+v, want %s disabled and offline", list.Kiosks, id)
+	}
+
+	s.do(http.MethodPost, apiPrefix+"/admin/kiosks/disable", SetKioskDisabledRequest{KioskID: id, Disabled: false}, admin)
+	if rec := s.do(http.MethodGet, apiPrefix+"/choose-questions", nil, kiosk); rec.Code != http.StatusOK {
+		t.Errorf("re-enabled kiosk request: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestKiosksRequired(t *testing.T) {
+	s := newTestServer(t)
+	_, kiosk := s.registerKiosk("")
+
+	// Off by default, so browsers in development need no token
+	if rec := s.do(http.MethodGet, apiPrefix+"/choose-questions", nil, nil); rec.Code != http.StatusOK {
+		t.Errorf("without a token: status = %d, want 200 with kiosks.required off", rec.Code)
+	}
+
+	s.srv.config.Kiosks.Required = true
+	for _, tt := range []struct {
+		method, path string
+		header       map[string]string
+		want         int
+	}{
+		{http.MethodGet, "/choose-questions", nil, http.StatusUnauthorized},
+		{http.MethodPost, "/user/create", nil, http.StatusUnauthorized},
+		{http.MethodPost, "/process", nil, http.StatusUnauthorized},
+		{http.MethodGet, "/choose-questions", kiosk, http.StatusOK},
+		{http.MethodGet, "/events", nil, http.StatusOK},
+		{http.MethodGet, "/admin/kiosks", map[string]string{"X-API-Key": "admin-key"}, http.StatusOK},
+	} {
+		rec := s.do(tt.method, apiPrefix+tt.path, nil, tt.header)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && decodeBody[ErrorResponse](t, rec).Code != CodeKioskTokenRequired {
+			t.Errorf("%s %s: body %q, want %s", tt.method, tt.path, rec.Body.String(), CodeKioskTokenRequired)
+		}
+	}
+}
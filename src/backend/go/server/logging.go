@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	requestIDContextKey contextKey = "requestID"
+	logFieldsContextKey contextKey = "logFields"
+)
+
+// Incoming X-Request-ID values are reused only when they look like an ID
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// logFields holds the attributes added to every log line of a request
+// Handlers add fields as they learn them (session, email hash, profile), so it is shared by pointer
+type logFields struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// contextHandler adds the request fields stored in the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if fields, ok := ctx.Value(logFieldsContextKey).(*logFields); ok {
+		fields.mu.Lock()
+		record.AddAttrs(fields.attrs...)
+		fields.mu.Unlock()
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// SetupLogging installs the default slog logger
+// level is debug, info, warn or error; format is json or text
+func SetupLogging(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return fmt.Errorf("invalid log format %q (use json or text)", format)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// envOrDefault returns the environment variable or the fallback when it is unset
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// withLogFields returns a context with its own field set, starting from the parent's fields
+func withLogFields(ctx context.Context) context.Context {
+	fields := &logFields{}
+	if parent, ok := ctx.Value(logFieldsContextKey).(*logFields); ok {
+		parent.mu.Lock()
+		fields.attrs = append(fields.attrs, parent.attrs...)
+		parent.mu.Unlock()
+	}
+	return context.WithValue(ctx, logFieldsContextKey, fields)
+}
+
+// addLogFields adds key/value pairs to the fields logged for the request in ctx
+// A key that is already present is replaced
+func addLogFields(ctx context.Context, args ...any) {
+	fields, ok := ctx.Value(logFieldsContextKey).(*logFields)
+	if !ok {
+		return
+	}
+
+	record := slog.NewRecord(time.Time{}, 0, "", 0)
+	record.Add(args...)
+
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+	record.Attrs(func(attr slog.Attr) bool {
+		for i := range fields.attrs {
+			if fields.attrs[i].Key == attr.Key {
+				fields.attrs[i] = attr
+				return true
+			}
+		}
+		fields.attrs = append(fields.attrs, attr)
+		return true
+	})
+}
+
+// logSession adds the session ID and the hashed email to the request's log fields
+func logSession(ctx context.Context, userEmail, sessionID string) {
+	if sessionID != "" {
+		addLogFields(ctx, "session_id", sessionID)
+	}
+	if userEmail != "" {
+		addLogFields(ctx, "email_hash", hashEmail(userEmail))
+	}
+}
+
+// hashEmail returns a short stable hash of an email so logs can correlate players without storing addresses
+func hashEmail(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:8])
+}
+
+// requestID returns the ID assigned to the request in ctx
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// loggingMiddleware assigns a request ID, echoes it as X-Request-ID and logs each request
+// with its status and duration; it runs first so every other log line carries the ID
+func loggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			generated, err := randomHex(8)
+			if err != nil {
+				generated = fmt.Sprintf("%x", start.UnixNano())
+			}
+			id = generated
+		}
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		ctx = withLogFields(ctx)
+		addLogFields(ctx, "request_id", id)
+		r = r.WithContext(ctx)
+
+		slog.DebugContext(ctx, "request started", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if recorder.status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+		slog.Log(ctx, level, "request completed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"remote_addr", r.RemoteAddr,
+		)
+	}
+}
@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"delfos/prizes"
+	"delfos/questions"
+	"delfos/scoring"
+	"delfos/sessions"
+)
+
+// Latency buckets in seconds for the request duration histogram
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// counterVec is a Prometheus counter partitioned by label values
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// histogramVec is a Prometheus histogram partitioned by label values
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Cumulative count per bucket, the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogram{}}
+}
+
+// Inc adds one to the counter for the given label values
+func (c *counterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta to the counter for the given label values
+func (c *counterVec) Add(delta float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	c.values[key] += delta
+	c.mu.Unlock()
+}
+
+// Observe records a sample in the histogram for the given label values
+func (h *histogramVec) Observe(sample float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = series
+	}
+	for i, bound := range h.buckets {
+		if sample <= bound {
+			series.counts[i]++
+		}
+	}
+	series.counts[len(h.buckets)]++
+	series.sum += sample
+	series.count++
+}
+
+var (
+	httpRequests = newCounterVec("delfos_http_requests_total",
+		"HTTP requests handled, by route, method and status code.", "route", "method", "status")
+	httpDuration = newHistogramVec("delfos_http_request_duration_seconds",
+		"HTTP request latency in seconds, by route.", latencyBuckets, "route")
+	quizzesStarted = newCounterVec("delfos_quizzes_started_total",
+		"Question sets drawn, by event and profile.", "event", "profile")
+	quizzesCompleted = newCounterVec("delfos_quizzes_completed_total",
+		"Quizzes evaluated, by event and profile.", "event", "profile")
+	quizzesPassed = newCounterVec("delfos_quizzes_passed_total",
+		"Quizzes evaluated with a passing score, by event and profile.", "event", "profile")
+	prizesAwarded = newCounterVec("delfos_prizes_awarded_total",
+		"Prizes awarded since the server started, by event and SKU.", "event", "sku")
+	storageErrors = newCounterVec("delfos_storage_errors_total",
+		"Failed reads or writes of the data directory, by operation.", "operation")
+	rateLimited = newCounterVec("delfos_rate_limited_total",
+		"Requests rejected with 429 by the rate limiter, by route class.", "class")
+)
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying connection (used by the WebSocket upgrade)
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// metricsMiddleware counts requests and observes their latency per route
+// Routes are registered as exact paths, so the URL path is a bounded label
+func metricsMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next(recorder, r)
+
+		httpRequests.Inc(routePath(r), r.Method, strconv.Itoa(recorder.status))
+		httpDuration.Observe(time.Since(start).Seconds(), routePath(r))
+	}
+}
+
+// recordQuizCompleted counts an evaluated quiz and whether it reached the pass score
+func recordQuizCompleted(event *Event, questionIDs []string, evaluation scoring.Score, passScore float64) {
+	if len(questionIDs) == 0 {
+		return
+	}
+	profile := questions.Profile(questionIDs[0])
+	quizzesCompleted.Inc(event.ID, profile)
+	if evaluation.Passed(passScore) {
+		quizzesPassed.Inc(event.ID, profile)
+	}
+}
+
+// serveMetrics handles exposing the server metrics in the Prometheus text format
+// It expects a GET request; session and stock gauges are computed from the data directory on each scrape
+func (srv *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	var b strings.Builder
+	httpRequests.write(&b, "counter")
+	httpDuration.write(&b)
+	quizzesStarted.write(&b, "counter")
+	quizzesCompleted.write(&b, "counter")
+	quizzesPassed.write(&b, "counter")
+	prizesAwarded.write(&b, "counter")
+	storageErrors.write(&b, "counter")
+	rateLimited.write(&b, "counter")
+	srv.writeEventGauges(&b)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
+
+// writeEventGauges writes the active session and remaining stock gauges of every event
+func (srv *Server) writeEventGauges(b *strings.Builder) {
+	srv.eventsMu.RLock()
+	list := make([]*Event, 0, len(srv.events))
+	for _, event := range srv.events {
+		list = append(list, event)
+	}
+	srv.eventsMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	active := newCounterVec("delfos_active_sessions",
+		"Registered sessions that have not submitted answers yet, by event.", "event")
+	conversations := newCounterVec("delfos_active_conversations",
+		"Conversation flow sessions in progress, by event.", "event")
+	stock := newCounterVec("delfos_prize_stock_remaining",
+		"Physical prizes left to award, by event and SKU.", "event", "sku")
+
+	for _, event := range list {
+		count, err := countActiveSessions(event.DataDir())
+		if err != nil {
+			storageErrors.Inc("count_sessions")
+		}
+		active.Add(float64(count), event.ID)
+
+		awards, err := prizes.ReadAwards(context.Background(), event.DataDir())
+		if err != nil {
+			continue
+		}
+		awarded := map[string]int{}
+		for _, award := range awards {
+			awarded[award.SKU]++
+		}
+		for _, prize := range event.Prizes {
+			if prize.Physical() {
+				stock.Add(float64(max(prize.Stock-awarded[prize.SKU], 0)), event.ID, prize.SKU)
+			}
+		}
+	}
+
+	srv.sessionsMu.Lock()
+	for _, session := range srv.flowSessions {
+		if !session.Done {
+			conversations.Inc(session.EventID)
+		}
+	}
+	srv.sessionsMu.Unlock()
+
+	active.write(b, "gauge")
+	conversations.write(b, "gauge")
+	stock.write(b, "gauge")
+}
+
+// countActiveSessions counts the session files in a data directory that are still in progress
+func countActiveSessions(dataDir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dataDir, "*_*.txt"))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return count, err
+		}
+		if strings.HasPrefix(string(content), "UserEmail: ") && sessions.InProgress(string(content)) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// write renders the counter family in the Prometheus text format
+func (c *counterVec) write(b *strings.Builder, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", c.name, c.help, c.name, kind)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(b, "%s%s %s\n", c.name, formatLabels(c.labels, key, ""), formatValue(c.values[key]))
+	}
+}
+
+// write renders the histogram family in the Prometheus text format
+func (h *histogramVec) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		for i, bound := range h.buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, le), series.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "+Inf"), series.counts[len(h.buckets)])
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatValue(series.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), series.count)
+	}
+}
+
+// formatLabels renders {name="value",...} for a series key, adding le for histogram buckets
+func formatLabels(names []string, key, le string) string {
+	var pairs []string
+	if len(names) > 0 {
+		for i, value := range strings.Split(key, "\xff") {
+			if i < len(names) {
+				pairs = append(pairs, fmt.Sprintf("%s=%s", names[i], strconv.Quote(value)))
+			}
+		}
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=%q", le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatValue renders a sample value the way Prometheus expects
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
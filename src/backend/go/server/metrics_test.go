@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"delfos/api"
+)
+
+func TestServeMetrics(t *testing.T) {
+	s := newTestServer(t)
+	if rec := s.do(http.MethodPost, apiPrefix+"/metrics", nil, nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /metrics: status = %d, want 405", rec.Code)
+	}
+
+	createTestUser(s, "fair", "a@example.com", "s-1")
+	s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-2"}, nil)
+
+	rec := s.do(http.MethodGet, apiPrefix+"/metrics", nil, nil)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("status = %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		`delfos_active_sessions{event="fair"} 1`,
+		`delfos_http_requests_total{`,
+		`delfos_prize_stock_remaining{event="default",sku="TERMO"} 40`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("metrics do not contain %s", want)
+		}
+	}
+}
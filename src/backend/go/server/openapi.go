@@ -0,0 +1,354 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Swagger UI 5.18.2 (Apache-2.0), see swagger-ui/README.md
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// openAPIDocument is an OpenAPI 3.0 document, with only the parts this API uses
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIServer struct {
+	URL         string `json:"url"`
+	Description string `json:"description"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"` // path, query or header
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema        `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// apiParam is a query or header parameter of a route
+type apiParam struct {
+	in          string
+	name        string
+	description string
+	required    bool
+}
+
+// eventParams select the event of the request, see requestEvent
+var eventParams = []apiParam{
+	{"query", "event", "Event ID; defaults to the kiosk's event, then to the default event", false},
+	{"header", "X-Event-ID", "Event ID, when the event query parameter is not set", false},
+}
+
+// openAPIEnums lists the values of the named string types that have a fixed set
+var openAPIEnums = map[reflect.Type][]string{
+	reflect.TypeFor[Role]():       {string(RoleViewer), string(RoleStaff), string(RoleAdmin)},
+	reflect.TypeFor[EventState](): {string(EventOpen), string(EventNotStarted), string(EventOutsideHours), string(EventEnded)},
+}
+
+// openAPISpec is the encoded document, built once from the route table
+var openAPISpec = sync.OnceValues(func() ([]byte, error) {
+	spec, err := json.MarshalIndent(buildOpenAPI(), "", "  ")
+	return append(spec, '\n'), err
+})
+
+// buildOpenAPI describes every documented route from its handler and its request and response types
+// Fields tagged omitempty or omitzero are optional, every other field is required
+func buildOpenAPI() *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title: "DelfosProfiler API",
+			Description: "Quiz, event, prize and kiosk API of the DelfosProfiler booth. Every path is also served " +
+				"without the " + apiPrefix + " prefix as a legacy alias. Errors use the ErrorResponse envelope.",
+			Version: "1.0.0",
+		},
+		Servers: []openAPIServer{{URL: apiPrefix, Description: "This server"}},
+		Paths:   map[string]map[string]*openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]*openAPISchema{},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"kioskToken":  {Type: "apiKey", In: "header", Name: "X-Kiosk-Token", Description: "Token returned when the kiosk was registered"},
+				"adminBearer": {Type: "http", Scheme: "bearer", Description: "Operator session token from /admin/login, or an API key"},
+				"adminApiKey": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "Operator API key"},
+				"adminCookie": {Type: "apiKey", In: "cookie", Name: adminSessionCookie, Description: "Session cookie set by /admin/login"},
+			},
+		},
+	}
+	errorSchema := doc.schema(reflect.TypeFor[ErrorResponse]())
+
+	for _, rt := range routes {
+		if rt.summary == "" {
+			continue
+		}
+		op := &openAPIOperation{
+			OperationID: handlerName(rt.handler),
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Responses: map[string]*openAPIResponse{
+				"default": {Description: "Error", Content: jsonContent(errorSchema)},
+			},
+		}
+
+		for _, p := range rt.params {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name: p.name, In: p.in, Description: p.description, Required: p.required,
+				Schema: &openAPISchema{Type: "string"},
+			})
+		}
+		if rt.request != nil {
+			op.RequestBody = &openAPIBody{Required: true, Content: jsonContent(doc.schema(reflect.TypeOf(rt.request)))}
+		}
+
+		status := rt.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := &openAPIResponse{Description: http.StatusText(status)}
+		switch body := rt.response.(type) {
+		case nil:
+		case string: // Media type of a body that is not JSON
+			response.Content = map[string]openAPIMediaType{body: {Schema: &openAPISchema{Type: "string"}}}
+		default:
+			response.Content = jsonContent(doc.schema(reflect.TypeOf(body)))
+		}
+		op.Responses[strconv.Itoa(status)] = response
+
+		switch {
+		case rt.admin && rt.role != "":
+			op.Description = "Requires the " + string(rt.role) + " role or higher."
+			op.Security = []map[string][]string{{"adminBearer": {}}, {"adminApiKey": {}}, {"adminCookie": {}}}
+		case rt.kiosk:
+			op.Security = []map[string][]string{{"kioskToken": {}}}
+		case !rt.admin:
+			op.Security = []map[string][]string{{}, {"kioskToken": {}}}
+			if rt.player {
+				op.Description = "Requires X-Kiosk-Token when the server sets kiosks.required."
+			}
+		}
+
+		if doc.Paths[rt.path] == nil {
+			doc.Paths[rt.path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+	return doc
+}
+
+// schema returns the schema of a Go type, adding named structs to the components
+func (doc *openAPIDocument) schema(t reflect.Type) *openAPISchema {
+	if t.Kind() == reflect.Pointer {
+		return doc.schema(t.Elem())
+	}
+	if values, ok := openAPIEnums[t]; ok {
+		return &openAPISchema{Type: "string", Enum: values}
+	}
+
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case reflect.TypeFor[json.RawMessage]():
+		return &openAPISchema{} // Any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Interface:
+		return &openAPISchema{}
+	case reflect.Struct:
+		ref := &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := doc.Components.Schemas[t.Name()]; ok {
+			return ref
+		}
+		object := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+		doc.Components.Schemas[t.Name()] = object // Before the fields, for recursive types
+		doc.addFields(object, t)
+		return ref
+	}
+	panic("openapi: unsupported type " + t.String())
+}
+
+// addFields adds the JSON fields of a struct to an object schema
+// Untagged embedded structs are inlined, as encoding/json does
+func (doc *openAPIDocument) addFields(object *openAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			doc.addFields(object, field.Type)
+			continue
+		}
+		name, optional, ok := jsonField(field)
+		if !ok {
+			continue
+		}
+		object.Properties[name] = doc.schema(field.Type)
+		if !optional {
+			object.Required = append(object.Required, name)
+		}
+	}
+}
+
+// jsonField returns the JSON name of a struct field and whether it may be left out
+func jsonField(field reflect.StructField) (name string, optional bool, ok bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range strings.Split(options, ",") {
+		if option == "omitempty" || option == "omitzero" {
+			optional = true
+		}
+	}
+	return name, optional, true
+}
+
+func jsonContent(schema *openAPISchema) map[string]openAPIMediaType {
+	return map[string]openAPIMediaType{"application/json": {Schema: schema}}
+}
+
+// handlerName returns the function name of a handler, used as the operation ID
+func handlerName(handler func(*Server, http.ResponseWriter, *http.Request)) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// serveOpenAPI handles serving the OpenAPI document of the API
+// It expects a GET request
+func (srv *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	spec, err := openAPISpec()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode openapi document", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(spec)
+}
+
+// serveAPIDocs handles serving the bundled Swagger UI under /docs/
+// It expects a GET request; the page loads the document from ../openapi.json
+func (srv *Server) serveAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	_, name, _ := strings.Cut(r.URL.Path, "/docs/")
+	if name == "" {
+		name = "index.html"
+	}
+	name = "swagger-ui/" + name
+	if info, err := fs.Stat(swaggerUI, name); err != nil || info.IsDir() || strings.HasSuffix(name, ".md") {
+		notFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, swaggerUI, name)
+}
+
+// runOpenAPI prints the OpenAPI document, to refresh docs/openapi.json
+func runOpenAPI(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) > 0 {
+		return errors.New("openapi takes no arguments")
+	}
+	spec, err := openAPISpec()
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(spec)
+	return err
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"delfos/api"
+	"delfos/questions"
+)
+
+// openAPIGolden is the committed document; refresh it with "go run . openapi > ../../../../docs/openapi.json" from cmd
+const openAPIGolden = "../../../../docs/openapi.json"
+
+func TestOpenAPIGolden(t *testing.T) {
+	want, err := os.ReadFile(openAPIGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := openAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("docs/openapi.json is out of date with the route table and its types; regenerate it with the openapi command")
+	}
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	srv := newRouteServer(t)
+	spec, _ := openAPISpec()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{apiPrefix + "/openapi.json", http.StatusOK, string(spec)},
+		{"/openapi.json", http.StatusOK, string(spec)},
+		{apiPrefix + "/docs/", http.StatusOK, "swagger-initializer.js"},
+		{apiPrefix + "/docs/swagger-initializer.js", http.StatusOK, "openapi.json"},
+		{apiPrefix + "/docs/README.md", http.StatusNotFound, CodeRouteNotFound},
+		{apiPrefix + "/docs/nope.js", http.StatusNotFound, CodeRouteNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+			t.Errorf("GET %s: status = %d, want %d with %q", tt.path, rec.Code, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+// specClient calls the API through a server and checks every request and response body against the committed document
+type specClient struct {
+	t       *testing.T
+	srv     *Server
+	doc     openAPIDocument
+	covered map[string]bool // "METHOD path" of the operations answered with their documented status
+}
+
+func newSpecClient(t *testing.T) *specClient {
+	content, err := os.ReadFile(openAPIGolden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &specClient{t: t, srv: newRouteServer(t), covered: map[string]bool{}}
+	if err := json.Unmarshal(content, &c.doc); err != nil {
+		t.Fatalf("docs/openapi.json: %v", err)
+	}
+	return c
+}
+
+// call sends the request and returns the decoded response body
+func (c *specClient) call(method, path string, body any, header map[string]string) map[string]any {
+	c.t.Helper()
+	route, _, _ := strings.Cut(path, "?")
+	route = c.documentedPath(route)
+	op := c.doc.Paths[route][strings.ToLower(method)]
+	if op == nil {
+		c.t.Fatalf("%s %s is not in the document", method, route)
+	}
+
+	var reqBody []byte
+	if body != nil {
+		reqBody, _ = json.Marshal(body)
+		if op.RequestBody == nil {
+			c.t.Errorf("%s %s: sent a body, the document has none", method, route)
+		} else {
+			c.validate(method+" "+route+" request", op.RequestBody.Content["application/json"].Schema, reqBody)
+		}
+	}
+
+	req := httptest.NewRequest(method, apiPrefix+path, bytes.NewReader(reqBody))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	c.srv.ServeHTTP(rec, req)
+
+	response, documented := op.Responses[strconv.Itoa(rec.Code)]
+	if documented {
+		c.covered[method+" "+route] = true
+	} else {
+		response = op.Responses["default"]
+	}
+	if _, ok := response.Content["application/json"]; !ok {
+		if got := rec.Header().Get("Content-Type"); !documented || !strings.HasPrefix(got, "text/plain") {
+			c.t.Errorf("%s %s: status %d with Content-Type %q is not documented", method, route, rec.Code, got)
+		}
+		return nil
+	}
+	c.validate(fmt.Sprintf("%s %s %d response", method, route, rec.Code), response.Content["application/json"].Schema, rec.Body.Bytes())
+
+	var decoded map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &decoded)
+	return decoded
+}
+
+// documentedPath returns the document path a request path matches, filling {name} segments with any value
+func (c *specClient) documentedPath(path string) string {
+	segments := strings.Split(path, "/")
+	for documented := range c.doc.Paths {
+		pattern := strings.Split(documented, "/")
+		if len(pattern) != len(segments) {
+			continue
+		}
+		matches := true
+		for i, segment := range pattern {
+			if segment != segments[i] && !strings.HasPrefix(segment, "{") {
+				matches = false
+				break
+			}
+		}
+		if matches && strings.Contains(documented, "{") {
+			return documented
+		}
+	}
+	return path
+}
+
+func (c *specClient) validate(what string, schema *openAPISchema, body []byte) {
+	c.t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		c.t.Errorf("%s: %v (%q)", what, err, body)
+		return
+	}
+	for _, problem := range c.check("$", schema, value) {
+		c.t.Errorf("%s: %s", what, problem)
+	}
+}
+
+// check returns where value does not match schema: missing required fields, unknown fields and wrong types
+func (c *specClient) check(at string, schema *openAPISchema, value any) []string {
+	if ref, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		schema = c.doc.Components.Schemas[ref]
+	}
+	if schema.Type == "" {
+		return nil // Any JSON value
+	}
+
+	var problems []string
+	mismatch := func() []string {
+		return []string{fmt.Sprintf("%s is %T, want %s", at, value, schema.Type)}
+	}
+	switch v := value.(type) {
+	case nil:
+		// Go encodes nil slices, maps and pointers as null
+		if schema.Type != "array" && schema.Type != "object" {
+			return mismatch()
+		}
+	case string:
+		if schema.Type != "string" {
+			return mismatch()
+		}
+		if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, v) {
+			problems = append(problems, fmt.Sprintf("%s is %q, want one of %v", at, v, schema.Enum))
+		}
+	case bool:
+		if schema.Type != "boolean" {
+			return mismatch()
+		}
+	case json.Number:
+		if schema.Type != "number" && (schema.Type != "integer" || strings.ContainsAny(v.String(), ".eE")) {
+			return mismatch()
+		}
+	case []any:
+		if schema.Type != "array" {
+			return mismatch()
+		}
+		for i, item := range v {
+			problems = append(problems, c.check(fmt.Sprintf("%s[%d]", at, i), schema.Items, item)...)
+		}
+	case map[string]any:
+		if schema.Type != "object" {
+			return mismatch()
+		}
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required", at, name))
+			}
+		}
+		for name, field := range v {
+			property := schema.Properties[name]
+			if property == nil {
+				property = schema.AdditionalProperties
+			}
+			if property == nil {
+				problems = append(problems, fmt.Sprintf("%s.%s is not in the document", at, name))
+				continue
+			}
+			problems = append(problems, c.check(at+"."+name, property, field)...)
+		}
+	}
+	return problems
+}
+
+// TestOpenAPIConformance walks every documented operation through its success path, so a handler
+// that sends or reads something other than its documented types fails here
+func TestOpenAPIConformance(t *testing.T) {
+	c := newSpecClient(t)
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.srv.config.Admin.Users = []AdminUser{{Username: "ana", PasswordHash: hash, Role: RoleAdmin}}
+
+	// Operators
+	login := c.call(http.MethodPost, "/admin/login", AdminLoginRequest{Username: "ana", Password: "correct horse"}, nil)
+	admin := map[string]string{"Authorization": fmt.Sprintf("Bearer %v", login["token"])}
+	c.call(http.MethodGet, "/admin/me", nil, admin)
+	registered := c.call(http.MethodPost, "/admin/kiosks/register", RegisterKioskRequest{Name: "Booth 1"}, admin)
+	kiosk := map[string]string{"X-Kiosk-Token": fmt.Sprint(registered["token"])}
+	c.call(http.MethodGet, "/admin/kiosks", nil, admin)
+
+	// Kiosks
+	c.call(http.MethodPost, "/kiosk/heartbeat", nil, kiosk)
+	c.call(http.MethodPost, "/sync", SyncRequest{Records: []SyncRecord{{
+		ID: "r-1", Type: "registration", UserEmail: "b@example.com", SessionID: "s-2",
+		RecordedAt: "2026-01-01T10:00:00Z", Signature: "00",
+	}}}, kiosk)
+
+	// Quiz
+	c.call(http.MethodGet, "/events", nil, nil)
+	c.call(http.MethodGet, "/event/status", nil, nil)
+	c.call(http.MethodGet, "/choose-questions?profile=1", nil, kiosk)
+	c.call(http.MethodGet, "/question?id=CRD0001", nil, nil)
+	c.call(http.MethodGet, "/answer?question_id=CRD0001", nil, nil)
+	c.call(http.MethodPost, "/user/create", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-1"}, kiosk)
+	issued := c.call(http.MethodGet, "/choose-questions?profile=1&userEmail=a@example.com&sessionId=s-1", nil, kiosk)
+	var questionIDs, answers []string
+	for _, number := range issued["questionIds"].([]any) {
+		n, _ := strconv.Atoi(fmt.Sprint(number))
+		id := questions.FormatID("1", n)
+		answer, _ := questions.FindAnswer(id)
+		questionIDs, answers = append(questionIDs, id), append(answers, answer.Answer)
+	}
+	c.call(http.MethodGet, "/question?id="+questionIDs[0]+"&userEmail=a@example.com&sessionId=s-1", nil, kiosk)
+	answers = shownAnswers(&testServer{t: t, srv: c.srv}, "default", "a@example.com", "s-1", questionIDs, answers)
+	c.call(http.MethodPost, "/user/update", api.UpdateUserRequest{
+		UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1}, UserAnswers: []string{"a"},
+	}, kiosk)
+	evaluated := c.call(http.MethodPost, "/evaluate-answers", api.EvaluateAnswersRequest{
+		QuestionIds: questionIDs, UserAnswers: answers, UserEmail: "a@example.com", SessionID: "s-1",
+	}, kiosk)
+	receipt := fmt.Sprint(evaluated["receipt"])
+
+	// Answers one at a time, scored from what the server holds
+	created := c.call(http.MethodPost, "/user/create", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-3"}, kiosk)
+	drawn := c.call(http.MethodGet, "/choose-questions?profile=1&userEmail=a@example.com&sessionId=s-3", nil, kiosk)
+	for _, number := range drawn["questionIds"].([]any) {
+		n, _ := strconv.Atoi(fmt.Sprint(number))
+		c.call(http.MethodPost, "/session/s-3/answer", api.SubmitAnswerRequest{
+			UserEmail: "a@example.com", QuestionID: questions.FormatID("1", n), Answer: "a",
+		}, kiosk)
+	}
+	c.call(http.MethodPost, "/session/resume", api.ResumeSessionRequest{SessionToken: fmt.Sprint(created["sessionToken"])}, kiosk)
+	c.call(http.MethodPost, "/session/s-3/evaluate", api.EvaluateSessionRequest{UserEmail: "a@example.com"}, kiosk)
+	c.call(http.MethodGet, "/winner/count", nil, kiosk)
+	c.call(http.MethodPost, "/winner/increment", api.WinnerCountRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: receipt}, kiosk)
+	c.call(http.MethodPost, "/prize/draw", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: receipt}, kiosk)
+	c.call(http.MethodPost, "/process", ProcessRequest{SessionID: "flow-1", Input: ""}, nil)
+	c.call(http.MethodGet, "/metrics", nil, nil)
+
+	// Operators again, once there is something to audit
+	c.call(http.MethodPost, "/admin/prizes/redeem", RedeemPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1"}, admin)
+	c.call(http.MethodGet, "/admin/audit?limit=5", nil, admin)
+	c.call(http.MethodPost, "/admin/kiosks/disable", SetKioskDisabledRequest{KioskID: fmt.Sprint(registered["kioskId"]), Disabled: true}, admin)
+	c.call(http.MethodPost, "/admin/logout", nil, admin)
+
+	// Errors use the default response
+	c.call(http.MethodGet, "/question?id=XYZ0001", nil, nil)
+
+	for path, methods := range c.doc.Paths {
+		for method := range methods {
+			name := strings.ToUpper(method) + " " + path
+			if !c.covered[name] && path != "/ws" { // Needs a hijackable connection
+				t.Errorf("%s was not answered with its documented status", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,747 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"delfos/api"
+	"delfos/questions"
+	"delfos/scoring"
+	"delfos/sessions"
+	"delfos/storage"
+)
+
+func (srv *Server) getQuestionByID(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	id := r.URL.Query().Get("id")
+	question, ok := questions.Find(id)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, CodeQuestionNotFound, "Question not found", map[string]string{"id": id})
+		return
+	}
+
+	// A session sees the options in the order it was issued, and answers with those letters
+	userEmail, sessionID := r.URL.Query().Get("userEmail"), r.URL.Query().Get("sessionId")
+	logSession(r.Context(), userEmail, sessionID)
+	if userEmail != "" || sessionID != "" {
+		if missing := missingFields("userEmail", userEmail, "sessionId", sessionID); len(missing) > 0 {
+			writeMissingFields(w, r, missing)
+			return
+		}
+		event, ok := srv.requestEvent(w, r)
+		if !ok {
+			return
+		}
+		content, err := sessions.Read(event.DataDir(), userEmail, sessionID)
+		if errors.Is(err, sessions.ErrNotFound) {
+			writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to read user file", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+		question = questions.Reorder(question, sessions.Issued(content).Orders()[id])
+		if err := srv.recordServed(r.Context(), event, userEmail, sessionID, id); err != nil {
+			slog.ErrorContext(r.Context(), "failed to record question served", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(question)
+}
+
+func (srv *Server) getAnswerByQuestionID(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	questionID := r.URL.Query().Get("question_id")
+	answer, ok := questions.FindAnswer(questionID)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, CodeAnswerNotFound, "Answer not found", map[string]string{"question_id": questionID})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(answer)
+}
+
+func (srv *Server) getQuestionIDs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	// Get profile parameter from query string (default to "1" for CRD questions)
+	profile := r.URL.Query().Get("profile")
+	if profile == "" {
+		profile = "1" // Default to profile 1 (CRD questions)
+	}
+
+	addLogFields(r.Context(), "profile", profile)
+
+	if !event.ProfileEnabled(profile) {
+		slog.WarnContext(r.Context(), "profile not enabled for event")
+		writeError(w, r, http.StatusBadRequest, CodeProfileNotEnabled, "Profile not enabled for this event",
+			map[string]any{"profile": profile, "enabledProfiles": event.Profiles})
+		return
+	}
+
+	numbers := questions.Draw(profile, srv.config.Quiz.QuestionsPerQuiz)
+
+	// A session keeps the set it was first issued, so drawing again cannot fish for known questions
+	userEmail, sessionID := r.URL.Query().Get("userEmail"), r.URL.Query().Get("sessionId")
+	logSession(r.Context(), userEmail, sessionID)
+	started := true
+	if userEmail != "" || sessionID != "" {
+		if missing := missingFields("userEmail", userEmail, "sessionId", sessionID); len(missing) > 0 {
+			writeMissingFields(w, r, missing)
+			return
+		}
+		drawn := make([]string, len(numbers))
+		for i, number := range numbers {
+			drawn[i] = questions.FormatID(profile, number)
+		}
+		issued, err := srv.issueQuestions(r.Context(), event, userEmail, sessionID, shuffleOptions(drawn))
+		if errors.Is(err, sessions.ErrNotFound) {
+			writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+			return
+		}
+		if errors.Is(err, sessions.ErrClosed) {
+			writeError(w, r, http.StatusConflict, CodeSessionClosed, "Session already answered or closed", nil)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to issue questions", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+		// A set issued before was already counted as started
+		started = slices.Equal(issued.QuestionIDs, drawn)
+		profile, numbers = questionNumbers(issued.QuestionIDs)
+	}
+	if started {
+		quizzesStarted.Inc(event.ID, profile)
+	}
+
+	// Create response with profile info
+	response := api.ChooseQuestionsResponse{
+		Event:       event.ID,
+		Profile:     profile,
+		QuestionIds: numbers,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// errAttemptLimit is returned by saveUser for an email that used up the event's attempts
+var errAttemptLimit = errors.New("attempt limit reached")
+
+// saveUser registers a session file, enforcing the event's attempt limit per email
+// The attempts are counted under the same lock as the write, so concurrent registrations cannot
+// both take the last attempt
+func (srv *Server) saveUser(ctx context.Context, event *Event, userEmail, sessionID, kioskID, frontendTimestamp string) (sessions.User, string, error) {
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+	if limit := event.Attempts.MaxAttemptsPerEmail; limit > 0 {
+		attempts, err := sessions.CountAttempts(ctx, event.DataDir(), userEmail)
+		if err != nil {
+			return sessions.User{}, "", err
+		}
+		if attempts >= limit {
+			slog.WarnContext(ctx, "attempt limit reached", "attempts", attempts, "limit", limit)
+			return sessions.User{}, "", errAttemptLimit
+		}
+	}
+	return sessions.Save(ctx, event.DataDir(), userEmail, sessionID, kioskID, frontendTimestamp)
+}
+
+// appendAnswers appends the answers sent in bulk to a session file
+func (srv *Server) appendAnswers(ctx context.Context, event *Event, userEmail, sessionID string, questionIDs, answers []string) error {
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+	return sessions.AppendAnswers(ctx, event.DataDir(), userEmail, sessionID, questionIDs, answers)
+}
+
+// issueQuestions records the quiz drawn for a session and returns the quiz the session holds
+func (srv *Server) issueQuestions(ctx context.Context, event *Event, userEmail, sessionID string, drawn sessions.Issue) (sessions.Issue, error) {
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+	return sessions.IssueQuestions(ctx, event.DataDir(), userEmail, sessionID, drawn)
+}
+
+// shuffleOptions draws a display order for the options of each question, so the letter of a correct
+// answer differs between sessions
+func shuffleOptions(questionIDs []string) sessions.Issue {
+	issue := sessions.Issue{QuestionIDs: questionIDs, OptionOrders: make([]string, len(questionIDs))}
+	for i, id := range questionIDs {
+		if question, ok := questions.Find(id); ok {
+			issue.OptionOrders[i] = questions.Shuffle(len(question.Options))
+		}
+	}
+	return issue
+}
+
+// recordServed records when a session was first shown one of its questions
+func (srv *Server) recordServed(ctx context.Context, event *Event, userEmail, sessionID, questionID string) error {
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+	return sessions.RecordServed(ctx, event.DataDir(), userEmail, sessionID, questionID, time.Now())
+}
+
+// recordAnswered records the frontend's answer times for the served questions a session answered,
+// matched by question number. Times are kept between serving the question and now, so a kiosk clock
+// cannot claim an answer before the question was shown or in the future
+func (srv *Server) recordAnswered(ctx context.Context, event *Event, userEmail, sessionID string, numbers []int, answeredAt []time.Time, now time.Time) error {
+	srv.sessionFileMu.Lock()
+	defer srv.sessionFileMu.Unlock()
+
+	content, err := sessions.Read(event.DataDir(), userEmail, sessionID)
+	if err != nil {
+		return err
+	}
+	timings := sessions.Timings(content)
+	times := map[string]time.Time{}
+	for _, id := range sessions.Issued(content).QuestionIDs {
+		_, number, _ := questions.ParseID(id)
+		i := slices.Index(numbers, number)
+		served := timings[id].ServedAt
+		if i < 0 || i >= len(answeredAt) || served.IsZero() {
+			continue
+		}
+		switch at := answeredAt[i]; {
+		case at.Before(served):
+			times[id] = served
+		case at.After(now):
+			times[id] = now
+		default:
+			times[id] = at
+		}
+	}
+	if len(times) == 0 {
+		return nil
+	}
+	return sessions.RecordAnswered(ctx, event.DataDir(), userEmail, sessionID, times)
+}
+
+// timeBonus returns the configured bonus for fast correct answers
+func (srv *Server) timeBonus() scoring.TimeBonus {
+	return scoring.TimeBonus{Points: srv.config.Quiz.TimeBonusPoints, Window: time.Duration(srv.config.Quiz.TimeBonusWindow)}
+}
+
+// parseAnsweredAt parses the optional answer times of a /user/update request, one per answer
+func parseAnsweredAt(answeredAt []string, answers int) ([]time.Time, error) {
+	if len(answeredAt) == 0 {
+		return nil, nil
+	}
+	if len(answeredAt) != answers {
+		return nil, fmt.Errorf("answeredAt has %d times for %d answers", len(answeredAt), answers)
+	}
+	times := make([]time.Time, len(answeredAt))
+	for i, stamp := range answeredAt {
+		at, err := time.Parse(time.RFC3339Nano, stamp)
+		if err != nil {
+			return nil, fmt.Errorf("answeredAt[%d] must be an RFC3339 timestamp", i)
+		}
+		times[i] = at
+	}
+	return times, nil
+}
+
+// questionNumbers returns the profile and numbers of issued question IDs, as /choose-questions sends them
+func questionNumbers(ids []string) (profile string, numbers []int) {
+	for _, id := range ids {
+		p, number, ok := questions.ParseID(id)
+		if !ok {
+			continue
+		}
+		profile, numbers = p, append(numbers, number)
+	}
+	return profile, numbers
+}
+
+// createUser handles the creation of a new user file
+// It expects a POST request with JSON body containing userEmail and sessionId
+func (srv *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid user create request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	logSession(r.Context(), req.UserEmail, req.SessionID)
+
+	// Validate required fields
+	if missing := missingFields("userEmail", req.UserEmail, "sessionId", req.SessionID); len(missing) > 0 {
+		slog.WarnContext(r.Context(), "missing required fields for user create")
+		writeMissingFields(w, r, missing)
+		return
+	}
+
+	// Both name the session file and are stored as header lines, which these would break out of
+	if sessions.ValidateName(req.UserEmail, req.SessionID) != nil || strings.ContainsAny(req.Timestamp, "\r\n") {
+		slog.WarnContext(r.Context(), "invalid characters in user create request")
+		writeError(w, r, http.StatusBadRequest, CodeInvalidParameter,
+			`userEmail, sessionId and timestamp must not contain line breaks, and userEmail and sessionId no slashes or ".."`, nil)
+		return
+	}
+
+	// Registrations are only accepted while the event is open by the server clock
+	if closed := registrationError(event, time.Now()); closed != nil {
+		writeEventClosed(w, r, closed)
+		return
+	}
+
+	kioskID := requestKioskID(r)
+	user, filename, err := srv.saveUser(r.Context(), event, req.UserEmail, req.SessionID, kioskID, req.Timestamp)
+	if errors.Is(err, sessions.ErrExists) {
+		writeError(w, r, http.StatusConflict, CodeSessionExists, "Session already registered", nil)
+		return
+	}
+	if errors.Is(err, errAttemptLimit) {
+		writeError(w, r, http.StatusForbidden, CodeAttemptLimit, "Attempt limit reached for this event",
+			map[string]int{"limit": event.Attempts.MaxAttemptsPerEmail})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to create user file", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	srv.recordKioskSession(r.Context(), kioskID)
+	srv.auditUserCreated(r.Context(), event, req.UserEmail, req.SessionID, user.CreatedAt)
+	slog.InfoContext(r.Context(), "user registered", "server_timestamp", user.CreatedAt, "frontend_timestamp", req.Timestamp)
+
+	token, err := srv.issueSessionToken(event, req.UserEmail, req.SessionID, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to issue session token", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	// Create response
+	response := api.CreateUserResponse{
+		Status:       "success",
+		Message:      "User session file created successfully",
+		Event:        event.ID,
+		Filename:     filename,
+		User:         user,
+		SessionToken: token,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// updateUser handles updating an existing user file with questions and answers
+// It expects a POST request with JSON body containing userEmail, sessionId, questionIds, and userAnswers
+func (srv *Server) updateUser(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid user update request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	logSession(r.Context(), req.UserEmail, req.SessionID)
+
+	// Validate required fields
+	if missing := missingFields("userEmail", req.UserEmail, "sessionId", req.SessionID); len(missing) > 0 {
+		slog.WarnContext(r.Context(), "missing required fields for user update")
+		writeMissingFields(w, r, missing)
+		return
+	}
+
+	answeredAt, err := parseAnsweredAt(req.AnsweredAt, len(req.UserAnswers))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error(), nil)
+		return
+	}
+
+	if closed := submissionError(event, time.Now()); closed != nil {
+		writeEventClosed(w, r, closed)
+		return
+	}
+
+	// The session file stores the question numbers as sent
+	questionIDs := make([]string, len(req.QuestionIds))
+	for i, id := range req.QuestionIds {
+		questionIDs[i] = strconv.Itoa(id)
+	}
+
+	err = srv.appendAnswers(r.Context(), event, req.UserEmail, req.SessionID, questionIDs, req.UserAnswers)
+	if errors.Is(err, sessions.ErrNotFound) {
+		slog.WarnContext(r.Context(), "user file not found")
+		writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to update user file", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	if len(answeredAt) > 0 {
+		if err := srv.recordAnswered(r.Context(), event, req.UserEmail, req.SessionID, req.QuestionIds, answeredAt, time.Now()); err != nil {
+			slog.ErrorContext(r.Context(), "failed to record answer times", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+	}
+
+	srv.auditAnswersSubmitted(r.Context(), event, req.SessionID, questionIDs, req.UserAnswers)
+	slog.InfoContext(r.Context(), "user answers recorded", "questions", len(req.QuestionIds), "answers", len(req.UserAnswers))
+
+	// Create response
+	response := api.UpdateUserResponse{
+		Status:  "success",
+		Message: fmt.Sprintf("User file updated with %d questions and %d answers", len(req.QuestionIds), len(req.UserAnswers)),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// evaluateAnswers handles the evaluation of user answers
+// It expects a POST request with JSON body containing questionIds and userAnswers
+func (srv *Server) evaluateAnswers(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.EvaluateAnswersRequest
+	_, decodeSpan := startSpan(r.Context(), "request.decode")
+	err := json.NewDecoder(r.Body).Decode(&req)
+	decodeSpan.SetError(err)
+	decodeSpan.End()
+	if err != nil {
+		slog.WarnContext(r.Context(), "invalid evaluation request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	logSession(r.Context(), req.UserEmail, req.SessionID)
+	if len(req.QuestionIds) > 0 {
+		addLogFields(r.Context(), "profile", questions.Profile(req.QuestionIds[0]))
+	}
+
+	// Validate required fields
+	var missing []string
+	if len(req.QuestionIds) == 0 {
+		missing = append(missing, "questionIds")
+	}
+	if len(req.UserAnswers) == 0 {
+		missing = append(missing, "userAnswers")
+	}
+	if len(missing) > 0 {
+		writeMissingFields(w, r, missing)
+		return
+	}
+
+	var invalid *scoring.ValidationError
+	if err := scoring.Validate(req.QuestionIds, req.UserAnswers); errors.As(err, &invalid) {
+		slog.WarnContext(r.Context(), "invalid answers", "error", err)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidAnswers, "Answers do not match the questions", invalid)
+		return
+	}
+
+	if closed := submissionError(event, time.Now()); closed != nil {
+		writeEventClosed(w, r, closed)
+		return
+	}
+
+	// A session is only scored on the questions it was issued
+	hasSession := req.UserEmail != "" && req.SessionID != ""
+	var issued sessions.Issue
+	var timings map[string]scoring.Timing
+	var evaluated bool
+	if hasSession {
+		// Held for the whole evaluation, so concurrent retries record one result
+		srv.sessionFileMu.Lock()
+		defer srv.sessionFileMu.Unlock()
+
+		content, err := sessions.Read(event.DataDir(), req.UserEmail, req.SessionID)
+		if errors.Is(err, sessions.ErrNotFound) {
+			writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to read user file", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+		issued, timings = sessions.Issued(content), sessions.Timings(content)
+		evaluated = sessions.Evaluated(content)
+		if !evaluated && sessions.Expired(content, time.Duration(srv.config.Quiz.SessionTTL), time.Now()) {
+			writeSessionExpired(w, r)
+			return
+		}
+		if !evaluated && sessions.Closed(content) {
+			writeError(w, r, http.StatusConflict, CodeSessionClosed, "Session no longer takes answers", nil)
+			return
+		}
+		if !sameQuestions(issued.QuestionIDs, req.QuestionIds) {
+			slog.WarnContext(r.Context(), "evaluation for questions not issued to the session", "issued", len(issued.QuestionIDs))
+			writeError(w, r, http.StatusConflict, CodeQuestionSetMismatch, "Questions were not issued to this session",
+				map[string][]string{"issued": issued.QuestionIDs})
+			return
+		}
+
+		// Answers the server already holds win over resent ones
+		held := sessions.Answers(content)
+		for i, id := range req.QuestionIds {
+			if answer, ok := held[id]; ok && !strings.EqualFold(strings.TrimSpace(req.UserAnswers[i]), answer) {
+				slog.WarnContext(r.Context(), "evaluation conflicts with a recorded answer", "question_id", id)
+				writeError(w, r, http.StatusConflict, CodeAnswerConflict, "Question was already answered differently",
+					map[string]string{"questionId": id, "answer": answer})
+				return
+			}
+		}
+	}
+
+	score := srv.scoreAnswers(r.Context(), req.QuestionIds, req.UserAnswers, issued, timings)
+	if !evaluated {
+		recordQuizCompleted(event, req.QuestionIds, score, srv.config.Quiz.PassScore)
+	}
+
+	response := api.EvaluateAnswersResponse{
+		Status:  "success",
+		Message: "Answers evaluated successfully",
+		Event:   event.ID,
+		Score:   score,
+	}
+
+	// Record the result in the event and sign a receipt when the session is known; retries of an
+	// evaluated session, whose answers it now holds, sign the receipt again without a second result
+	if hasSession {
+		if !evaluated {
+			if err := srv.recordResult(r.Context(), event, req.UserEmail, req.SessionID, req.QuestionIds, score); err != nil {
+				slog.ErrorContext(r.Context(), "failed to record evaluation result", "error", err)
+				writeInternalError(w, r)
+				return
+			}
+			answers := make(map[string]string, len(req.QuestionIds))
+			for i, id := range req.QuestionIds {
+				answers[id] = strings.ToLower(strings.TrimSpace(req.UserAnswers[i]))
+			}
+			if err := sessions.MarkEvaluated(r.Context(), event.DataDir(), req.UserEmail, req.SessionID, answers, time.Now()); err != nil {
+				slog.ErrorContext(r.Context(), "failed to mark session evaluated", "error", err)
+				writeInternalError(w, r)
+				return
+			}
+		}
+
+		receipt, err := srv.issueReceipt(event, req.UserEmail, req.SessionID, req.QuestionIds, score, srv.config.Quiz.PassScore, time.Now())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to issue evaluation receipt", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+		response.Receipt = receipt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// scoreAnswers grades a session's answers, given as the letters it saw and mapped back to the bank's
+// through its option orders, and times them
+func (srv *Server) scoreAnswers(ctx context.Context, questionIDs, answers []string, issued sessions.Issue, timings map[string]scoring.Timing) scoring.Score {
+	_, span := startSpan(ctx, "quiz.score", "quiz.questions", len(questionIDs))
+	defer span.End()
+	score := scoring.EvaluateDisplayed(questionIDs, answers, issued.Orders())
+	score.AddTimings(timings, srv.timeBonus())
+	span.SetAttributes("quiz.correct", score.CorrectAnswers)
+	return score
+}
+
+// recordResult records the evaluation of a session in the event's results and audit log
+func (srv *Server) recordResult(ctx context.Context, event *Event, userEmail, sessionID string, questionIDs []string, score scoring.Score) error {
+	if err := storage.AppendResult(ctx, event.DataDir(), userEmail, sessionID, score); err != nil {
+		return err
+	}
+	srv.auditEvaluation(ctx, event, sessionID, questionIDs, score, srv.config.Quiz.PassScore)
+	return nil
+}
+
+// sameQuestions reports whether two lists hold the same question IDs, in any order
+func sameQuestions(issued, submitted []string) bool {
+	return len(issued) > 0 && slices.Equal(slices.Sorted(slices.Values(issued)), slices.Sorted(slices.Values(submitted)))
+}
+
+// getWinnerCount handles getting the current winner count
+// It expects a GET request and returns the current winner count
+func (srv *Server) getWinnerCount(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := storage.ReadWinnerCount(r.Context(), event.DataDir())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read winner count", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	response := api.WinnerCountResponse{
+		Status:      "success",
+		Message:     "Winner count retrieved successfully",
+		Event:       event.ID,
+		WinnerCount: count,
+		MaxWinners:  event.Attempts.MaxWinners,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// incrementWinnerCount handles incrementing the winner count
+// It expects a POST request with JSON body containing userEmail, sessionId and the evaluation receipt
+func (srv *Server) incrementWinnerCount(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.WinnerCountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid winner count request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+	logSession(r.Context(), req.UserEmail, req.SessionID)
+
+	if !srv.requireReceipt(w, r, event, req.UserEmail, req.SessionID, req.Receipt) {
+		return
+	}
+
+	if !event.PrizesOpen(time.Now()) {
+		writeEventClosed(w, r, &EventClosedError{Event: event, Code: CodePrizesClosed})
+		return
+	}
+
+	srv.winnerMu.Lock()
+	defer srv.winnerMu.Unlock()
+
+	// Read current count
+	currentCount, err := storage.ReadWinnerCount(r.Context(), event.DataDir())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read winner count", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	// A receipt counts once; a replay gets the current count back, like a repeated prize draw
+	claimed, err := storage.WinnerClaimed(r.Context(), event.DataDir(), req.UserEmail, req.SessionID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read winner claims", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	if claimed {
+		slog.InfoContext(r.Context(), "winner already recorded", "winner_count", currentCount)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(api.WinnerCountResponse{
+			Status:      "success",
+			Message:     "Winner already recorded for this session",
+			Event:       event.ID,
+			WinnerCount: currentCount,
+			MaxWinners:  event.Attempts.MaxWinners,
+		})
+		return
+	}
+
+	// Respect the event's winner cap
+	if event.Attempts.MaxWinners > 0 && currentCount >= event.Attempts.MaxWinners {
+		slog.WarnContext(r.Context(), "winner cap reached", "winner_count", currentCount)
+		writeError(w, r, http.StatusConflict, CodeWinnerLimit, "Winner limit reached for this event",
+			map[string]int{"limit": event.Attempts.MaxWinners})
+		return
+	}
+
+	// Claimed first, so a failure after it can lose a count but never count a session twice
+	if err := storage.AppendWinnerClaim(r.Context(), event.DataDir(), req.UserEmail, req.SessionID); err != nil {
+		slog.ErrorContext(r.Context(), "failed to record winner claim", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	// Increment and write new count
+	newCount := currentCount + 1
+	err = storage.WriteWinnerCount(r.Context(), event.DataDir(), newCount)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to write winner count", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	srv.recordAudit(r.Context(), event, AuditWinnerIncrement, req.SessionID,
+		map[string]int{"winnerCount": currentCount}, map[string]int{"winnerCount": newCount})
+	slog.InfoContext(r.Context(), "winner recorded", "winner_count", newCount)
+
+	response := api.WinnerCountResponse{
+		Status:      "success",
+		Message:     fmt.Sprintf("Winner count updated to %d", newCount),
+		Event:       event.ID,
+		WinnerCount: newCount,
+		MaxWinners:  event.Attempts.MaxWinners,
+		UpdatedAt:   time.Now().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
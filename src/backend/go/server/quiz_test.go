@@ -0,0 +1,801 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"delfos/api"
+	"delfos/questions"
+	"delfos/scoring"
+	"delfos/sessions"
+)
+
+// createTestUser registers a session in an event through /user/create
+func createTestUser(s *testServer, event, email, sessionID string) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, apiPrefix+"/user/create?event="+event, api.CreateUserRequest{UserEmail: email, SessionID: sessionID}, nil)
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("create user: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+// startQuiz registers a session and returns the question IDs /choose-questions issued to it
+func startQuiz(s *testServer, event, email, sessionID string) []string {
+	s.t.Helper()
+	createTestUser(s, event, email, sessionID)
+	rec := s.do(http.MethodGet, apiPrefix+"/choose-questions?event="+event+"&userEmail="+email+"&sessionId="+sessionID, nil, nil)
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("choose questions: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	drawn := decodeBody[api.ChooseQuestionsResponse](s.t, rec)
+	ids := make([]string, len(drawn.QuestionIds))
+	for i, number := range drawn.QuestionIds {
+		ids[i] = questions.FormatID(drawn.Profile, number)
+	}
+	return ids
+}
+
+// testReceipt signs a receipt for a passed, or failed, evaluation of a session with the
+// receipt secret of testConfig
+func testReceipt(t testing.TB, event, email, sessionID string, passed bool) string {
+	t.Helper()
+	score := scoring.Score{TotalQuestions: 1, CorrectAnswers: 1, ScorePercentage: 100}
+	if !passed {
+		score = scoring.Score{TotalQuestions: 1, IncorrectAnswers: 1}
+	}
+	signer := &Server{}
+	signer.config.Quiz.ReceiptSecret = testReceiptSecret
+	receipt, err := signer.issueReceipt(&Event{ID: event}, email, sessionID, []string{"CRD0001"}, score, 75, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return receipt
+}
+
+func TestGetQuestion(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"found", http.MethodGet, apiPrefix + "/question?id=CRD0001", nil, nil, http.StatusOK, ""},
+		{"legacy path", http.MethodGet, "/question?id=EXP0002", nil, nil, http.StatusOK, ""},
+		{"unknown id", http.MethodGet, apiPrefix + "/question?id=CRD9999", nil, nil, http.StatusNotFound, CodeQuestionNotFound},
+		{"missing id", http.MethodGet, apiPrefix + "/question", nil, nil, http.StatusNotFound, CodeQuestionNotFound},
+		{"wrong method", http.MethodPost, apiPrefix + "/question?id=CRD0001", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	s := newTestServer(t)
+	question := decodeBody[questions.Question](t, s.do(http.MethodGet, apiPrefix+"/question?id=SRV0003", nil, nil))
+	if question.ID != "SRV0003" || question.Question == "" || len(question.Options) < 2 {
+		t.Errorf("question = %+v", question)
+	}
+}
+
+func TestQuestionForSession(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"missing session", http.MethodGet, apiPrefix + "/question?id=CRD0001&userEmail=a@example.com", nil, nil, http.StatusBadRequest, CodeMissingFields},
+		{"unknown session", http.MethodGet, apiPrefix + "/question?id=CRD0001&userEmail=a@example.com&sessionId=nope", nil, nil, http.StatusNotFound, CodeUserNotFound},
+		{"question not issued", http.MethodGet, apiPrefix + "/question?id=EXP0001&userEmail=a@example.com&sessionId=s-1", nil, nil, http.StatusOK, ""},
+	}, func(s *testServer) { startQuiz(s, "default", "a@example.com", "s-1") })
+
+	s := newTestServer(t)
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	e, _ := s.srv.getEvent("default")
+	content, _ := sessions.Read(e.DataDir(), "a@example.com", "s-1")
+	orders := sessions.Issued(content).Orders()
+	for _, id := range ids {
+		bank, _ := questions.Find(id)
+		shown := decodeBody[questions.Question](t, s.do(http.MethodGet, apiPrefix+"/question?id="+id+"&userEmail=a@example.com&sessionId=s-1", nil, nil))
+		if want := questions.Reorder(bank, orders[id]); !slices.Equal(shown.Options, want.Options) || len(orders[id]) != len(bank.Options) {
+			t.Errorf("%s options = %v, want them in the issued order %q", id, shown.Options, orders[id])
+		}
+	}
+	content, _ = sessions.Read(e.DataDir(), "a@example.com", "s-1")
+	for id, timing := range sessions.Timings(content) {
+		if timing.ServedAt.IsZero() || !slices.Contains(ids, id) {
+			t.Errorf("%s timing = %+v, want the issued questions served", id, timing)
+		}
+	}
+	if timings := sessions.Timings(content); len(timings) != len(ids) {
+		t.Errorf("%d questions served, want %d", len(timings), len(ids))
+	}
+
+	// Answers use the letters shown to the session; results carry both letters
+	answers := shownAnswers(s, "default", "a@example.com", "s-1", ids, correctAnswers(t, ids))
+	evaluation := api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: answers, UserEmail: "a@example.com", SessionID: "s-1"}
+	result := decodeBody[api.EvaluateAnswersResponse](t, s.do(http.MethodPost, apiPrefix+"/evaluate-answers", evaluation, nil))
+	if result.CorrectAnswers != len(ids) {
+		t.Fatalf("correct answers = %d, want %d", result.CorrectAnswers, len(ids))
+	}
+	for i, r := range result.Results {
+		if r.DisplayedAnswer != answers[i] || r.DisplayedCorrectAnswer != answers[i] || r.UserAnswer != r.CorrectAnswer {
+			t.Errorf("result %+v, want displayed letters %s and bank letters", r, answers[i])
+		}
+	}
+}
+
+func TestResponseTimes(t *testing.T) {
+	s := newTestServer(t)
+	s.srv.config.Quiz.TimeBonusPoints, s.srv.config.Quiz.TimeBonusWindow = 10, Duration(10*time.Second)
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	e, _ := s.srv.getEvent("default")
+
+	// Serve the questions a minute ago; serving again keeps the first time
+	served := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+	for _, id := range ids {
+		if err := sessions.RecordServed(context.Background(), e.DataDir(), "a@example.com", "s-1", id, served); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.do(http.MethodGet, apiPrefix+"/question?id="+ids[0]+"&userEmail=a@example.com&sessionId=s-1", nil, nil)
+
+	// Each answer takes a second longer; the last is wrong and claims a time in the future
+	numbers := make([]int, len(ids))
+	answeredAt := make([]string, len(ids))
+	for i, id := range ids {
+		_, numbers[i], _ = questions.ParseID(id)
+		answeredAt[i] = served.Add(time.Duration(i+1) * time.Second).Format(time.RFC3339Nano)
+	}
+	last := len(ids) - 1
+	answeredAt[last] = served.Add(time.Hour).Format(time.RFC3339Nano)
+	answers := shownAnswers(s, "default", "a@example.com", "s-1", ids, append(correctAnswers(t, ids[:last]), wrongAnswers(t, ids[last:])...))
+	update := api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: numbers, UserAnswers: answers, AnsweredAt: answeredAt}
+	if rec := s.do(http.MethodPost, apiPrefix+"/user/update", update, nil); rec.Code != http.StatusOK {
+		t.Fatalf("update: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	evaluation := api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: answers, UserEmail: "a@example.com", SessionID: "s-1"}
+	result := decodeBody[api.EvaluateAnswersResponse](t, s.do(http.MethodPost, apiPrefix+"/evaluate-answers", evaluation, nil))
+	var total int64
+	var bonus float64
+	for i, r := range result.Results[:last] {
+		wantBonus := float64(10 - (i + 1))
+		if r.ResponseTimeMs != int64(i+1)*1000 || r.TimeBonus != max(wantBonus, 0) {
+			t.Errorf("%s: response time %dms, bonus %v, want %dms and %v", r.QuestionID, r.ResponseTimeMs, r.TimeBonus, (i+1)*1000, max(wantBonus, 0))
+		}
+		total, bonus = total+r.ResponseTimeMs, bonus+r.TimeBonus
+	}
+	if r := result.Results[last]; r.ResponseTimeMs < time.Minute.Milliseconds() || r.ResponseTimeMs > time.Hour.Milliseconds()/2 || r.TimeBonus != 0 {
+		t.Errorf("future answer time: response time %dms, bonus %v, want it clamped to the evaluation and no bonus", r.ResponseTimeMs, r.TimeBonus)
+	}
+	total += result.Results[last].ResponseTimeMs
+	if result.TotalResponseTimeMs != total || result.TimeBonus != bonus || result.ScorePercentage >= 100 {
+		t.Errorf("score = %v%% with bonus %v and total %dms, want bonus %v and total %dms", result.ScorePercentage, result.TimeBonus, result.TotalResponseTimeMs, bonus, total)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(s.srv.config.DataDir, "results.txt"))
+	if want := fmt.Sprintf("|%.2f|%d\n", bonus, total); !strings.HasSuffix(string(content), want) {
+		t.Errorf("results.txt = %q, want a line ending %q", content, want)
+	}
+}
+
+func TestGetAnswer(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"found", http.MethodGet, apiPrefix + "/answer?question_id=CRD0001", nil, nil, http.StatusOK, ""},
+		{"unknown id", http.MethodGet, apiPrefix + "/answer?question_id=nope", nil, nil, http.StatusNotFound, CodeAnswerNotFound},
+		{"wrong method", http.MethodDelete, apiPrefix + "/answer?question_id=CRD0001", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	s := newTestServer(t)
+	answer := decodeBody[questions.Answer](t, s.do(http.MethodGet, apiPrefix+"/answer?question_id=CRD0001", nil, nil))
+	if want, _ := questions.FindAnswer("CRD0001"); answer != want {
+		t.Errorf("answer = %+v, want %+v", answer, want)
+	}
+}
+
+func TestChooseQuestions(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"default profile", http.MethodGet, apiPrefix + "/choose-questions", nil, nil, http.StatusOK, ""},
+		{"enabled profile", http.MethodGet, apiPrefix + "/choose-questions?event=fair&profile=2", nil, nil, http.StatusOK, ""},
+		{"profile not enabled", http.MethodGet, apiPrefix + "/choose-questions?event=fair&profile=3", nil, nil, http.StatusBadRequest, CodeProfileNotEnabled},
+		{"unknown event", http.MethodGet, apiPrefix + "/choose-questions?event=nope", nil, nil, http.StatusNotFound, CodeEventNotFound},
+		{"event header", http.MethodGet, apiPrefix + "/choose-questions?profile=3", nil, map[string]string{"X-Event-ID": "fair"}, http.StatusBadRequest, CodeProfileNotEnabled},
+		{"wrong method", http.MethodPost, apiPrefix + "/choose-questions", "{}", nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	s := newTestServer(t)
+	drawn := decodeBody[api.ChooseQuestionsResponse](t, s.do(http.MethodGet, apiPrefix+"/choose-questions?event=fair&profile=2", nil, nil))
+	if drawn.Event != "fair" || drawn.Profile != "2" || len(drawn.QuestionIds) != s.srv.config.Quiz.QuestionsPerQuiz {
+		t.Fatalf("response = %+v", drawn)
+	}
+	seen := map[int]bool{}
+	for _, number := range drawn.QuestionIds {
+		if _, ok := questions.Find(questions.FormatID("2", number)); !ok || seen[number] {
+			t.Errorf("question numbers %v are not unique questions of profile 2", drawn.QuestionIds)
+		}
+		seen[number] = true
+	}
+}
+
+func TestChooseQuestionsForSession(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"issued", http.MethodGet, apiPrefix + "/choose-questions?userEmail=a@example.com&sessionId=s-1", nil, nil, http.StatusOK, ""},
+		{"missing email", http.MethodGet, apiPrefix + "/choose-questions?sessionId=s-1", nil, nil, http.StatusBadRequest, CodeMissingFields},
+		{"unknown session", http.MethodGet, apiPrefix + "/choose-questions?userEmail=a@example.com&sessionId=s-2", nil, nil, http.StatusNotFound, CodeUserNotFound},
+		{"answered session", http.MethodGet, apiPrefix + "/choose-questions?userEmail=b@example.com&sessionId=s-1", nil, nil, http.StatusConflict, CodeSessionClosed},
+	}, func(s *testServer) {
+		createTestUser(s, "default", "a@example.com", "s-1")
+		createTestUser(s, "default", "b@example.com", "s-1")
+		s.do(http.MethodPost, apiPrefix+"/user/update", api.UpdateUserRequest{UserEmail: "b@example.com", SessionID: "s-1",
+			QuestionIds: []int{1}, UserAnswers: []string{"a"}}, nil)
+	})
+
+	s := newTestServer(t)
+	issued := startQuiz(s, "fair", "a@example.com", "s-1")
+	// Drawing again, even for another profile, gives the first set back
+	again := decodeBody[api.ChooseQuestionsResponse](t, s.do(http.MethodGet,
+		apiPrefix+"/choose-questions?event=fair&profile=2&userEmail=a@example.com&sessionId=s-1", nil, nil))
+	var ids []string
+	for _, number := range again.QuestionIds {
+		ids = append(ids, questions.FormatID(again.Profile, number))
+	}
+	if again.Profile != "1" || strings.Join(ids, ",") != strings.Join(issued, ",") {
+		t.Errorf("second draw = %+v, want the issued %v", again, issued)
+	}
+	content, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", "a@example.com_s-1.txt"))
+	if err != nil || !strings.Contains(string(content), "\nQuestions: "+strings.Join(issued, ",")+"\n") {
+		t.Errorf("session file = %q, %v", content, err)
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	user := api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", Timestamp: "2026-05-01T10:00:00Z"}
+	runHandlerCases(t, []handlerCase{
+		{"created", http.MethodPost, apiPrefix + "/user/create", user, nil, http.StatusCreated, ""},
+		{"created in event", http.MethodPost, apiPrefix + "/user/create?event=fair", user, nil, http.StatusCreated, ""},
+		{"malformed json", http.MethodPost, apiPrefix + "/user/create", `{"userEmail": `, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"wrong field type", http.MethodPost, apiPrefix + "/user/create", `{"userEmail": 7}`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"missing email", http.MethodPost, apiPrefix + "/user/create", `{"sessionId": "s-1"}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"missing both", http.MethodPost, apiPrefix + "/user/create", `{}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"header in session ID", http.MethodPost, apiPrefix + "/user/create", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "x\nQuestions: CRD0001"}, nil, http.StatusBadRequest, CodeInvalidParameter},
+		{"path in email", http.MethodPost, apiPrefix + "/user/create", api.CreateUserRequest{UserEmail: "../a@example.com", SessionID: "s-1"}, nil, http.StatusBadRequest, CodeInvalidParameter},
+		{"backslash in session ID", http.MethodPost, apiPrefix + "/user/create", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: `s\1`}, nil, http.StatusBadRequest, CodeInvalidParameter},
+		{"header in timestamp", http.MethodPost, apiPrefix + "/user/create", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", Timestamp: "now\rQuestions: CRD0001"}, nil, http.StatusBadRequest, CodeInvalidParameter},
+		{"wrong method", http.MethodGet, apiPrefix + "/user/create", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"event ended", http.MethodPost, apiPrefix + "/user/create?event=ended", user, nil, http.StatusForbidden, CodeEventEnded},
+		{"event not started", http.MethodPost, apiPrefix + "/user/create?event=upcoming", user, nil, http.StatusForbidden, CodeEventNotStarted},
+		{"unknown event", http.MethodPost, apiPrefix + "/user/create?event=nope", user, nil, http.StatusNotFound, CodeEventNotFound},
+	}, nil)
+
+	runHandlerCases(t, []handlerCase{
+		{"attempt limit", http.MethodPost, apiPrefix + "/user/create?event=fair", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-3"}, nil, http.StatusForbidden, CodeAttemptLimit},
+		{"other email", http.MethodPost, apiPrefix + "/user/create?event=fair", api.CreateUserRequest{UserEmail: "b@example.com", SessionID: "s-3"}, nil, http.StatusCreated, ""},
+	}, func(s *testServer) {
+		createTestUser(s, "fair", "a@example.com", "s-1")
+		createTestUser(s, "fair", "a@example.com", "s-2")
+	})
+
+	s := newTestServer(t)
+	kioskID, kiosk := s.registerKiosk("fair")
+	rec := s.do(http.MethodPost, apiPrefix+"/user/create", user, kiosk)
+	created := decodeBody[api.CreateUserResponse](t, rec)
+	if rec.Code != http.StatusCreated || created.Event != "fair" || created.Filename != "a@example.com_s-1.txt" || created.User.KioskID != kioskID {
+		t.Fatalf("response = %d %+v, want the kiosk's event and ID", rec.Code, created)
+	}
+	content, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", created.Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"UserEmail: a@example.com\n", "SessionID: s-1\n", "KioskID: " + kioskID + "\n", "FrontendTimestamp: 2026-05-01T10:00:00Z\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("session file %q does not contain %q", content, want)
+		}
+	}
+
+	// Registering the session again must not replace its file
+	startQuiz(s, "fair", "b@example.com", "s-1")
+	rec = s.do(http.MethodPost, apiPrefix+"/user/create?event=fair", api.CreateUserRequest{UserEmail: "b@example.com", SessionID: "s-1"}, nil)
+	if got := decodeBody[ErrorResponse](t, rec); rec.Code != http.StatusConflict || got.Code != CodeSessionExists {
+		t.Fatalf("second registration = %d %+v, want 409 %s", rec.Code, got, CodeSessionExists)
+	}
+	content, err = os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", "b@example.com_s-1.txt"))
+	if err != nil || !strings.Contains(string(content), "Questions: ") {
+		t.Errorf("session file = %q, %v; want it kept with its questions", content, err)
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	update := api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1, 2}, UserAnswers: []string{"a", "b"}}
+	runHandlerCases(t, []handlerCase{
+		{"updated", http.MethodPost, apiPrefix + "/user/update", update, nil, http.StatusOK, ""},
+		{"unknown session", http.MethodPost, apiPrefix + "/user/update", api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-2"}, nil, http.StatusNotFound, CodeUserNotFound},
+		{"other event", http.MethodPost, apiPrefix + "/user/update?event=fair", update, nil, http.StatusNotFound, CodeUserNotFound},
+		{"malformed json", http.MethodPost, apiPrefix + "/user/update", `{"questionIds": [1,`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"string question ids", http.MethodPost, apiPrefix + "/user/update", `{"userEmail": "a@example.com", "sessionId": "s-1", "questionIds": ["1"]}`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"missing session", http.MethodPost, apiPrefix + "/user/update", `{"userEmail": "a@example.com"}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"wrong method", http.MethodPut, apiPrefix + "/user/update", update, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"event ended", http.MethodPost, apiPrefix + "/user/update?event=ended", update, nil, http.StatusForbidden, CodeEventEnded},
+		{"answer times", http.MethodPost, apiPrefix + "/user/update", api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1}, UserAnswers: []string{"a"}, AnsweredAt: []string{"2026-05-01T10:00:00.250Z"}}, nil, http.StatusOK, ""},
+		{"fewer answer times", http.MethodPost, apiPrefix + "/user/update", api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1, 2}, UserAnswers: []string{"a", "b"}, AnsweredAt: []string{"2026-05-01T10:00:00Z"}}, nil, http.StatusBadRequest, CodeInvalidParameter},
+		{"malformed answer time", http.MethodPost, apiPrefix + "/user/update", api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1}, UserAnswers: []string{"a"}, AnsweredAt: []string{"10:00"}}, nil, http.StatusBadRequest, CodeInvalidParameter},
+	}, func(s *testServer) {
+		createTestUser(s, "default", "a@example.com", "s-1")
+	})
+
+	s := newTestServer(t)
+	createTestUser(s, "default", "a@example.com", "s-1")
+	if rec := s.do(http.MethodPost, apiPrefix+"/user/update", update, nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	content, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "a@example.com_s-1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(content), "\n1,2\nA,B\n") {
+		t.Errorf("session file %q does not end with the question numbers and uppercased answers", content)
+	}
+}
+
+// correctAnswers returns the answer key for question IDs
+func correctAnswers(t *testing.T, ids []string) []string {
+	t.Helper()
+	answers := make([]string, len(ids))
+	for i, id := range ids {
+		answer, ok := questions.FindAnswer(id)
+		if !ok {
+			t.Fatalf("no answer for %s", id)
+		}
+		answers[i] = answer.Answer
+	}
+	return answers
+}
+
+// shownAnswers maps bank letters to the letters a session answers with, through the option orders
+// it was issued
+func shownAnswers(s *testServer, event, email, sessionID string, ids, answers []string) []string {
+	s.t.Helper()
+	e, _ := s.srv.getEvent(event)
+	content, err := sessions.Read(e.DataDir(), email, sessionID)
+	if err != nil {
+		s.t.Fatalf("read session: %v", err)
+	}
+	return displayedAnswers(sessions.Issued(content).Orders(), ids, answers)
+}
+
+// displayedAnswers maps bank letters to the letters their questions' options are shown under
+func displayedAnswers(orders map[string]string, ids, answers []string) []string {
+	shown := make([]string, len(answers))
+	for i, answer := range answers {
+		shown[i] = questions.DisplayedLetter(orders[ids[i]], answer)
+	}
+	return shown
+}
+
+// wrongAnswers returns, for each question, the option letter after the correct one
+func wrongAnswers(t *testing.T, ids []string) []string {
+	t.Helper()
+	answers := correctAnswers(t, ids)
+	for i, answer := range answers {
+		answers[i] = string('a' + (strings.ToLower(answer)[0]-'a'+1)%4)
+	}
+	return answers
+}
+
+func TestEvaluateAnswers(t *testing.T) {
+	ids := []string{"CRD0001", "CRD0002", "CRD0003", "CRD0004"}
+	evaluation := api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: correctAnswers(t, ids)}
+	runHandlerCases(t, []handlerCase{
+		{"evaluated", http.MethodPost, apiPrefix + "/evaluate-answers", evaluation, nil, http.StatusOK, ""},
+		{"malformed json", http.MethodPost, apiPrefix + "/evaluate-answers", `{"questionIds": "CRD0001"}`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"trailing garbage", http.MethodPost, apiPrefix + "/evaluate-answers", `}`, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"missing answers", http.MethodPost, apiPrefix + "/evaluate-answers", `{"questionIds": ["CRD0001"]}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"missing questions", http.MethodPost, apiPrefix + "/evaluate-answers", `{"userAnswers": ["a"]}`, nil, http.StatusBadRequest, CodeMissingFields},
+		{"fewer answers", http.MethodPost, apiPrefix + "/evaluate-answers", api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: []string{"a"}}, nil, http.StatusBadRequest, CodeInvalidAnswers},
+		{"more answers", http.MethodPost, apiPrefix + "/evaluate-answers", api.EvaluateAnswersRequest{QuestionIds: ids[:1], UserAnswers: []string{"a", "b"}}, nil, http.StatusBadRequest, CodeInvalidAnswers},
+		{"wrong method", http.MethodGet, apiPrefix + "/evaluate-answers", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"event ended", http.MethodPost, apiPrefix + "/evaluate-answers?event=ended", evaluation, nil, http.StatusForbidden, CodeEventEnded},
+	}, nil)
+
+	tests := []struct {
+		name        string
+		answers     []string
+		wantCorrect int
+		wantPercent float64
+	}{
+		{"all correct", correctAnswers(t, ids), 4, 100},
+		{"case and spaces ignored", append([]string{" " + strings.ToUpper(correctAnswers(t, ids)[0]) + " "}, wrongAnswers(t, ids)[1:]...), 1, 25},
+		{"all wrong", wrongAnswers(t, ids), 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers", api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: tt.answers}, nil)
+			result := decodeBody[api.EvaluateAnswersResponse](t, rec)
+			if result.CorrectAnswers != tt.wantCorrect || result.IncorrectAnswers != len(ids)-tt.wantCorrect ||
+				result.TotalQuestions != len(ids) || result.ScorePercentage != tt.wantPercent {
+				t.Errorf("score = %+v, want %d correct, %v%%", result.Score, tt.wantCorrect, tt.wantPercent)
+			}
+			if len(result.Results) != len(ids) {
+				t.Fatalf("results = %+v, want one per question", result.Results)
+			}
+			for i, r := range result.Results {
+				if r.QuestionID != ids[i] || r.UserAnswer != tt.answers[i] {
+					t.Errorf("result %d = %+v, want question %s answered %q", i, r, ids[i], tt.answers[i])
+				}
+			}
+		})
+	}
+
+	// Results are only recorded for a known session
+	s := newTestServer(t)
+	s.do(http.MethodPost, apiPrefix+"/evaluate-answers", evaluation, nil)
+	if _, err := os.Stat(filepath.Join(s.srv.config.DataDir, "results.txt")); !os.IsNotExist(err) {
+		t.Errorf("an anonymous evaluation wrote results.txt: %v", err)
+	}
+	issued := startQuiz(s, "fair", "a@example.com", "s-1")
+	answers := shownAnswers(s, "fair", "a@example.com", "s-1", issued, correctAnswers(t, issued))
+	evaluation = api.EvaluateAnswersRequest{QuestionIds: issued, UserAnswers: answers, UserEmail: "a@example.com", SessionID: "s-1"}
+	result := decodeBody[api.EvaluateAnswersResponse](t, s.do(http.MethodPost, apiPrefix+"/evaluate-answers?event=fair", evaluation, nil))
+	content, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", "results.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("|a@example.com|s-1|%d|%d|100.00|0.00|0\n", len(issued), len(issued)); !strings.HasSuffix(string(content), want) {
+		t.Errorf("results.txt = %q, want a line ending %q", content, want)
+	}
+	fair, _ := s.srv.getEvent("fair")
+	if _, err := s.srv.verifyReceipt(result.Receipt, fair, "a@example.com", "s-1"); err != nil {
+		t.Errorf("receipt %q: %v", result.Receipt, err)
+	}
+}
+
+func TestVerifyReceiptExpiry(t *testing.T) {
+	s := newTestServer(t)
+	fair, _ := s.srv.getEvent("fair")
+	score := scoring.Score{TotalQuestions: 1, CorrectAnswers: 1, ScorePercentage: 100}
+	issuedAgo := func(age time.Duration) string {
+		receipt, err := s.srv.issueReceipt(fair, "a@example.com", "s-1", []string{"CRD0001"}, score, 75, time.Now().Add(-age))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipt
+	}
+
+	if _, err := s.srv.verifyReceipt(issuedAgo(30*time.Minute), fair, "a@example.com", "s-1"); err != nil {
+		t.Errorf("receipt within quiz.receiptTTL: %v", err)
+	}
+	old := issuedAgo(2 * time.Hour)
+	if _, err := s.srv.verifyReceipt(old, fair, "a@example.com", "s-1"); err == nil || err.Error() != "receipt expired" {
+		t.Errorf("receipt past quiz.receiptTTL: %v, want it expired", err)
+	}
+	rec := s.do(http.MethodPost, apiPrefix+"/prize/draw?event=fair", api.DrawPrizeRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: old}, nil)
+	if rec.Code != http.StatusForbidden || decodeBody[ErrorResponse](t, rec).Code != CodeInvalidReceipt {
+		t.Errorf("draw with an expired receipt: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+
+	s.srv.config.Quiz.ReceiptTTL = 0
+	if _, err := s.srv.verifyReceipt(old, fair, "a@example.com", "s-1"); err != nil {
+		t.Errorf("receipt with quiz.receiptTTL 0: %v", err)
+	}
+}
+
+func TestEvaluateIssuedQuestions(t *testing.T) {
+	// setup fills the issued set of each case's fresh server
+	issued := []string{}
+	evaluation := func(questionIDs func() []string) func() api.EvaluateAnswersRequest {
+		return func() api.EvaluateAnswersRequest {
+			ids := questionIDs()
+			return api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: make([]string, len(ids)), UserEmail: "a@example.com", SessionID: "s-1"}
+		}
+	}
+	tests := []struct {
+		name       string
+		request    func() api.EvaluateAnswersRequest
+		wantStatus int
+		wantCode   string
+	}{
+		{"issued set", evaluation(func() []string { return issued }), http.StatusOK, ""},
+		{"issued set in another order", evaluation(func() []string { return slices.Concat(issued[1:], issued[:1]) }), http.StatusOK, ""},
+		{"other questions", evaluation(func() []string { return []string{"CRD0001", "CRD0002"} }), http.StatusConflict, CodeQuestionSetMismatch},
+		{"part of the set", evaluation(func() []string { return issued[1:] }), http.StatusConflict, CodeQuestionSetMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			issued = startQuiz(s, "default", "a@example.com", "s-1")
+			request := tt.request()
+			for i := range request.UserAnswers {
+				request.UserAnswers[i] = "a"
+			}
+			rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers", request, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantCode != "" && decodeBody[ErrorResponse](t, rec).Code != tt.wantCode {
+				t.Errorf("body = %q, want code %s", rec.Body.String(), tt.wantCode)
+			}
+		})
+	}
+
+	runHandlerCases(t, []handlerCase{
+		{"no questions issued", http.MethodPost, apiPrefix + "/evaluate-answers",
+			api.EvaluateAnswersRequest{QuestionIds: []string{"CRD0001"}, UserAnswers: []string{"a"}, UserEmail: "a@example.com", SessionID: "s-1"},
+			nil, http.StatusConflict, CodeQuestionSetMismatch},
+		{"unknown session", http.MethodPost, apiPrefix + "/evaluate-answers",
+			api.EvaluateAnswersRequest{QuestionIds: []string{"CRD0001"}, UserAnswers: []string{"a"}, UserEmail: "a@example.com", SessionID: "s-2"},
+			nil, http.StatusNotFound, CodeUserNotFound},
+	}, func(s *testServer) {
+		createTestUser(s, "default", "a@example.com", "s-1")
+	})
+}
+
+func TestEvaluateAnswersSessionState(t *testing.T) {
+	s := newTestServer(t)
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	evaluate := func(answers []string) *httptest.ResponseRecorder {
+		return s.do(http.MethodPost, apiPrefix+"/evaluate-answers",
+			api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: answers, UserEmail: "a@example.com", SessionID: "s-1"}, nil)
+	}
+	wrong := shownAnswers(s, "default", "a@example.com", "s-1", ids, wrongAnswers(t, ids))
+
+	// A retry signs the receipt again without recording a second result
+	for attempt := range 2 {
+		if rec := evaluate(wrong); rec.Code != http.StatusOK || decodeBody[api.EvaluateAnswersResponse](t, rec).Receipt == "" {
+			t.Fatalf("evaluation %d: status = %d, body %q", attempt+1, rec.Code, rec.Body.String())
+		}
+	}
+	content, _ := os.ReadFile(filepath.Join(s.srv.config.DataDir, "results.txt"))
+	if lines := strings.Count(string(content), "|a@example.com|s-1|"); lines != 1 {
+		t.Errorf("results.txt = %q, want one result for s-1", content)
+	}
+
+	// The evaluated answers are the session's, so a retry cannot score others
+	right := shownAnswers(s, "default", "a@example.com", "s-1", ids, correctAnswers(t, ids))
+	if rec := evaluate(right); rec.Code != http.StatusConflict || decodeBody[ErrorResponse](t, rec).Code != CodeAnswerConflict {
+		t.Errorf("evaluate other answers: status = %d, body %q, want 409 %s", rec.Code, rec.Body.String(), CodeAnswerConflict)
+	}
+
+	// Expired and closed sessions are not scored
+	ids = startQuiz(s, "default", "a@example.com", "s-2")
+	s.srv.config.Quiz.SessionTTL = Duration(time.Nanosecond)
+	rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers",
+		api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: wrongAnswers(t, ids), UserEmail: "a@example.com", SessionID: "s-2"}, nil)
+	if rec.Code != http.StatusGone || decodeBody[ErrorResponse](t, rec).Code != CodeSessionExpired {
+		t.Errorf("expired session: status = %d, body %q, want 410 %s", rec.Code, rec.Body.String(), CodeSessionExpired)
+	}
+	s.srv.config.Quiz.SessionTTL = 0
+	ids = startQuiz(s, "default", "a@example.com", "s-3")
+	e, _ := s.srv.getEvent("default")
+	sessions.CloseInProgress(context.Background(), e.DataDir(), time.Now())
+	rec = s.do(http.MethodPost, apiPrefix+"/evaluate-answers",
+		api.EvaluateAnswersRequest{QuestionIds: ids, UserAnswers: wrongAnswers(t, ids), UserEmail: "a@example.com", SessionID: "s-3"}, nil)
+	if rec.Code != http.StatusConflict || decodeBody[ErrorResponse](t, rec).Code != CodeSessionClosed {
+		t.Errorf("closed session: status = %d, body %q, want 409 %s", rec.Code, rec.Body.String(), CodeSessionClosed)
+	}
+}
+
+func TestEvaluateAnswersValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		questionIDs []string
+		answers     []string
+		want        scoring.ValidationError
+	}{
+		{"fewer answers", []string{"CRD0001", "CRD0002", "CRD0003"}, []string{"a"},
+			scoring.ValidationError{QuestionCount: 3, AnswerCount: 1, UnansweredQuestions: []int{1, 2}}},
+		{"more answers", []string{"CRD0001"}, []string{"a", "b", "c"},
+			scoring.ValidationError{QuestionCount: 1, AnswerCount: 3, ExtraAnswers: []int{1, 2}}},
+		{"unknown questions", []string{"CRD0001", "CRD9999", "NOPE"}, []string{"a", "b", "c"},
+			scoring.ValidationError{QuestionCount: 3, AnswerCount: 3, UnknownQuestions: []int{1, 2}}},
+		{"duplicate questions", []string{"CRD0001", "CRD0002", "CRD0001", "CRD0001"}, []string{"a", "b", "c", "d"},
+			scoring.ValidationError{QuestionCount: 4, AnswerCount: 4, DuplicateQuestions: []int{2, 3}}},
+		{"invalid answers", []string{"CRD0001", "CRD0002", "CRD0003", "CRD0004"}, []string{"x", "", "e", "ab"},
+			scoring.ValidationError{QuestionCount: 4, AnswerCount: 4, InvalidAnswers: []int{0, 1, 2, 3}}},
+		{"several problems", []string{"CRD0001", "NOPE", "CRD0001"}, []string{"z", "a"},
+			scoring.ValidationError{QuestionCount: 3, AnswerCount: 2, UnansweredQuestions: []int{2}, UnknownQuestions: []int{1},
+				DuplicateQuestions: []int{2}, InvalidAnswers: []int{0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			request := api.EvaluateAnswersRequest{QuestionIds: tt.questionIDs, UserAnswers: tt.answers, UserEmail: "a@example.com", SessionID: "s-1"}
+			rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers", request, nil)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+			var response struct {
+				Code    string                  `json:"code"`
+				Details scoring.ValidationError `json:"details"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if response.Code != CodeInvalidAnswers || !reflect.DeepEqual(response.Details, tt.want) {
+				t.Errorf("error = %s %+v, want %+v", response.Code, response.Details, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(s.srv.config.DataDir, "results.txt")); !os.IsNotExist(err) {
+				t.Errorf("a rejected evaluation wrote results.txt: %v", err)
+			}
+		})
+	}
+}
+
+// FuzzEvaluateAnswers sends arbitrary bodies to the handler, which must answer 200 or a 4xx and never panic
+func FuzzEvaluateAnswers(f *testing.F) {
+	f.Add(`{"questionIds": ["CRD0001", "CRD0002"], "userAnswers": ["a", "b"]}`)
+	f.Add(`{"questionIds": ["CRD0001", "CRD0002", "CRD0003"], "userAnswers": ["a"]}`)
+	f.Add(`{"questionIds": ["CRD0001"], "userAnswers": ["a", "b", "c"]}`)
+	f.Add(`{"questionIds": ["NOPE", "NOPE"], "userAnswers": ["", "zz"]}`)
+	f.Add(`{"questionIds": [], "userAnswers": null}`)
+	f.Add(`{"questionIds": ["SRV0001"], "userAnswers": ["\u00e1"], "userEmail": "a@example.com"}`)
+	f.Add(`[]`)
+
+	srv := newTestServer(f).srv
+	f.Fuzz(func(t *testing.T, body string) {
+		s := &testServer{t: t, srv: srv}
+		rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers", body, nil)
+		if rec.Code != http.StatusOK && (rec.Code < 400 || rec.Code >= 500) {
+			t.Fatalf("status = %d for body %q: %s", rec.Code, body, rec.Body.String())
+		}
+		if rec.Code != http.StatusOK {
+			return
+		}
+		var request api.EvaluateAnswersRequest
+		json.Unmarshal([]byte(body), &request)
+		result := decodeBody[api.EvaluateAnswersResponse](t, rec)
+		if len(result.Results) != len(request.QuestionIds) || len(request.UserAnswers) != len(request.QuestionIds) {
+			t.Fatalf("evaluated %d results for %d questions and %d answers", len(result.Results), len(request.QuestionIds), len(request.UserAnswers))
+		}
+	})
+}
+
+func TestCreateUserAttemptLimitConcurrent(t *testing.T) {
+	s := newTestServer(t)
+
+	var mu sync.Mutex
+	created := 0
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := s.do(http.MethodPost, apiPrefix+"/user/create?event=fair", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: fmt.Sprintf("s-%d", i)}, nil)
+			if rec.Code != http.StatusCreated && rec.Code != http.StatusForbidden {
+				t.Errorf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusCreated {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// fair allows two attempts per email
+	if created != 2 {
+		t.Errorf("registered %d sessions, want 2", created)
+	}
+}
+
+func TestEvaluateAnswersConcurrent(t *testing.T) {
+	s := newTestServer(t)
+
+	const players = 20
+	issued := make([][]string, players)
+	for i := range players {
+		issued[i] = startQuiz(s, "default", "p@example.com", fmt.Sprintf("s-%d", i))
+	}
+	var wg sync.WaitGroup
+	for i := range players {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers", api.EvaluateAnswersRequest{
+				QuestionIds: issued[i], UserAnswers: wrongAnswers(t, issued[i]), UserEmail: "p@example.com", SessionID: fmt.Sprintf("s-%d", i),
+			}, nil)
+			if rec.Code != http.StatusOK {
+				t.Errorf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	content, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "results.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines != players {
+		t.Errorf("results.txt has %d lines, want %d", lines, players)
+	}
+}
+
+// winner returns a winner increment request with a receipt of a passed evaluation
+func winner(t testing.TB, event, sessionID string) api.WinnerCountRequest {
+	return api.WinnerCountRequest{UserEmail: "a@example.com", SessionID: sessionID, Receipt: testReceipt(t, event, "a@example.com", sessionID, true)}
+}
+
+func TestWinnerCount(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"count", http.MethodGet, apiPrefix + "/winner/count", nil, nil, http.StatusOK, ""},
+		{"unknown event", http.MethodGet, apiPrefix + "/winner/count?event=nope", nil, nil, http.StatusNotFound, CodeEventNotFound},
+		{"wrong method", http.MethodPost, apiPrefix + "/winner/count", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"increment", http.MethodPost, apiPrefix + "/winner/increment", winner(t, "default", "s-1"), nil, http.StatusOK, ""},
+		{"increment without body", http.MethodPost, apiPrefix + "/winner/increment", nil, nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"increment with malformed body", http.MethodPost, apiPrefix + "/winner/increment", "{", nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"increment without receipt", http.MethodPost, apiPrefix + "/winner/increment", api.WinnerCountRequest{UserEmail: "a@example.com", SessionID: "s-1"}, nil, http.StatusBadRequest, CodeMissingFields},
+		{"increment with forged receipt", http.MethodPost, apiPrefix + "/winner/increment", api.WinnerCountRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: "e30.c2ln"}, nil, http.StatusForbidden, CodeInvalidReceipt},
+		{"increment with another session's receipt", http.MethodPost, apiPrefix + "/winner/increment", api.WinnerCountRequest{UserEmail: "a@example.com", SessionID: "s-2", Receipt: winner(t, "default", "s-1").Receipt}, nil, http.StatusForbidden, CodeInvalidReceipt},
+		{"increment with another event's receipt", http.MethodPost, apiPrefix + "/winner/increment", winner(t, "fair", "s-1"), nil, http.StatusForbidden, CodeInvalidReceipt},
+		{"increment after failing", http.MethodPost, apiPrefix + "/winner/increment", api.WinnerCountRequest{UserEmail: "a@example.com", SessionID: "s-1", Receipt: testReceipt(t, "default", "a@example.com", "s-1", false)}, nil, http.StatusForbidden, CodeInvalidReceipt},
+		{"increment wrong method", http.MethodGet, apiPrefix + "/winner/increment", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"increment after event end", http.MethodPost, apiPrefix + "/winner/increment?event=ended", winner(t, "ended", "s-1"), nil, http.StatusForbidden, CodePrizesClosed},
+	}, nil)
+
+	runHandlerCases(t, []handlerCase{
+		{"cap reached", http.MethodPost, apiPrefix + "/winner/increment?event=fair", winner(t, "fair", "s-3"), nil, http.StatusConflict, CodeWinnerLimit},
+		{"other event unaffected", http.MethodPost, apiPrefix + "/winner/increment", winner(t, "default", "s-9"), nil, http.StatusOK, ""},
+	}, func(s *testServer) {
+		for i := range 3 {
+			s.do(http.MethodPost, apiPrefix+"/winner/increment?event=fair", winner(t, "fair", fmt.Sprintf("s-%d", i)), nil)
+		}
+	})
+
+	s := newTestServer(t)
+	for want := 1; want <= 2; want++ {
+		updated := decodeBody[api.WinnerCountResponse](t, s.do(http.MethodPost, apiPrefix+"/winner/increment?event=fair", winner(t, "fair", fmt.Sprintf("s-%d", want)), nil))
+		if updated.WinnerCount != want || updated.MaxWinners != 3 || updated.UpdatedAt == "" {
+			t.Errorf("increment %d = %+v", want, updated)
+		}
+	}
+
+	// A replayed receipt does not count again
+	replayed := s.do(http.MethodPost, apiPrefix+"/winner/increment?event=fair", winner(t, "fair", "s-2"), nil)
+	if got := decodeBody[api.WinnerCountResponse](t, replayed); replayed.Code != http.StatusOK || got.WinnerCount != 2 {
+		t.Errorf("replay: status = %d, %+v, want 200 with the count unchanged", replayed.Code, got)
+	}
+	count := decodeBody[api.WinnerCountResponse](t, s.do(http.MethodGet, apiPrefix+"/winner/count?event=fair", nil, nil))
+	if count.Event != "fair" || count.WinnerCount != 2 || count.MaxWinners != 3 {
+		t.Errorf("count = %+v, want 2 of 3", count)
+	}
+}
+
+func TestWinnerIncrementConcurrent(t *testing.T) {
+	tests := []struct {
+		event     string
+		requests  int
+		wantCount int
+	}{
+		{"default", 25, 25},
+		{"fair", 25, 3}, // Capped
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			s := newTestServer(t)
+			statuses := make(chan int, tt.requests)
+			var wg sync.WaitGroup
+			for i := range tt.requests {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					statuses <- s.do(http.MethodPost, apiPrefix+"/winner/increment?event="+tt.event, winner(t, tt.event, fmt.Sprintf("s-%d", i)), nil).Code
+				}()
+			}
+			wg.Wait()
+			close(statuses)
+
+			accepted := 0
+			for status := range statuses {
+				switch status {
+				case http.StatusOK:
+					accepted++
+				case http.StatusConflict:
+				default:
+					t.Errorf("status = %d", status)
+				}
+			}
+			count := decodeBody[api.WinnerCountResponse](t, s.do(http.MethodGet, apiPrefix+"/winner/count?event="+tt.event, nil, nil))
+			if accepted != tt.wantCount || count.WinnerCount != tt.wantCount {
+				t.Errorf("%d increments accepted, count %d, want %d", accepted, count.WinnerCount, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route classes with their own rate limits
+const (
+	rateClassRead  = "read"  // GET requests on the public routes
+	rateClassWrite = "write" // Every other method on the public routes
+	rateClassAdmin = "admin" // The /admin routes
+)
+
+// Largest request body inspected for a sessionId when limiting by session
+const rateLimitMaxPeek = 64 * 1024
+
+// Buckets are swept once there are this many, dropping the ones that have refilled
+const rateLimitSweepSize = 10000
+
+// tokenBucket holds up to burst tokens and regains rate tokens per second
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps a token bucket per route class and client key
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// allow takes a token from the bucket of key, returning how long to wait when it is empty
+func (l *rateLimiter) allow(key string, rule RateLimitRule, now time.Time) (bool, time.Duration) {
+	rate := rule.PerMinute / 60
+	burst := float64(max(rule.Burst, 1))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) >= rateLimitSweepSize {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets untouched for long enough to have refilled completely, they behave like new ones
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.last) > 10*time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware rejects requests over the limit of their route class with 429 and Retry-After
+// admin selects the admin limits; on other routes GET requests are reads and the rest are writes
+func (srv *Server) rateLimitMiddleware(admin bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		class := rateClassWrite
+		switch {
+		case admin:
+			class = rateClassAdmin
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			class = rateClassRead
+		}
+		rule := srv.config.RateLimit.rule(class)
+		if rule.PerMinute <= 0 || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		key := srv.rateLimitKey(r)
+		ok, wait := srv.limiter.allow(class+"|"+key, rule, time.Now())
+		if !ok {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			rateLimited.Inc(class)
+			slog.WarnContext(r.Context(), "rate limit exceeded", "class", class, "key", key, "retry_after_s", retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, r, http.StatusTooManyRequests, CodeRateLimited, "Too many requests",
+				map[string]any{"class": class, "retryAfterSeconds": retryAfter})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// rateLimitKey returns the client key of a request: the first of the configured keys that is known
+// The client IP is always known, so it ends the list when no other key applies
+func (srv *Server) rateLimitKey(r *http.Request) string {
+	for _, key := range srv.config.RateLimit.Keys {
+		switch key {
+		case "kiosk":
+			if id := requestKioskID(r); id != "" {
+				return "kiosk:" + id
+			}
+		case "session":
+			if id := requestSessionID(r); id != "" {
+				return "session:" + id
+			}
+		case "ip":
+			return "ip:" + srv.clientIP(r)
+		}
+	}
+	return "ip:" + srv.clientIP(r)
+}
+
+// requestSessionID returns the session a request is about, from the sessionId query parameter,
+// the X-Session-ID header or the sessionId field of a JSON body; the body is left readable
+func requestSessionID(r *http.Request) string {
+	if id := r.URL.Query().Get("sessionId"); id != "" {
+		return id
+	}
+	if id := r.Header.Get("X-Session-ID"); id != "" {
+		return id
+	}
+	if r.Body == nil || r.ContentLength > rateLimitMaxPeek || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, rateLimitMaxPeek+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil || len(body) > rateLimitMaxPeek {
+		return ""
+	}
+
+	var payload struct {
+		SessionID string `json:"sessionId"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return payload.SessionID
+}
+
+// clientIP returns the address of the client that sent the request
+// X-Forwarded-For is only followed through proxies listed in rateLimit.trustedProxies:
+// it is read from the right, and the first address that is not a trusted proxy is the client
+func (srv *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !srv.trustedProxy(addr) {
+		return host
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !srv.trustedProxy(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// trustedProxy reports whether addr is one of the configured proxies
+func (srv *Server) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, proxy := range srv.config.RateLimit.TrustedProxies {
+		if prefix, err := parseProxy(proxy); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProxy parses a trusted proxy given as an address or a CIDR range
+func parseProxy(proxy string) (netip.Prefix, error) {
+	if strings.Contains(proxy, "/") {
+		return netip.ParsePrefix(proxy)
+	}
+	addr, err := netip.ParseAddr(proxy)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
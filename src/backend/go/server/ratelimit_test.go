@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupRateLimitTest returns a server with the given limits
+func setupRateLimitTest(t *testing.T, configure func(*RateLimitConfig)) *Server {
+	t.Helper()
+	cfg := testConfig(t)
+	configure(&cfg.RateLimit)
+	return newServer(t, cfg)
+}
+
+func TestTokenBucket(t *testing.T) {
+	l := &rateLimiter{buckets: map[string]*tokenBucket{}}
+	rule := RateLimitRule{PerMinute: 60, Burst: 3}
+	start := time.Unix(1700000000, 0)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("k", rule, start); !ok {
+			t.Fatalf("request %d within burst was rejected", i+1)
+		}
+	}
+	ok, wait := l.allow("k", rule, start)
+	if ok {
+		t.Fatal("request over burst was allowed")
+	}
+	if wait != time.Second {
+		t.Errorf("wait = %v, want 1s at 60 per minute", wait)
+	}
+	if ok, _ := l.allow("other", rule, start); !ok {
+		t.Error("another key shares the bucket")
+	}
+	if ok, _ := l.allow("k", rule, start.Add(time.Second)); !ok {
+		t.Error("bucket did not refill after 1s")
+	}
+	if ok, _ := l.allow("k", rule, start.Add(time.Second)); ok {
+		t.Error("bucket refilled more than one token in 1s")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		admin   bool
+		method  string
+		allowed int // Requests allowed before the first 429
+	}{
+		{"public reads", false, http.MethodGet, 4},
+		{"public writes", false, http.MethodPost, 2},
+		{"admin", true, http.MethodGet, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupRateLimitTest(t, func(c *RateLimitConfig) {
+				c.Read = RateLimitRule{PerMinute: 60, Burst: 4}
+				c.Write = RateLimitRule{PerMinute: 30, Burst: 2}
+				c.Admin = RateLimitRule{PerMinute: 6, Burst: 1}
+			})
+			handler := srv.rateLimitMiddleware(tt.admin, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})
+
+			for i := 0; i <= tt.allowed; i++ {
+				rec := httptest.NewRecorder()
+				handler(rec, httptest.NewRequest(tt.method, "/x", nil))
+
+				if i < tt.allowed {
+					if rec.Code != http.StatusNoContent {
+						t.Fatalf("request %d: status = %d, want 204", i+1, rec.Code)
+					}
+					continue
+				}
+				if rec.Code != http.StatusTooManyRequests {
+					t.Fatalf("request %d: status = %d, want 429", i+1, rec.Code)
+				}
+				if rec.Header().Get("Retry-After") == "" {
+					t.Error("429 without Retry-After")
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitClassesAreSeparate(t *testing.T) {
+	srv := setupRateLimitTest(t, func(c *RateLimitConfig) {
+		c.Write = RateLimitRule{PerMinute: 1, Burst: 1}
+	})
+	handler := srv.rateLimitMiddleware(false, func(w http.ResponseWriter, r *http.Request) {})
+
+	codes := []int{}
+	for _, method := range []string{http.MethodPost, http.MethodPost, http.MethodGet, http.MethodOptions} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(method, "/x", nil))
+		codes = append(codes, rec.Code)
+	}
+	want := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusOK}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("status codes = %v, want %v", codes, want)
+		}
+	}
+	if got := rateLimitedRetryAfter(t, handler); got != "60" {
+		t.Errorf("Retry-After = %q, want 60 at 1 per minute", got)
+	}
+}
+
+func rateLimitedRetryAfter(t *testing.T, handler http.HandlerFunc) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/x", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", rec.Code)
+	}
+	return rec.Header().Get("Retry-After")
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	srv := setupRateLimitTest(t, func(c *RateLimitConfig) {
+		c.Write = RateLimitRule{PerMinute: 0, Burst: 0}
+	})
+	handler := srv.rateLimitMiddleware(false, func(w http.ResponseWriter, r *http.Request) {})
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/x", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d with the limit disabled", i+1, rec.Code)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trusted   []string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"direct", nil, "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"forwarded from untrusted peer is ignored", nil, "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", []string{"10.0.0.1"}, "10.0.0.1:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted range", []string{"10.0.0.0/8"}, "10.1.2.3:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed hops left of the client are ignored", []string{"10.0.0.0/8"}, "10.0.0.1:5000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", []string{"10.0.0.0/8"}, "10.0.0.1:5000", []string{"198.51.100.1", "10.0.0.2"}, "198.51.100.1"},
+		{"trusted proxy without header", []string{"10.0.0.1"}, "10.0.0.1:5000", nil, "10.0.0.1"},
+		{"ipv6", nil, "[2001:db8::1]:5000", nil, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupRateLimitTest(t, func(c *RateLimitConfig) { c.TrustedProxies = tt.trusted })
+			req := httptest.NewRequest(http.MethodGet, "/x", nil)
+			req.RemoteAddr = tt.remote
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if got := srv.clientIP(req); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	body := `{"userEmail": "a@example.com", "sessionId": "s-42"}`
+	tests := []struct {
+		name string
+		keys []string
+		req  func() *http.Request
+		want string
+	}{
+		{"ip", []string{"ip"}, func() *http.Request { return jsonRequest(body) }, "ip:192.0.2.1"},
+		{"session from body", []string{"session", "ip"}, func() *http.Request { return jsonRequest(body) }, "session:s-42"},
+		{"session from query", []string{"session", "ip"}, func() *http.Request {
+			return httptest.NewRequest(http.MethodGet, "/x?sessionId=s-7", nil)
+		}, "session:s-7"},
+		{"session falls back to ip", []string{"session", "ip"}, func() *http.Request { return jsonRequest(`{}`) }, "ip:192.0.2.1"},
+		{"kiosk without token falls back", []string{"kiosk", "session"}, func() *http.Request { return jsonRequest(body) }, "session:s-42"},
+		{"kiosk", []string{"kiosk", "ip"}, func() *http.Request {
+			req := jsonRequest(body)
+			return req.WithContext(context.WithValue(req.Context(), kioskContextKey, &Kiosk{ID: "booth-1"}))
+		}, "kiosk:booth-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := setupRateLimitTest(t, func(c *RateLimitConfig) { c.Keys = tt.keys })
+			req := tt.req()
+			if got := srv.rateLimitKey(req); got != tt.want {
+				t.Errorf("rateLimitKey = %q, want %q", got, tt.want)
+			}
+			if req.Body != nil {
+				if rest, _ := io.ReadAll(req.Body); req.Method == http.MethodPost && len(rest) == 0 {
+					t.Error("request body was consumed")
+				}
+			}
+		})
+	}
+}
+
+func jsonRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/user/create", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
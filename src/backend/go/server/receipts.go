@@ -0,0 +1,115 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"delfos/scoring"
+)
+
+// receiptClaims is the payload of an evaluation receipt: who was scored in which event, on which
+// questions and with what result
+type receiptClaims struct {
+	Event       string   `json:"event"`
+	UserEmail   string   `json:"email"`
+	SessionID   string   `json:"sid"`
+	QuestionIDs []string `json:"qids"`
+	Correct     int      `json:"correct"`
+	Total       int      `json:"total"`
+	Passed      bool     `json:"passed"`
+	IssuedAt    int64    `json:"iat"`
+}
+
+// evaluationReceiptKey returns the key signing evaluation receipts
+// Without quiz.receiptSecret a random key is used, so receipts do not survive a restart
+func (srv *Server) evaluationReceiptKey() []byte {
+	srv.receiptKeyOnce.Do(func() {
+		if srv.config.Quiz.ReceiptSecret != "" {
+			srv.receiptKey = []byte(srv.config.Quiz.ReceiptSecret)
+			return
+		}
+		srv.receiptKey = make([]byte, 32)
+		rand.Read(srv.receiptKey)
+		slog.Warn("quiz.receiptSecret is not set, evaluation receipts are invalid after a restart")
+	})
+	return srv.receiptKey
+}
+
+// issueReceipt returns the signed receipt of a session's evaluation, base64url claims and HMAC
+// separated by a dot; the prize routes only serve sessions holding one
+func (srv *Server) issueReceipt(event *Event, userEmail, sessionID string, questionIDs []string, score scoring.Score, passScore float64, now time.Time) (string, error) {
+	payload, err := json.Marshal(receiptClaims{
+		Event:       event.ID,
+		UserEmail:   userEmail,
+		SessionID:   sessionID,
+		QuestionIDs: questionIDs,
+		Correct:     score.CorrectAnswers,
+		Total:       score.TotalQuestions,
+		Passed:      score.Passed(passScore),
+		IssuedAt:    now.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode receipt: %w", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + srv.signReceipt(unsigned), nil
+}
+
+// verifyReceipt checks the signature of a receipt, that it was issued for a passed evaluation of
+// the session in the event and that it is not older than quiz.receiptTTL
+func (srv *Server) verifyReceipt(receipt string, event *Event, userEmail, sessionID string) (*receiptClaims, error) {
+	unsigned, signature, ok := strings.Cut(receipt, ".")
+	if !ok {
+		return nil, errors.New("malformed receipt")
+	}
+	if !hmac.Equal([]byte(signature), []byte(srv.signReceipt(unsigned))) {
+		return nil, errors.New("invalid receipt signature")
+	}
+
+	var claims receiptClaims
+	payload, err := base64.RawURLEncoding.DecodeString(unsigned)
+	if err != nil || json.Unmarshal(payload, &claims) != nil {
+		return nil, errors.New("malformed receipt claims")
+	}
+	if claims.Event != event.ID || claims.UserEmail != userEmail || claims.SessionID != sessionID {
+		return nil, errors.New("receipt issued for another session")
+	}
+	if !claims.Passed {
+		return nil, errors.New("receipt of a failed evaluation")
+	}
+	if ttl := time.Duration(srv.config.Quiz.ReceiptTTL); ttl > 0 && !time.Now().Before(time.Unix(claims.IssuedAt, 0).Add(ttl)) {
+		return nil, errors.New("receipt expired")
+	}
+	return &claims, nil
+}
+
+func (srv *Server) signReceipt(unsigned string) string {
+	mac := hmac.New(sha256.New, srv.evaluationReceiptKey())
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// requireReceipt reports whether the receipt is valid for the session
+// Otherwise it writes the 400 for a missing receipt or the 403 for an invalid one
+func (srv *Server) requireReceipt(w http.ResponseWriter, r *http.Request, event *Event, userEmail, sessionID, receipt string) bool {
+	if missing := missingFields("userEmail", userEmail, "sessionId", sessionID, "receipt", receipt); len(missing) > 0 {
+		writeMissingFields(w, r, missing)
+		return false
+	}
+	if _, err := srv.verifyReceipt(receipt, event, userEmail, sessionID); err != nil {
+		slog.WarnContext(r.Context(), "evaluation receipt rejected", "error", err)
+		writeError(w, r, http.StatusForbidden, CodeInvalidReceipt, "Invalid evaluation receipt",
+			map[string]string{"reason": err.Error()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"delfos/api"
+	"delfos/sessions"
+)
+
+// sessionTokenClaims is the payload of a session token: the session it resumes
+type sessionTokenClaims struct {
+	Event     string `json:"event"`
+	UserEmail string `json:"email"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp,omitempty"` // quiz.sessionTTL after issue, none when sessions never expire
+}
+
+// issueSessionToken returns the signed token that resumes a session, base64url claims and HMAC
+// separated by a dot like receipts, and signed with the same key
+func (srv *Server) issueSessionToken(event *Event, userEmail, sessionID string, now time.Time) (string, error) {
+	claims := sessionTokenClaims{
+		Event:     event.ID,
+		UserEmail: userEmail,
+		SessionID: sessionID,
+		IssuedAt:  now.Unix(),
+	}
+	if ttl := time.Duration(srv.config.Quiz.SessionTTL); ttl > 0 {
+		claims.ExpiresAt = now.Add(ttl).Unix()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session token: %w", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + srv.signSessionToken(unsigned), nil
+}
+
+// verifySessionToken checks the signature of a session token, that it was issued in the event and
+// that it has not expired; while quiz.sessionTTL is set, tokens without an expiry are refused
+func (srv *Server) verifySessionToken(token string, event *Event, now time.Time) (*sessionTokenClaims, error) {
+	unsigned, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("malformed session token")
+	}
+	if !hmac.Equal([]byte(signature), []byte(srv.signSessionToken(unsigned))) {
+		return nil, errors.New("invalid session token signature")
+	}
+
+	var claims sessionTokenClaims
+	payload, err := base64.RawURLEncoding.DecodeString(unsigned)
+	if err != nil || json.Unmarshal(payload, &claims) != nil || claims.UserEmail == "" || claims.SessionID == "" {
+		return nil, errors.New("malformed session token claims")
+	}
+	if claims.Event != event.ID {
+		return nil, errors.New("session token issued for another event")
+	}
+	if claims.ExpiresAt == 0 && srv.config.Quiz.SessionTTL > 0 {
+		return nil, errors.New("session token without expiry")
+	}
+	if claims.ExpiresAt != 0 && now.Unix() >= claims.ExpiresAt {
+		return nil, errors.New("session token expired")
+	}
+	return &claims, nil
+}
+
+// signSessionToken signs with a prefix, so a receipt signature never passes as a session token's
+func (srv *Server) signSessionToken(unsigned string) string {
+	mac := hmac.New(sha256.New, srv.evaluationReceiptKey())
+	mac.Write([]byte("session."))
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// resumeSession handles continuing a session after a refresh or from another kiosk
+// It expects a POST request with JSON body containing userEmail and resumeCode, or sessionToken, and
+// returns the issued questions, the answers the server holds and the time left
+func (srv *Server) resumeSession(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.ResumeSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid resume request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	now := time.Now()
+	userEmail, sessionID := req.UserEmail, ""
+	if req.SessionToken != "" {
+		claims, err := srv.verifySessionToken(req.SessionToken, event, now)
+		if err != nil {
+			slog.WarnContext(r.Context(), "session token rejected", "error", err)
+			writeError(w, r, http.StatusForbidden, CodeInvalidSessionToken, "Invalid session token",
+				map[string]string{"reason": err.Error()})
+			return
+		}
+		userEmail, sessionID = claims.UserEmail, claims.SessionID
+	} else {
+		if missing := missingFields("userEmail", req.UserEmail, "resumeCode", req.ResumeCode); len(missing) > 0 {
+			writeMissingFields(w, r, missing)
+			return
+		}
+		found, err := sessions.FindByResumeCode(r.Context(), event.DataDir(), req.UserEmail, req.ResumeCode)
+		if errors.Is(err, sessions.ErrNotFound) {
+			slog.WarnContext(r.Context(), "no session for the resume code")
+			writeError(w, r, http.StatusNotFound, CodeUserNotFound, "No session matches the email and resume code", nil)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "failed to find session", "error", err)
+			writeInternalError(w, r)
+			return
+		}
+		sessionID = found
+	}
+	logSession(r.Context(), userEmail, sessionID)
+
+	if closed := submissionError(event, now); closed != nil {
+		writeEventClosed(w, r, closed)
+		return
+	}
+
+	content, err := sessions.Read(event.DataDir(), userEmail, sessionID)
+	if errors.Is(err, sessions.ErrNotFound) {
+		writeError(w, r, http.StatusNotFound, CodeUserNotFound, "User file not found", nil)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read user file", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+	ttl := time.Duration(srv.config.Quiz.SessionTTL)
+	if sessions.Expired(content, ttl, now) {
+		writeSessionExpired(w, r)
+		return
+	}
+	if !sessions.InProgress(content) {
+		writeError(w, r, http.StatusConflict, CodeSessionClosed, "Session is already finished", nil)
+		return
+	}
+
+	token, err := srv.issueSessionToken(event, userEmail, sessionID, now)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to issue session token", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	issued, held := sessions.Issued(content), sessions.Answers(content)
+	profile, numbers := questionNumbers(issued.QuestionIDs)
+	response := api.ResumeSessionResponse{
+		Status:       "success",
+		Message:      "Session resumed",
+		Event:        event.ID,
+		UserEmail:    userEmail,
+		SessionID:    sessionID,
+		SessionToken: token,
+		Profile:      profile,
+		QuestionIds:  numbers,
+		Answers:      make([]string, len(issued.QuestionIDs)),
+	}
+	if response.QuestionIds == nil {
+		response.QuestionIds = []int{}
+	}
+	for i, id := range issued.QuestionIDs {
+		response.Answers[i] = held[id]
+		if held[id] != "" {
+			response.Answered++
+		}
+	}
+	if expires := sessions.ExpiresAt(content, ttl); !expires.IsZero() {
+		response.RemainingSeconds = int(math.Ceil(expires.Sub(now).Seconds()))
+		response.ExpiresAt = expires.Format(time.RFC3339)
+	}
+	slog.InfoContext(r.Context(), "session resumed", "answered", response.Answered, "questions", len(numbers))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeSessionExpired writes the 410 response for a session abandoned past quiz.sessionTTL
+func writeSessionExpired(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusGone, CodeSessionExpired, "Session expired, start a new one", nil)
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"delfos/api"
+	"delfos/questions"
+	"delfos/sessions"
+)
+
+func TestResumeSession(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"missing fields", http.MethodPost, apiPrefix + "/session/resume",
+			api.ResumeSessionRequest{UserEmail: "a@example.com"}, nil, http.StatusBadRequest, CodeMissingFields},
+		{"unknown code", http.MethodPost, apiPrefix + "/session/resume",
+			api.ResumeSessionRequest{UserEmail: "a@example.com", ResumeCode: "NOPE22"}, nil, http.StatusNotFound, CodeUserNotFound},
+		{"forged token", http.MethodPost, apiPrefix + "/session/resume",
+			api.ResumeSessionRequest{SessionToken: "e30.c2ln"}, nil, http.StatusForbidden, CodeInvalidSessionToken},
+		{"malformed body", http.MethodPost, apiPrefix + "/session/resume", "{", nil, http.StatusBadRequest, CodeInvalidJSON},
+		{"wrong method", http.MethodGet, apiPrefix + "/session/resume", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, func(s *testServer) {
+		createTestUser(s, "default", "a@example.com", "s-1")
+	})
+
+	s := newTestServer(t)
+	rec := s.do(http.MethodPost, apiPrefix+"/user/create", api.CreateUserRequest{UserEmail: "a@example.com", SessionID: "s-1"}, nil)
+	created := decodeBody[api.CreateUserResponse](t, rec)
+	if len(created.User.ResumeCode) != 6 || created.SessionToken == "" {
+		t.Fatalf("create user = %+v, want a resume code and a session token", created)
+	}
+	resume := func(req api.ResumeSessionRequest) api.ResumeSessionResponse {
+		t.Helper()
+		rec := s.do(http.MethodPost, apiPrefix+"/session/resume", req, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("resume %+v: status = %d, body %q", req, rec.Code, rec.Body.String())
+		}
+		return decodeBody[api.ResumeSessionResponse](t, rec)
+	}
+
+	// Refreshed before choosing a profile
+	if got := resume(api.ResumeSessionRequest{SessionToken: created.SessionToken}); got.SessionID != "s-1" || len(got.QuestionIds) != 0 || got.Profile != "" {
+		t.Errorf("resume before questions = %+v, want s-1 with no questions", got)
+	}
+
+	rec = s.do(http.MethodGet, apiPrefix+"/choose-questions?profile=2&userEmail=a@example.com&sessionId=s-1", nil, nil)
+	drawn := decodeBody[api.ChooseQuestionsResponse](t, rec)
+	ids := make([]string, len(drawn.QuestionIds))
+	for i, number := range drawn.QuestionIds {
+		ids[i] = questions.FormatID(drawn.Profile, number)
+	}
+	for _, id := range ids[:2] {
+		s.do(http.MethodPost, apiPrefix+"/session/s-1/answer", api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: id, Answer: "b"}, nil)
+	}
+
+	// From another kiosk, with the code as the player types it
+	got := resume(api.ResumeSessionRequest{UserEmail: "a@example.com", ResumeCode: " " + strings.ToLower(created.User.ResumeCode)})
+	if got.SessionID != "s-1" || got.Profile != "2" || !slices.Equal(got.QuestionIds, drawn.QuestionIds) || got.Answered != 2 {
+		t.Errorf("resume = %+v, want the issued questions of s-1 with 2 answered", got)
+	}
+	if want := append([]string{"b", "b"}, make([]string, len(ids)-2)...); !slices.Equal(got.Answers, want) {
+		t.Errorf("answers = %q, want %q", got.Answers, want)
+	}
+	if ttl := time.Duration(s.srv.config.Quiz.SessionTTL); got.RemainingSeconds <= 0 || got.RemainingSeconds > int(ttl.Seconds()) || got.ExpiresAt == "" {
+		t.Errorf("remaining %ds until %q, want up to %v", got.RemainingSeconds, got.ExpiresAt, ttl)
+	}
+	if got.SessionToken == "" {
+		t.Error("resume returned no session token")
+	}
+
+	// Tokens are bound to their event
+	other, _ := s.srv.issueSessionToken(&Event{ID: "fair"}, "a@example.com", "s-1", time.Now())
+	rec = s.do(http.MethodPost, apiPrefix+"/session/resume", api.ResumeSessionRequest{SessionToken: other}, nil)
+	if rec.Code != http.StatusForbidden || decodeBody[ErrorResponse](t, rec).Code != CodeInvalidSessionToken {
+		t.Errorf("token of another event: status = %d, want 403 %s", rec.Code, CodeInvalidSessionToken)
+	}
+
+	// Tokens expire quiz.sessionTTL after they were issued
+	ttl := s.srv.config.Quiz.SessionTTL
+	expired, _ := s.srv.issueSessionToken(&Event{ID: "default"}, "a@example.com", "s-1", time.Now().Add(-time.Duration(ttl)-time.Second))
+	s.srv.config.Quiz.SessionTTL = 0
+	unbounded, _ := s.srv.issueSessionToken(&Event{ID: "default"}, "a@example.com", "s-1", time.Now())
+	s.srv.config.Quiz.SessionTTL = ttl
+	for name, token := range map[string]string{"expired": expired, "without expiry": unbounded} {
+		rec = s.do(http.MethodPost, apiPrefix+"/session/resume", api.ResumeSessionRequest{SessionToken: token}, nil)
+		if rec.Code != http.StatusForbidden || decodeBody[ErrorResponse](t, rec).Code != CodeInvalidSessionToken {
+			t.Errorf("%s token: status = %d, want 403 %s", name, rec.Code, CodeInvalidSessionToken)
+		}
+	}
+
+	// Finished sessions are not resumed
+	evaluation := s.do(http.MethodPost, apiPrefix+"/user/update", api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1}, UserAnswers: []string{"a"}}, nil)
+	if evaluation.Code != http.StatusOK {
+		t.Fatalf("update: status = %d", evaluation.Code)
+	}
+	rec = s.do(http.MethodPost, apiPrefix+"/session/resume", api.ResumeSessionRequest{SessionToken: created.SessionToken}, nil)
+	if rec.Code != http.StatusConflict || decodeBody[ErrorResponse](t, rec).Code != CodeSessionClosed {
+		t.Errorf("resume a finished session: status = %d, want 409 %s", rec.Code, CodeSessionClosed)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := newTestServer(t)
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	e, _ := s.srv.getEvent("default")
+
+	// Registered at least a nanosecond ago
+	s.srv.config.Quiz.SessionTTL = Duration(time.Nanosecond)
+	for _, tt := range []struct {
+		name, path string
+		body       any
+	}{
+		{"answer", "/session/s-1/answer", api.SubmitAnswerRequest{UserEmail: "a@example.com", QuestionID: ids[0], Answer: "a"}},
+		{"evaluate", "/session/s-1/evaluate", api.EvaluateSessionRequest{UserEmail: "a@example.com"}},
+	} {
+		rec := s.do(http.MethodPost, apiPrefix+tt.path, tt.body, nil)
+		if rec.Code != http.StatusGone || decodeBody[ErrorResponse](t, rec).Code != CodeSessionExpired {
+			t.Errorf("%s: status = %d, want 410 %s", tt.name, rec.Code, CodeSessionExpired)
+		}
+	}
+
+	// The scheduler marks it, so it stays expired whatever the setting
+	s.srv.expireSessions(e, time.Now())
+	content, _ := sessions.Read(e.DataDir(), "a@example.com", "s-1")
+	if !strings.Contains(content, "SessionExpired: ") {
+		t.Fatalf("session file = %q, want a SessionExpired line", content)
+	}
+	s.srv.config.Quiz.SessionTTL = 0
+	code := strings.SplitN(strings.SplitN(content, "ResumeCode: ", 2)[1], "\n", 2)[0]
+	rec := s.do(http.MethodPost, apiPrefix+"/session/resume", api.ResumeSessionRequest{UserEmail: "a@example.com", ResumeCode: code}, nil)
+	if rec.Code != http.StatusGone || decodeBody[ErrorResponse](t, rec).Code != CodeSessionExpired {
+		t.Errorf("resume: status = %d, want 410 %s", rec.Code, CodeSessionExpired)
+	}
+
+	// Sessions that never expire keep no deadline
+	startQuiz(s, "default", "a@example.com", "s-2")
+	s.srv.expireSessions(e, time.Now().Add(time.Hour))
+	if content, _ := sessions.Read(e.DataDir(), "a@example.com", "s-2"); !sessions.InProgress(content) {
+		t.Errorf("session file = %q, want it in progress with quiz.sessionTTL 0", content)
+	}
+}
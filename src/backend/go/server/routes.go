@@ -0,0 +1,223 @@
+package server
+
+import (
+	"net/http"
+
+	"delfos/api"
+	"delfos/questions"
+)
+
+// route is an API endpoint, served under apiPrefix and at its legacy unversioned path
+// The documentation fields build the OpenAPI document; routes without a summary are left out of it
+type route struct {
+	method  string // Handlers check their own methods
+	path    string
+	handler func(*Server, http.ResponseWriter, *http.Request)
+	admin   bool // Operator route: admin CORS policy and rate limit, and role when set
+	role    Role
+	kiosk   bool // Requires X-Kiosk-Token
+	player  bool // Quiz route: requires X-Kiosk-Token when kiosks.required is set
+
+	summary  string
+	tag      string
+	params   []apiParam
+	request  any // Zero value of the JSON request body, nil for none
+	response any // Zero value of the JSON response body, or the media type of a body that is not JSON
+	status   int // Success status, 200 when zero
+}
+
+var routes []route
+
+// The table is filled in init because serveOpenAPI, one of its handlers, reads it
+func init() {
+	routes = []route{
+		{
+			method: http.MethodGet, path: "/question", handler: (*Server).getQuestionByID, player: true,
+			summary: "Get a question with its options, in the session's shuffled order when one is given", tag: "quiz",
+			params: append([]apiParam{
+				{"query", "id", "Question ID, e.g. CRD0001", true},
+				{"query", "userEmail", "Player of the session the options are shown to", false},
+				{"query", "sessionId", "Session the options are shown to; it answers with the letters of its order", false},
+			}, eventParams...),
+			response: questions.Question{},
+		},
+		{
+			method: http.MethodGet, path: "/answer", handler: (*Server).getAnswerByQuestionID, player: true,
+			summary: "Get the correct answer of a question", tag: "quiz",
+			params:   []apiParam{{"query", "question_id", "Question ID, e.g. CRD0001", true}},
+			response: questions.Answer{},
+		},
+		{
+			method: http.MethodGet, path: "/choose-questions", handler: (*Server).getQuestionIDs, player: true,
+			summary: "Draw random question numbers for a profile, issued to the session when one is given", tag: "quiz",
+			params: append([]apiParam{
+				{"query", "profile", `Question profile: "1" CRD (default), "2" SRV, "3" EXP`, false},
+				{"query", "userEmail", "Player of the session the questions are issued to", false},
+				{"query", "sessionId", "Session the questions are issued to; it keeps its first set", false},
+			}, eventParams...),
+			response: api.ChooseQuestionsResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/user/create", handler: (*Server).createUser, player: true,
+			summary: "Register a player session", tag: "sessions",
+			params: eventParams, request: api.CreateUserRequest{}, response: api.CreateUserResponse{}, status: http.StatusCreated,
+		},
+		{
+			method: http.MethodPost, path: "/user/update", handler: (*Server).updateUser, player: true,
+			summary: "Store the answers of a session", tag: "sessions",
+			params: eventParams, request: api.UpdateUserRequest{}, response: api.UpdateUserResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/session/resume", handler: (*Server).resumeSession, player: true,
+			summary: "Resume a session with the email and resume code, or the session token", tag: "sessions",
+			params: eventParams, request: api.ResumeSessionRequest{}, response: api.ResumeSessionResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/session/{id}/answer", handler: (*Server).submitAnswer, player: true,
+			summary: "Record one answer of a session; a question keeps its first answer, so retries are safe", tag: "sessions",
+			params:  append([]apiParam{{"path", "id", "Session ID", true}}, eventParams...),
+			request: api.SubmitAnswerRequest{}, response: api.SubmitAnswerResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/session/{id}/evaluate", handler: (*Server).evaluateSession, player: true,
+			summary: "Score the answers a session recorded, record the result and sign a receipt", tag: "quiz",
+			params:  append([]apiParam{{"path", "id", "Session ID", true}}, eventParams...),
+			request: api.EvaluateSessionRequest{}, response: api.EvaluateAnswersResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/evaluate-answers", handler: (*Server).evaluateAnswers, player: true,
+			summary: "Score answers; with userEmail and sessionId, record the result and sign a receipt", tag: "quiz",
+			params: eventParams, request: api.EvaluateAnswersRequest{}, response: api.EvaluateAnswersResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/winner/count", handler: (*Server).getWinnerCount, player: true,
+			summary: "Get the winner count of the event", tag: "prizes",
+			params: eventParams, response: api.WinnerCountResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/winner/increment", handler: (*Server).incrementWinnerCount, player: true,
+			summary: "Record a winner with the session's evaluation receipt", tag: "prizes",
+			params: eventParams, request: api.WinnerCountRequest{}, response: api.WinnerCountResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/prize/draw", handler: (*Server).drawPrize, player: true,
+			summary: "Draw a prize from the event's prize table with the session's evaluation receipt", tag: "prizes",
+			params: eventParams, request: api.DrawPrizeRequest{}, response: api.DrawPrizeResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/events", handler: (*Server).listEvents,
+			summary: "List the events", tag: "events",
+			response: EventListResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/event/status", handler: (*Server).getEventStatus,
+			summary: "Get the schedule state of an event by the server clock", tag: "events",
+			params: eventParams, response: EventStatusResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/kiosk/heartbeat", handler: (*Server).kioskHeartbeat, kiosk: true,
+			summary: "Report that a kiosk is online", tag: "kiosks",
+			response: HeartbeatResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/sync", handler: (*Server).syncRecords, kiosk: true,
+			summary: "Upload records a kiosk queued while offline", tag: "kiosks",
+			request: SyncRequest{}, response: SyncResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/process", handler: (*Server).processInput, player: true,
+			summary: "Advance a conversation flow", tag: "flows",
+			params:  append([]apiParam{{"query", "flow", "Flow ID, only used when the session starts", false}}, eventParams...),
+			request: ProcessRequest{}, response: ProcessResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/ws", handler: (*Server).flowWebSocket, player: true,
+			summary: "Conversation flows over a WebSocket, one FlowMessage in and one ProcessResponse out per text message", tag: "flows",
+			params: eventParams, status: http.StatusSwitchingProtocols,
+		},
+		{
+			method: http.MethodGet, path: "/metrics", handler: (*Server).serveMetrics,
+			summary: "Prometheus metrics", tag: "operations",
+			response: "text/plain",
+		},
+		{method: http.MethodGet, path: "/openapi.json", handler: (*Server).serveOpenAPI},
+		{method: http.MethodGet, path: "/docs/", handler: (*Server).serveAPIDocs},
+
+		{
+			method: http.MethodPost, path: "/admin/login", handler: (*Server).adminLogin, admin: true,
+			summary: "Log in an operator and set the session cookie", tag: "admin",
+			request: AdminLoginRequest{}, response: AdminLoginResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/admin/logout", handler: (*Server).adminLogout, admin: true,
+			summary: "Clear the operator session cookie", tag: "admin",
+			response: AdminLogoutResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/admin/me", handler: (*Server).adminWhoAmI, admin: true, role: RoleViewer,
+			summary: "Get the authenticated operator", tag: "admin",
+			response: AdminWhoAmIResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/admin/kiosks", handler: (*Server).listKiosks, admin: true, role: RoleViewer,
+			summary: "List kiosks with their online status", tag: "admin",
+			response: KioskListResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/admin/kiosks/register", handler: (*Server).registerKiosk, admin: true, role: RoleAdmin,
+			summary: "Register a kiosk; the token is only returned once", tag: "admin",
+			request: RegisterKioskRequest{}, response: RegisterKioskResponse{}, status: http.StatusCreated,
+		},
+		{
+			method: http.MethodPost, path: "/admin/kiosks/disable", handler: (*Server).setKioskDisabled, admin: true, role: RoleAdmin,
+			summary: "Disable or re-enable a kiosk", tag: "admin",
+			request: SetKioskDisabledRequest{}, response: SetKioskDisabledResponse{},
+		},
+		{
+			method: http.MethodPost, path: "/admin/prizes/redeem", handler: (*Server).redeemPrize, admin: true, role: RoleStaff,
+			summary: "Hand over the prize awarded to a session; each award is redeemed once", tag: "admin",
+			params: eventParams, request: RedeemPrizeRequest{}, response: RedeemPrizeResponse{},
+		},
+		{
+			method: http.MethodGet, path: "/admin/audit", handler: (*Server).queryAudit, admin: true, role: RoleStaff,
+			summary: "Query the audit log, most recent matching entries oldest first", tag: "admin",
+			params: []apiParam{
+				{"query", "action", "Only entries with this action, e.g. prize.award", false},
+				{"query", "actor", "Only entries by this actor, e.g. admin:ana or kiosk:booth-1", false},
+				{"query", "kiosk", "Only entries from this kiosk", false},
+				{"query", "event", "Only entries for this event", false},
+				{"query", "subject", "Only entries about this session, kiosk or operator", false},
+				{"query", "since", "Entries at or after this RFC3339 time", false},
+				{"query", "until", "Entries before this RFC3339 time", false},
+				{"query", "limit", "Number of entries, 1 to 1000, default 100", false},
+			},
+			response: AuditQueryResponse{},
+		},
+	}
+}
+
+// Combined middleware wrapper; player marks the quiz routes kiosks.required applies to
+func (srv *Server) withMiddleware(player bool, handler http.HandlerFunc) http.HandlerFunc {
+	return loggingMiddleware(tracingMiddleware(corsMiddleware(srv.publicCORSPolicy(), srv.kioskMiddleware(player, srv.rateLimitMiddleware(false, metricsMiddleware(handler))))))
+}
+
+// registerRoutes adds every route to mux under apiPrefix and at its legacy path
+// Paths that match no route, including unknown /admin paths, get a ROUTE_NOT_FOUND error
+func (srv *Server) registerRoutes(mux *http.ServeMux) {
+	for _, rt := range routes {
+		serve := func(w http.ResponseWriter, r *http.Request) { rt.handler(srv, w, r) }
+		handler := srv.withMiddleware(rt.player, serve)
+		if rt.admin {
+			handler = srv.withAdminMiddleware(rt.role, serve)
+		}
+		mux.HandleFunc(apiPrefix+rt.path, handler)
+		mux.HandleFunc(rt.path, handler)
+	}
+
+	// Legacy path of /admin/kiosks/register
+	mux.HandleFunc("/kiosk/register", srv.withAdminMiddleware(RoleAdmin, srv.registerKiosk))
+
+	mux.HandleFunc("/", srv.withMiddleware(false, notFound))
+	mux.HandleFunc(apiPrefix+"/admin/", srv.withAdminMiddleware("", notFound))
+	mux.HandleFunc("/admin/", srv.withAdminMiddleware("", notFound))
+}
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+	_ "time/tzdata" // Kiosk hosts may not ship a zoneinfo database
+
+	"delfos/sessions"
+)
+
+// OpeningHours represents the daily window in which an event accepts registrations
+type OpeningHours struct {
+	Open  string `json:"open"`  // "HH:MM" in the event timezone
+	Close string `json:"close"` // "HH:MM" in the event timezone
+}
+
+// EventState is the schedule state of an event at a given server time
+type EventState string
+
+const (
+	EventOpen         EventState = "open"
+	EventNotStarted   EventState = "not_started"
+	EventOutsideHours EventState = "outside_hours"
+	EventEnded        EventState = "ended"
+)
+
+// Machine readable codes sent to the terminal when an action is refused by the schedule
+const (
+	CodeEventNotStarted   = "EVENT_NOT_STARTED"
+	CodeEventOutsideHours = "EVENT_OUTSIDE_HOURS"
+	CodeEventEnded        = "EVENT_ENDED"
+	CodePrizesClosed      = "PRIZES_CLOSED"
+)
+
+// EventClosedResponse is the WebSocket message sent when the event schedule refuses an action
+// HTTP routes send the same information as an ErrorResponse, see writeEventClosed
+type EventClosedResponse struct {
+	Status     string `json:"status"` // Always "event_closed"
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Event      string `json:"event"`
+	ServerTime string `json:"serverTime"`
+	OpensAt    string `json:"opensAt,omitempty"`
+}
+
+// EventStatusResponse represents the response for the event status endpoint
+type EventStatusResponse struct {
+	Status     string     `json:"status"`
+	Event      string     `json:"event"`
+	Name       string     `json:"name"`
+	State      EventState `json:"state"`
+	ServerTime string     `json:"serverTime"`
+	OpensAt    string     `json:"opensAt,omitempty"`
+	ClosesAt   string     `json:"closesAt,omitempty"`
+	PrizesOpen bool       `json:"prizesOpen"`
+}
+
+// EventClosedError is returned by operations refused because of the event schedule
+type EventClosedError struct {
+	Event *Event
+	Code  string
+}
+
+func (e *EventClosedError) Error() string {
+	return fmt.Sprintf("event %s refused the action: %s", e.Event.ID, e.Code)
+}
+
+// eventClosedMessages are the texts shown by the terminal for each refusal code
+var eventClosedMessages = map[string]string{
+	CodeEventNotStarted:   "El evento aún no ha comenzado. ¡Vuelve pronto!",
+	CodeEventOutsideHours: "La terminal está cerrada en este momento. Vuelve en el horario del evento.",
+	CodeEventEnded:        "El evento ha finalizado. ¡Gracias por participar!",
+	CodePrizesClosed:      "La entrega de premios ha cerrado por hoy.",
+}
+
+// prepareSchedule validates and parses the schedule fields of an event
+func (e *Event) prepareSchedule() error {
+	e.location = time.Local
+	if e.Timezone != "" {
+		location, err := time.LoadLocation(e.Timezone)
+		if err != nil {
+			return fmt.Errorf("event %q has invalid timezone: %w", e.ID, err)
+		}
+		e.location = location
+	}
+
+	if e.Hours == nil {
+		return nil
+	}
+	open, err := parseClock(e.Hours.Open)
+	if err != nil {
+		return fmt.Errorf("event %q has invalid opening time: %w", e.ID, err)
+	}
+	closing, err := parseClock(e.Hours.Close)
+	if err != nil {
+		return fmt.Errorf("event %q has invalid closing time: %w", e.ID, err)
+	}
+	if closing <= open {
+		return fmt.Errorf("event %q closes before it opens", e.ID)
+	}
+	e.openAt, e.closeAt = open, closing
+	return nil
+}
+
+// parseClock parses an "HH:MM" time of day into a duration since midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// State returns the schedule state of the event at the given server time
+func (e *Event) State(now time.Time) EventState {
+	if !e.EndsAt.IsZero() && !now.Before(e.EndsAt) {
+		return EventEnded
+	}
+	if !e.StartsAt.IsZero() && now.Before(e.StartsAt) {
+		return EventNotStarted
+	}
+	if e.Hours != nil {
+		local := now.In(e.location)
+		sinceMidnight := local.Sub(startOfDay(local))
+		if sinceMidnight < e.openAt || sinceMidnight >= e.closeAt {
+			return EventOutsideHours
+		}
+	}
+	return EventOpen
+}
+
+// NextOpening returns when the event accepts registrations again, or the zero time if it is open
+// or will not open again before it ends
+func (e *Event) NextOpening(now time.Time) time.Time {
+	var opening time.Time
+	switch e.State(now) {
+	case EventNotStarted:
+		if e.State(e.StartsAt) == EventOpen {
+			return e.StartsAt
+		}
+		opening = e.nextDailyOpening(e.StartsAt)
+	case EventOutsideHours:
+		opening = e.nextDailyOpening(now)
+	default:
+		return time.Time{}
+	}
+
+	if !e.EndsAt.IsZero() && !opening.Before(e.EndsAt) {
+		return time.Time{}
+	}
+	return opening
+}
+
+// nextDailyOpening returns the first daily opening time after from
+func (e *Event) nextDailyOpening(from time.Time) time.Time {
+	local := from.In(e.location)
+	opening := startOfDay(local).Add(e.openAt)
+	if local.Sub(startOfDay(local)) >= e.openAt {
+		opening = startOfDay(local.AddDate(0, 0, 1)).Add(e.openAt)
+	}
+	return opening
+}
+
+// ClosingTime returns when the current opening ends, or the zero time if the event is not open
+func (e *Event) ClosingTime(now time.Time) time.Time {
+	if e.State(now) != EventOpen {
+		return time.Time{}
+	}
+	closing := e.EndsAt
+	if e.Hours != nil {
+		local := now.In(e.location)
+		daily := startOfDay(local).Add(e.closeAt)
+		if closing.IsZero() || daily.Before(closing) {
+			closing = daily
+		}
+	}
+	return closing
+}
+
+// PrizesOpen reports whether physical prizes can still be awarded at the given server time
+func (e *Event) PrizesOpen(now time.Time) bool {
+	if e.State(now) == EventEnded {
+		return false
+	}
+	return e.PrizeCutoff.IsZero() || now.Before(e.PrizeCutoff)
+}
+
+// startOfDay returns midnight of the given time's day in its location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// registrationError returns an EventClosedError when the event does not accept registrations now
+func registrationError(event *Event, now time.Time) *EventClosedError {
+	switch event.State(now) {
+	case EventNotStarted:
+		return &EventClosedError{Event: event, Code: CodeEventNotStarted}
+	case EventOutsideHours:
+		return &EventClosedError{Event: event, Code: CodeEventOutsideHours}
+	case EventEnded:
+		return &EventClosedError{Event: event, Code: CodeEventEnded}
+	}
+	return nil
+}
+
+// submissionError returns an EventClosedError when the event no longer accepts answers
+// Sessions already in progress may finish outside the daily hours, but not after the event ends
+func submissionError(event *Event, now time.Time) *EventClosedError {
+	if event.State(now) == EventEnded {
+		return &EventClosedError{Event: event, Code: CodeEventEnded}
+	}
+	return nil
+}
+
+// eventClosedResponse builds the terminal-friendly response for a refused action
+func eventClosedResponse(closed *EventClosedError, now time.Time) EventClosedResponse {
+	response := EventClosedResponse{
+		Status:     "event_closed",
+		Code:       closed.Code,
+		Message:    eventClosedMessages[closed.Code],
+		Event:      closed.Event.ID,
+		ServerTime: now.Format(time.RFC3339),
+	}
+	if closed.Code == CodeEventNotStarted || closed.Code == CodeEventOutsideHours {
+		if opensAt := closed.Event.NextOpening(now); !opensAt.IsZero() {
+			response.OpensAt = opensAt.Format(time.RFC3339)
+		}
+	}
+	return response
+}
+
+// writeEventClosed writes the event closed error with a 403 status
+// The details carry the event, the server time and, when it reopens, opensAt
+func writeEventClosed(w http.ResponseWriter, r *http.Request, closed *EventClosedError) {
+	response := eventClosedResponse(closed, time.Now())
+	slog.InfoContext(r.Context(), "event refused request", "code", closed.Code)
+
+	details := map[string]string{"event": response.Event, "serverTime": response.ServerTime}
+	if response.OpensAt != "" {
+		details["opensAt"] = response.OpensAt
+	}
+	writeError(w, r, http.StatusForbidden, response.Code, response.Message, details)
+}
+
+// getEventStatus handles reporting the schedule state of an event by the server clock
+// It expects a GET request and returns the event state, opening and closing times
+func (srv *Server) getEventStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	response := EventStatusResponse{
+		Status:     "success",
+		Event:      event.ID,
+		Name:       event.Name,
+		State:      event.State(now),
+		ServerTime: now.Format(time.RFC3339),
+		PrizesOpen: event.PrizesOpen(now),
+	}
+	if opensAt := event.NextOpening(now); !opensAt.IsZero() {
+		response.OpensAt = opensAt.Format(time.RFC3339)
+	}
+	if closesAt := event.ClosingTime(now); !closesAt.IsZero() {
+		response.ClosesAt = closesAt.Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// runEventScheduler watches event schedules and closes in-progress sessions when an event ends, and
+// expires the sessions and conversations abandoned past quiz.sessionTTL
+// It returns when ctx is done, after finishing the pass in progress
+func (srv *Server) runEventScheduler(ctx context.Context, interval time.Duration) {
+	states := map[string]EventState{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		srv.expireFlowSessions(now)
+
+		srv.eventsMu.RLock()
+		list := make([]*Event, 0, len(srv.events))
+		for _, event := range srv.events {
+			list = append(list, event)
+		}
+		srv.eventsMu.RUnlock()
+
+		for _, event := range list {
+			state := event.State(now)
+			if state != EventEnded {
+				srv.expireSessions(event, now)
+			}
+			previous, seen := states[event.ID]
+			states[event.ID] = state
+			if seen && previous == state {
+				continue
+			}
+
+			slog.Info("event state changed", "event", event.ID, "state", state)
+			if state == EventEnded {
+				srv.closeEventSessions(event, now)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// closeEventSessions closes the in-progress conversation sessions and session files of an ended event
+func (srv *Server) closeEventSessions(event *Event, now time.Time) {
+	ctx, span := startSpan(context.Background(), "event.close_sessions", "event.id", event.ID)
+	defer span.End()
+
+	srv.sessionsMu.Lock()
+	closedFlows := 0
+	for _, session := range srv.flowSessions {
+		if session.EventID == event.ID && !session.Done {
+			session.Done = true
+			session.Closed = true
+			closedFlows++
+		}
+	}
+	srv.sessionsMu.Unlock()
+
+	srv.sessionFileMu.Lock()
+	closedFiles, err := sessions.CloseInProgress(ctx, event.DataDir(), now)
+	srv.sessionFileMu.Unlock()
+	if err != nil {
+		slog.Error("failed to close session files", "event", event.ID, "error", err)
+	}
+
+	srv.recordAudit(withAuditActor(ctx, "system"), event, AuditEventClose, event.ID, nil,
+		map[string]int{"closedConversations": closedFlows, "closedSessionFiles": closedFiles})
+	slog.Info("event ended", "event", event.ID, "closed_conversations", closedFlows, "closed_session_files", closedFiles)
+}
+
+// expireSessions marks the session files of an event still in progress quiz.sessionTTL after they
+// were registered as expired, so they can no longer be answered or resumed
+func (srv *Server) expireSessions(event *Event, now time.Time) {
+	ttl := time.Duration(srv.config.Quiz.SessionTTL)
+	if ttl <= 0 {
+		return
+	}
+	ctx, span := startSpan(context.Background(), "event.expire_sessions", "event.id", event.ID)
+	defer span.End()
+
+	srv.sessionFileMu.Lock()
+	expired, err := sessions.CloseExpired(ctx, event.DataDir(), ttl, now)
+	srv.sessionFileMu.Unlock()
+	if err != nil {
+		slog.Error("failed to expire session files", "event", event.ID, "error", err)
+	}
+	if expired == 0 {
+		return
+	}
+
+	srv.recordAudit(withAuditActor(ctx, "system"), event, AuditSessionExpire, event.ID, nil,
+		map[string]int{"expiredSessionFiles": expired})
+	slog.Info("sessions expired", "event", event.ID, "expired_session_files", expired)
+}
+
+// expireFlowSessions forgets the conversations without input for longer than quiz.sessionTTL,
+// including those closed when their event ended
+func (srv *Server) expireFlowSessions(now time.Time) {
+	ttl := time.Duration(srv.config.Quiz.SessionTTL)
+	if ttl <= 0 {
+		return
+	}
+
+	srv.sessionsMu.Lock()
+	expired := 0
+	for id, session := range srv.flowSessions {
+		if now.Sub(session.UpdatedAt) > ttl {
+			delete(srv.flowSessions, id)
+			expired++
+		}
+	}
+	srv.sessionsMu.Unlock()
+
+	if expired > 0 {
+		slog.Info("conversations expired", "expired_conversations", expired)
+	}
+}
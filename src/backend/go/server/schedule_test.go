@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"delfos/api"
+)
+
+func TestGetEventStatus(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{"default event", http.MethodGet, apiPrefix + "/event/status", nil, nil, http.StatusOK, ""},
+		{"unknown event", http.MethodGet, apiPrefix + "/event/status?event=nope", nil, nil, http.StatusNotFound, CodeEventNotFound},
+		{"wrong method", http.MethodPost, apiPrefix + "/event/status", nil, nil, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, nil)
+
+	tests := []struct {
+		event      string
+		wantState  EventState
+		wantPrizes bool
+		wantOpens  bool
+	}{
+		{"default", EventOpen, true, false},
+		{"ended", EventEnded, false, false},
+		{"upcoming", EventNotStarted, true, true},
+	}
+	s := newTestServer(t)
+	for _, tt := range tests {
+		status := decodeBody[EventStatusResponse](t, s.do(http.MethodGet, apiPrefix+"/event/status?event="+tt.event, nil, nil))
+		if status.Event != tt.event || status.State != tt.wantState || status.PrizesOpen != tt.wantPrizes || (status.OpensAt != "") != tt.wantOpens {
+			t.Errorf("%s status = %+v, want %s, prizesOpen %v", tt.event, status, tt.wantState, tt.wantPrizes)
+		}
+	}
+}
+
+func TestSessionFileWritesLocked(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(s *testServer, e *Event)
+	}{
+		{"event close", func(s *testServer, e *Event) { s.srv.closeEventSessions(e, time.Now()) }},
+		{"answers in bulk", func(s *testServer, e *Event) {
+			s.do(http.MethodPost, apiPrefix+"/user/update", api.UpdateUserRequest{UserEmail: "a@example.com", SessionID: "s-1", QuestionIds: []int{1}, UserAnswers: []string{"a"}}, nil)
+		}},
+		{"registration", func(s *testServer, e *Event) { createTestUser(s, "default", "a@example.com", "s-2") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			startQuiz(s, "default", "a@example.com", "s-1")
+			e, _ := s.srv.getEvent("default")
+
+			// A write while another update holds the lock would be lost when that update replaces the file
+			s.srv.sessionFileMu.Lock()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tt.write(s, e)
+			}()
+			select {
+			case <-done:
+				t.Error("wrote a session file without holding sessionFileMu")
+			case <-time.After(50 * time.Millisecond):
+			}
+			s.srv.sessionFileMu.Unlock()
+			<-done
+		})
+	}
+}
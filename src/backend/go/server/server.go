@@ -0,0 +1,210 @@
+// Package server is the HTTP transport of the DelfosProfiler API: the routes, middleware and the
+// event, kiosk, conversation flow and operator handlers
+//
+// New returns a Server holding its configuration and loaded state; it serves the API as an
+// http.Handler, and RunScheduler runs its event scheduler
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// schedulerInterval is how often the event scheduler checks event states and session expiry
+const schedulerInterval = 30 * time.Second
+
+// Server is a configured API server with its loaded events, flows and kiosks
+type Server struct {
+	config  Config
+	limiter *rateLimiter
+	mux     *http.ServeMux
+
+	eventsMu sync.RWMutex
+	events   map[string]*Event
+
+	// winnerMu serializes read-modify-write cycles on winner counts and prize stock
+	winnerMu sync.Mutex
+
+	kiosksMu sync.Mutex
+	kiosks   map[string]*Kiosk
+
+	flowsMu      sync.RWMutex
+	flows        map[string]*Flow
+	sessionsMu   sync.Mutex
+	flowSessions map[string]*FlowSession
+
+	// sessionFileMu serializes every write of a session file: most updates read the file and replace it,
+	// so a write between would be lost, e.g. concurrent draws for a session, answers sent in bulk or the
+	// scheduler's SessionClosed line. Registering, issuing questions, recording timings and answers,
+	// evaluating and closing all hold it
+	sessionFileMu sync.Mutex
+
+	// syncMu serializes sync batches so duplicate detection sees every applied record
+	syncMu sync.Mutex
+
+	// auditTail is the last entry written, so appends do not re-read the log
+	auditMu   sync.Mutex
+	auditTail struct {
+		path string
+		seq  int64
+		hash string
+	}
+
+	receiptKeyOnce sync.Once
+	receiptKey     []byte
+	sessionKeyOnce sync.Once
+	sessionKey     []byte
+
+	// Open WebSocket connections, closed with a going away frame on shutdown
+	wsClientsMu sync.Mutex
+	wsClients   map[*wsConn]struct{}
+	wsHandlers  sync.WaitGroup
+}
+
+// New applies the configuration and loads the conversation flows, events and kiosk registry
+// The returned server serves every route under apiPrefix and at its legacy path; its event
+// scheduler only runs in ListenAndServe or RunScheduler
+func New(cfg Config) (*Server, error) {
+	srv := &Server{
+		config:       cfg,
+		limiter:      &rateLimiter{buckets: map[string]*tokenBucket{}},
+		mux:          http.NewServeMux(),
+		events:       map[string]*Event{},
+		kiosks:       map[string]*Kiosk{},
+		flows:        map[string]*Flow{},
+		flowSessions: map[string]*FlowSession{},
+		wsClients:    map[*wsConn]struct{}{},
+	}
+
+	if err := srv.loadFlows(cfg.FlowsDir); err != nil {
+		return nil, fmt.Errorf("failed to load conversation flows: %w", err)
+	}
+	if err := srv.loadEvents(cfg.EventsDir); err != nil {
+		return nil, fmt.Errorf("failed to load events: %w", err)
+	}
+	if err := srv.loadKiosks(); err != nil {
+		return nil, fmt.Errorf("failed to load kiosks: %w", err)
+	}
+
+	srv.registerRoutes(srv.mux)
+	return srv, nil
+}
+
+// ServeHTTP serves the API
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// RunScheduler runs the event scheduler until ctx is done: it closes the sessions of events that
+// end and expires sessions abandoned past quiz.sessionTTL. Embedders mounting the server in their
+// own http.Server run it next to it; ListenAndServe runs it already
+func (srv *Server) RunScheduler(ctx context.Context) {
+	srv.runEventScheduler(ctx, schedulerInterval)
+}
+
+// ListenAndServe serves the API on the configured address, with the event scheduler running,
+// until SIGINT or SIGTERM; see runServer
+func (srv *Server) ListenAndServe() error {
+	endpoints := []string{}
+	for _, rt := range routes {
+		endpoint := rt.method + " " + apiPrefix + rt.path
+		if rt.role != "" {
+			endpoint += " (" + string(rt.role) + ")"
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	slog.Info("starting DelfosProfiler Go API server",
+		"addr", srv.config.Addr,
+		"data_dir", srv.config.DataDir,
+		"cors_origins", srv.config.CORS.AllowedOrigins,
+		"cors_admin_origins", srv.config.CORS.AdminOrigins,
+		"middleware", "request id + logging, tracing, CORS, kiosk identity, rate limiting, metrics",
+		"endpoints", endpoints,
+		"legacy_aliases", "every route without the "+apiPrefix+" prefix, and POST /kiosk/register",
+	)
+
+	return srv.runServer(srv.newHTTPServer(srv))
+}
+
+// newHTTPServer returns the HTTP server with the configured timeouts
+func (srv *Server) newHTTPServer(handler http.Handler) *http.Server {
+	server := &http.Server{
+		Addr:              srv.config.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(srv.config.Server.ReadTimeout),
+		ReadTimeout:       time.Duration(srv.config.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(srv.config.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(srv.config.Server.IdleTimeout),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	// Hijacked WebSocket connections are not tracked by Shutdown, close them ourselves
+	server.RegisterOnShutdown(srv.closeWebSockets)
+	return server
+}
+
+// runServer serves until SIGINT or SIGTERM, then shuts down gracefully:
+// it stops accepting connections, waits for in-flight requests and WebSocket messages
+// up to the shutdown timeout, stops the event scheduler and flushes queued spans
+func (srv *Server) runServer(server *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		srv.RunScheduler(ctx)
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		stop()
+		<-schedulerDone
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+	// A second signal terminates immediately
+	stop()
+
+	timeout := time.Duration(srv.config.Server.ShutdownTimeout)
+	slog.Info("shutting down", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var shutdownErr error
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("in-flight requests did not finish before the shutdown timeout", "error", err)
+		server.Close()
+		shutdownErr = err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("server stopped unexpectedly", "error", err)
+	}
+	if err := srv.waitWebSockets(shutdownCtx); err != nil {
+		slog.Warn("websocket handlers did not finish before the shutdown timeout", "error", err)
+		shutdownErr = err
+	}
+
+	select {
+	case <-schedulerDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("event scheduler did not stop before the shutdown timeout")
+	}
+
+	shutdownTracing(shutdownCtx)
+
+	slog.Info("server stopped")
+	return shutdownErr
+}
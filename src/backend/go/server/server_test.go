@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"delfos/api"
+)
+
+// testEvents are loaded next to the built-in default event by newTestServer
+var testEvents = map[string]string{
+	"fair": `{"id": "fair", "name": "Fair", "profiles": ["1", "2"], "attempts": {"maxAttemptsPerEmail": 2, "maxWinners": 3},
+		"prizes": [{"sku": "MUG", "name": "Mug", "weight": 1, "stock": 2}, {"sku": "HONOR", "name": "Honor", "weight": 1, "stock": -1}]}`,
+	"ended":    `{"id": "ended", "name": "Ended", "profiles": ["1"], "startsAt": "2020-01-01T00:00:00Z", "endsAt": "2020-01-02T00:00:00Z"}`,
+	"upcoming": `{"id": "upcoming", "name": "Upcoming", "profiles": ["1"], "startsAt": "2099-01-01T00:00:00Z"}`,
+}
+
+// testServer is the server returned by New over temporary data, events and flows directories,
+// with rate limits off and an admin API key "admin-key"
+type testServer struct {
+	t        testing.TB
+	srv      *Server
+	syncKeys map[string]string // Sync key of each kiosk token, from registerKiosk
+}
+
+func newTestServer(t testing.TB) *testServer {
+	t.Helper()
+	cfg := testConfig(t)
+	cfg.RateLimit.Read, cfg.RateLimit.Write, cfg.RateLimit.Admin = RateLimitRule{}, RateLimitRule{}, RateLimitRule{}
+	cfg.Admin.APIKeys = []AdminAPIKey{{Name: "ops", KeyHash: hashToken("admin-key"), Role: RoleAdmin}}
+	for id, definition := range testEvents {
+		if err := os.WriteFile(filepath.Join(cfg.EventsDir, id+".json"), []byte(definition), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &testServer{t: t, srv: newServer(t, cfg), syncKeys: map[string]string{}}
+}
+
+// testReceiptSecret signs the evaluation receipts of test servers, see testReceipt
+const testReceiptSecret = "test-receipt-secret-of-32-bytes!"
+
+// testConfig returns the default configuration over temporary data, events and flows directories
+func testConfig(t testing.TB) Config {
+	cfg := DefaultConfig()
+	cfg.DataDir, cfg.EventsDir, cfg.FlowsDir = t.TempDir(), t.TempDir(), t.TempDir()
+	cfg.Quiz.ReceiptSecret = testReceiptSecret
+	return cfg
+}
+
+// newServer returns the server New builds from cfg
+func newServer(t testing.TB, cfg Config) *Server {
+	t.Helper()
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return srv
+}
+
+// do serves a request; body is sent as is when it is a string and encoded as JSON otherwise
+func (s *testServer) do(method, path string, body any, header map[string]string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var content string
+	switch body := body.(type) {
+	case nil:
+	case string:
+		content = body
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		content = string(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(content))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	s.srv.ServeHTTP(rec, req)
+	return rec
+}
+
+// registerKiosk registers a kiosk for an event and returns its ID and the X-Kiosk-Token header
+func (s *testServer) registerKiosk(event string) (string, map[string]string) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, apiPrefix+"/admin/kiosks/register", RegisterKioskRequest{Name: "Booth", Event: event},
+		map[string]string{"X-API-Key": "admin-key"})
+	if rec.Code != http.StatusCreated {
+		s.t.Fatalf("register kiosk: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	registered := decodeBody[RegisterKioskResponse](s.t, rec)
+	s.syncKeys[registered.Token] = registered.SyncKey
+	return registered.KioskID, map[string]string{"X-Kiosk-Token": registered.Token}
+}
+
+// decodeBody decodes a JSON response body
+func decodeBody[T any](t testing.TB, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var body T
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %v (%q)", err, rec.Body.String())
+	}
+	return body
+}
+
+// handlerCase is a request and the status and error code it should get; an empty code expects success
+type handlerCase struct {
+	name       string
+	method     string
+	path       string
+	body       any
+	header     map[string]string
+	wantStatus int
+	wantCode   string
+}
+
+// runHandlerCases serves each case on a fresh test server, after setup when it is set, and checks the response
+func runHandlerCases(t *testing.T, tests []handlerCase, setup func(*testServer)) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			if setup != nil {
+				setup(s)
+			}
+			rec := s.do(tt.method, tt.path, tt.body, tt.header)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %q)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := decodeBody[ErrorResponse](t, rec).Code; got != tt.wantCode {
+				t.Errorf("code = %q, want %q", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestNewMountsHandler(t *testing.T) {
+	cfg := testConfig(t)
+	srv := httptest.NewServer(newServer(t, cfg))
+	defer srv.Close()
+
+	for _, path := range []string{"/api/v1/choose-questions?profile=2", "/choose-questions?profile=2"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		var body api.ChooseQuestionsResponse
+		err = json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s = %d, %v", path, resp.StatusCode, err)
+		}
+		if body.Profile != "2" || len(body.QuestionIds) != cfg.Quiz.QuestionsPerQuiz {
+			t.Errorf("GET %s = %+v, want %d questions of profile 2", path, body, cfg.Quiz.QuestionsPerQuiz)
+		}
+	}
+}
+
+func TestNewRejectsInvalidFlows(t *testing.T) {
+	cfg := testConfig(t)
+	if err := os.WriteFile(filepath.Join(cfg.FlowsDir, "broken.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(cfg); err == nil {
+		t.Fatal("New accepted an invalid flow file")
+	}
+}
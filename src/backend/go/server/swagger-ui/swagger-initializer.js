@@ -0,0 +1,10 @@
+// The document is served next to the docs, under the same prefix (/api/v1 or the legacy root)
+window.onload = function () {
+  window.ui = SwaggerUIBundle({
+    url: new URL('../openapi.json', window.location.href).href,
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis],
+    layout: 'BaseLayout',
+  });
+};
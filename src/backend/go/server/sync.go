@@ -0,0 +1,421 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"delfos/prizes"
+	"delfos/scoring"
+	"delfos/sessions"
+	"delfos/storage"
+)
+
+// SyncRecord represents a session record queued by a kiosk while it was offline
+type SyncRecord struct {
+	ID          string   `json:"id"`   // Unique per kiosk, used as idempotency key
+	Type        string   `json:"type"` // "registration", "answers" or "prize"
+	Event       string   `json:"event,omitempty"`
+	UserEmail   string   `json:"userEmail"`
+	SessionID   string   `json:"sessionId"`
+	QuestionIDs []string `json:"questionIds,omitempty"`
+	UserAnswers []string `json:"userAnswers,omitempty"`
+	PrizeSKU    string   `json:"prizeSku,omitempty"` // Prize the kiosk showed to the player while offline
+	RecordedAt  string   `json:"recordedAt"`         // Kiosk time of the action (RFC3339)
+	Signature   string   `json:"signature"`          // Hex HMAC-SHA256 of the canonical payload
+}
+
+// SyncRequest represents the request body for syncing offline records
+type SyncRequest struct {
+	Records []SyncRecord `json:"records"`
+}
+
+// SyncRecordResult represents the outcome for a single synced record
+type SyncRecordResult struct {
+	ID      string `json:"id"`
+	Status  string `json:"status"` // "applied", "duplicate" or "rejected"
+	Message string `json:"message,omitempty"`
+}
+
+// SyncResponse represents the response for a sync batch
+type SyncResponse struct {
+	Status     string             `json:"status"`
+	Message    string             `json:"message"`
+	Applied    int                `json:"applied"`
+	Duplicates int                `json:"duplicates"`
+	Rejected   int                `json:"rejected"`
+	Results    []SyncRecordResult `json:"results"`
+}
+
+// Kiosk clocks may drift, records this far in the future are still accepted
+const syncClockSkew = 5 * time.Minute
+
+// Largest batch accepted in a single sync request
+const maxSyncRecords = 500
+
+// canonicalPayload returns the string a kiosk signs for a record
+// Fields are joined with newlines in a fixed order, lists are comma separated
+func (rec SyncRecord) canonicalPayload() string {
+	return strings.Join([]string{
+		rec.ID,
+		rec.Type,
+		rec.Event,
+		rec.UserEmail,
+		rec.SessionID,
+		strings.Join(rec.QuestionIDs, ","),
+		strings.Join(rec.UserAnswers, ","),
+		rec.PrizeSKU,
+		rec.RecordedAt,
+	}, "\n")
+}
+
+// verifySignature checks the record signature against the kiosk's sync key, see kioskSyncKey
+func (rec SyncRecord) verifySignature(syncKey string) bool {
+	signature, err := hex.DecodeString(rec.Signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(syncKey))
+	mac.Write([]byte(rec.canonicalPayload()))
+	return hmac.Equal(signature, mac.Sum(nil))
+}
+
+// syncRecordsFile returns the path of the log of processed sync records
+func (srv *Server) syncRecordsFile() string {
+	return filepath.Join(srv.config.DataDir, "sync_records.txt")
+}
+
+// readSyncedRecords returns the keys ("kioskId|recordId") of the records already processed
+func (srv *Server) readSyncedRecords(ctx context.Context) (_ map[string]bool, err error) {
+	defer traceStorage(ctx, "read_sync_records")(&err)
+
+	seen := map[string]bool{}
+	content, err := os.ReadFile(srv.syncRecordsFile())
+	if os.IsNotExist(err) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync records file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) >= 2 {
+			seen[fields[0]+"|"+fields[1]] = true
+		}
+	}
+	return seen, nil
+}
+
+// appendSyncedRecord logs a processed record so it is never applied twice
+func (srv *Server) appendSyncedRecord(ctx context.Context, kioskID string, rec SyncRecord, result SyncRecordResult) (err error) {
+	defer traceStorage(ctx, "append_sync_record")(&err)
+
+	if err := os.MkdirAll(srv.config.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(srv.syncRecordsFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open sync records file: %w", err)
+	}
+	defer file.Close()
+
+	line := strings.Join([]string{kioskID, rec.ID, rec.Type, result.Status, time.Now().Format(time.RFC3339)}, "|") + "\n"
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write sync records file: %w", err)
+	}
+	return nil
+}
+
+// syncRecords handles uploading records a kiosk queued while offline
+// It expects a POST request from a kiosk identified by X-Kiosk-Token with a batch of signed records
+// Records are applied in recordedAt order and the same record ID is never applied twice
+func (srv *Server) syncRecords(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	kiosk := requestKiosk(r)
+	if kiosk == nil {
+		writeError(w, r, http.StatusUnauthorized, CodeKioskTokenRequired, "X-Kiosk-Token header is required", nil)
+		return
+	}
+
+	var req SyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.WarnContext(r.Context(), "invalid sync request", "error", err)
+		writeInvalidJSON(w, r, err)
+		return
+	}
+
+	if len(req.Records) == 0 {
+		writeMissingFields(w, r, []string{"records"})
+		return
+	}
+	if len(req.Records) > maxSyncRecords {
+		writeError(w, r, http.StatusRequestEntityTooLarge, CodeTooManyRecords, fmt.Sprintf("at most %d records per sync", maxSyncRecords),
+			map[string]int{"maxRecords": maxSyncRecords, "records": len(req.Records)})
+		return
+	}
+
+	// Registrations must land before the answers and prizes of the same session
+	sort.SliceStable(req.Records, func(i, j int) bool {
+		return req.Records[i].RecordedAt < req.Records[j].RecordedAt
+	})
+
+	srv.syncMu.Lock()
+	defer srv.syncMu.Unlock()
+
+	seen, err := srv.readSyncedRecords(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to read synced records", "error", err)
+		writeInternalError(w, r)
+		return
+	}
+
+	// kioskMiddleware matched the token to the kiosk, so it derives the kiosk's key
+	syncKey := srv.kioskSyncKey(r.Header.Get("X-Kiosk-Token"))
+	response := SyncResponse{Status: "success"}
+	for _, rec := range req.Records {
+		result := SyncRecordResult{ID: rec.ID}
+		key := kiosk.ID + "|" + rec.ID
+
+		// Each record gets its own log fields, a batch covers many sessions
+		ctx := withLogFields(r.Context())
+		addLogFields(ctx, "record_id", rec.ID, "record_type", rec.Type)
+		logSession(ctx, rec.UserEmail, rec.SessionID)
+
+		switch {
+		case rec.ID == "":
+			result.Status, result.Message = "rejected", "record id is required"
+		case seen[key]:
+			result.Status, result.Message = "duplicate", "record already synced"
+		case !rec.verifySignature(syncKey):
+			result.Status, result.Message = "rejected", "invalid signature"
+		default:
+			result = srv.applySyncRecord(ctx, kiosk, rec)
+		}
+
+		switch result.Status {
+		case "applied":
+			response.Applied++
+		case "duplicate":
+			response.Duplicates++
+		default:
+			response.Rejected++
+		}
+		response.Results = append(response.Results, result)
+
+		// Only signed records are remembered, so a corrupted upload can be retried
+		if rec.ID != "" && !seen[key] && result.Message != "invalid signature" {
+			if err := srv.appendSyncedRecord(ctx, kiosk.ID, rec, result); err != nil {
+				slog.ErrorContext(ctx, "failed to log synced record", "error", err)
+				writeInternalError(w, r)
+				return
+			}
+			seen[key] = true
+		}
+	}
+
+	response.Message = fmt.Sprintf("Synced %d records: %d applied, %d duplicates, %d rejected",
+		len(req.Records), response.Applied, response.Duplicates, response.Rejected)
+	slog.InfoContext(r.Context(), "kiosk records synced",
+		"records", len(req.Records), "applied", response.Applied, "duplicates", response.Duplicates, "rejected", response.Rejected)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// applySyncRecord applies a verified record to the event's data
+func (srv *Server) applySyncRecord(ctx context.Context, kiosk *Kiosk, rec SyncRecord) SyncRecordResult {
+	result := SyncRecordResult{ID: rec.ID}
+	reject := func(message string) SyncRecordResult {
+		result.Status, result.Message = "rejected", message
+		return result
+	}
+
+	eventID := rec.Event
+	if eventID == "" {
+		eventID = kiosk.Event
+	}
+	if eventID == "" {
+		eventID = defaultEventID
+	}
+	event, ok := srv.getEvent(eventID)
+	if !ok {
+		return reject("event not found")
+	}
+	addLogFields(ctx, "event", event.ID)
+
+	if rec.UserEmail == "" || rec.SessionID == "" {
+		return reject("userEmail and sessionId are required")
+	}
+	if sessions.ValidateName(rec.UserEmail, rec.SessionID) != nil {
+		return reject(`userEmail and sessionId must not contain line breaks, slashes or ".."`)
+	}
+	recordedAt, err := time.Parse(time.RFC3339, rec.RecordedAt)
+	if err != nil {
+		return reject("recordedAt must be an RFC3339 timestamp")
+	}
+	if recordedAt.After(time.Now().Add(syncClockSkew)) {
+		return reject("recordedAt is in the future")
+	}
+	if (!event.StartsAt.IsZero() && recordedAt.Before(event.StartsAt)) || (!event.EndsAt.IsZero() && !recordedAt.Before(event.EndsAt)) {
+		return reject("recordedAt is outside the event window")
+	}
+
+	dataDir := event.DataDir()
+
+	switch rec.Type {
+	case "registration":
+		// Held from the check to the write, so a replayed record cannot register the session twice
+		srv.sessionFileMu.Lock()
+		defer srv.sessionFileMu.Unlock()
+		if _, err := os.Stat(filepath.Join(dataDir, sessions.FileName(rec.UserEmail, rec.SessionID))); err == nil {
+			result.Status, result.Message = "duplicate", "session already registered"
+			return result
+		}
+		if limit := event.Attempts.MaxAttemptsPerEmail; limit > 0 {
+			attempts, err := sessions.CountAttempts(ctx, dataDir, rec.UserEmail)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to count attempts", "error", err)
+				return reject("internal error")
+			}
+			if attempts >= limit {
+				return reject("attempt limit reached for this event")
+			}
+		}
+		user, _, err := sessions.Save(ctx, dataDir, rec.UserEmail, rec.SessionID, kiosk.ID, rec.RecordedAt)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to create synced user file", "error", err)
+			return reject("internal error")
+		}
+		srv.recordKioskSession(ctx, kiosk.ID)
+		srv.auditUserCreated(ctx, event, rec.UserEmail, rec.SessionID, user.CreatedAt)
+
+	case "answers":
+		if len(rec.QuestionIDs) == 0 {
+			return reject("questionIds is required")
+		}
+		if err := scoring.Validate(rec.QuestionIDs, rec.UserAnswers); err != nil {
+			return reject(err.Error())
+		}
+		// Held from the check to the write, so the answers land in a session still in progress and
+		// the session is marked evaluated before an online evaluation can score it again
+		srv.sessionFileMu.Lock()
+		defer srv.sessionFileMu.Unlock()
+		content, err := sessions.Read(dataDir, rec.UserEmail, rec.SessionID)
+		if errors.Is(err, sessions.ErrNotFound) {
+			return reject("session not registered")
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to read synced user file", "error", err)
+			return reject("internal error")
+		}
+		if !sessions.InProgress(content) {
+			result.Status, result.Message = "duplicate", "session already has answers"
+			return result
+		}
+		// Sessions issued a quiz online are scored on it, in the option order they were shown
+		issued := sessions.Issued(content)
+		if issued.QuestionIDs != nil && !sameQuestions(issued.QuestionIDs, rec.QuestionIDs) {
+			return reject("questions were not issued to this session")
+		}
+
+		if err := sessions.AppendAnswers(ctx, dataDir, rec.UserEmail, rec.SessionID, rec.QuestionIDs, rec.UserAnswers); err != nil {
+			slog.ErrorContext(ctx, "failed to update synced user file", "error", err)
+			return reject("internal error")
+		}
+		srv.auditAnswersSubmitted(ctx, event, rec.SessionID, rec.QuestionIDs, rec.UserAnswers)
+		evaluation := srv.scoreAnswers(ctx, rec.QuestionIDs, rec.UserAnswers, issued, sessions.Timings(content))
+		recordQuizCompleted(event, rec.QuestionIDs, evaluation, srv.config.Quiz.PassScore)
+		if err := storage.AppendResult(ctx, dataDir, rec.UserEmail, rec.SessionID, evaluation); err != nil {
+			slog.ErrorContext(ctx, "failed to record synced result", "error", err)
+			return reject("internal error")
+		}
+		answers := make(map[string]string, len(rec.QuestionIDs))
+		for i, id := range rec.QuestionIDs {
+			answers[id] = strings.ToLower(strings.TrimSpace(rec.UserAnswers[i]))
+		}
+		if err := sessions.MarkEvaluated(ctx, dataDir, rec.UserEmail, rec.SessionID, answers, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "failed to mark synced session evaluated", "error", err)
+			return reject("internal error")
+		}
+		srv.auditEvaluation(ctx, event, rec.SessionID, rec.QuestionIDs, evaluation, srv.config.Quiz.PassScore)
+
+	case "prize":
+		message, err := srv.reconcilePrize(ctx, event, rec)
+		if err != nil {
+			return reject(err.Error())
+		}
+		result.Message = message
+
+	default:
+		return reject(fmt.Sprintf("unknown record type %q", rec.Type))
+	}
+
+	if result.Status == "" {
+		result.Status = "applied"
+	}
+	return result
+}
+
+// reconcilePrize records a prize a kiosk handed out while offline
+// The prize was already given to the player, so it is always recorded and the stock is
+// allowed to go negative; the returned message flags oversold prizes for the staff
+func (srv *Server) reconcilePrize(ctx context.Context, event *Event, rec SyncRecord) (string, error) {
+	prize, ok := prizes.Find(event.Prizes, rec.PrizeSKU)
+	if !ok {
+		return "", fmt.Errorf("unknown prize sku %q", rec.PrizeSKU)
+	}
+
+	srv.winnerMu.Lock()
+	defer srv.winnerMu.Unlock()
+
+	dataDir := event.DataDir()
+	awards, err := prizes.ReadAwards(ctx, dataDir)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read prize awards", "error", err)
+		return "", fmt.Errorf("internal error")
+	}
+	if award, ok := prizes.ForSession(awards, rec.UserEmail, rec.SessionID); ok {
+		return "", fmt.Errorf("session already has prize %s", award.SKU)
+	}
+	awarded := 0
+	for _, award := range awards {
+		if award.SKU == prize.SKU {
+			awarded++
+		}
+	}
+
+	winnerCount, err := storage.ReadWinnerCount(ctx, dataDir)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read winner count", "error", err)
+		return "", fmt.Errorf("internal error")
+	}
+
+	award := prizes.Award{AwardedAt: rec.RecordedAt, SKU: prize.SKU, UserEmail: rec.UserEmail, SessionID: rec.SessionID}
+	if _, err := srv.recordAwardLocked(ctx, event, prize, award, winnerCount); err != nil {
+		slog.ErrorContext(ctx, "failed to record synced prize", "error", err)
+		return "", fmt.Errorf("internal error")
+	}
+
+	if prize.Physical() && awarded >= prize.Stock {
+		slog.WarnContext(ctx, "offline prize oversold", "sku", prize.SKU, "awarded", awarded+1, "stock", prize.Stock)
+		return fmt.Sprintf("prize %s oversold: %d awarded for a stock of %d", prize.SKU, awarded+1, prize.Stock), nil
+	}
+	return "", nil
+}
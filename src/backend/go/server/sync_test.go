@@ -0,0 +1,262 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"delfos/api"
+	"delfos/prizes"
+)
+
+// signRecord signs a record the way a kiosk does, with the sync key its registration returned
+func signRecord(syncKey string, rec SyncRecord) SyncRecord {
+	mac := hmac.New(sha256.New, []byte(syncKey))
+	mac.Write([]byte(rec.canonicalPayload()))
+	rec.Signature = hex.EncodeToString(mac.Sum(nil))
+	return rec
+}
+
+func TestSyncRequest(t *testing.T) {
+	// setup fills the kiosk header of each case's fresh server
+	kiosk := map[string]string{}
+	tooMany := SyncRequest{Records: make([]SyncRecord, maxSyncRecords+1)}
+	runHandlerCases(t, []handlerCase{
+		{"no token", http.MethodPost, apiPrefix + "/sync", `{"records": []}`, nil, http.StatusUnauthorized, CodeKioskTokenRequired},
+		{"malformed json", http.MethodPost, apiPrefix + "/sync", `{"records": [}`, kiosk, http.StatusBadRequest, CodeInvalidJSON},
+		{"no records", http.MethodPost, apiPrefix + "/sync", `{"records": []}`, kiosk, http.StatusBadRequest, CodeMissingFields},
+		{"too many records", http.MethodPost, apiPrefix + "/sync", tooMany, kiosk, http.StatusRequestEntityTooLarge, CodeTooManyRecords},
+		{"wrong method", http.MethodGet, apiPrefix + "/sync", nil, kiosk, http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	}, func(s *testServer) {
+		_, header := s.registerKiosk("")
+		kiosk["X-Kiosk-Token"] = header["X-Kiosk-Token"]
+	})
+}
+
+func TestSyncRecords(t *testing.T) {
+	registeredAt := time.Now().Add(-time.Hour).UTC()
+	registration := SyncRecord{ID: "r-1", Type: "registration", UserEmail: "a@example.com", SessionID: "s-1",
+		RecordedAt: registeredAt.Format(time.RFC3339)}
+	answers := SyncRecord{ID: "r-2", Type: "answers", UserEmail: "a@example.com", SessionID: "s-1",
+		QuestionIDs: []string{"CRD0001", "CRD0002"}, UserAnswers: []string{"a", "b"}, RecordedAt: registeredAt.Add(time.Minute).Format(time.RFC3339)}
+
+	tests := []struct {
+		name        string
+		records     func(syncKey string) []SyncRecord
+		wantResults []string // Status of each record, in recordedAt order
+	}{
+		{"registration and answers out of order", func(key string) []SyncRecord {
+			return []SyncRecord{signRecord(key, answers), signRecord(key, registration)}
+		}, []string{"applied", "applied"}},
+		{"replayed record", func(key string) []SyncRecord {
+			return []SyncRecord{signRecord(key, registration), signRecord(key, registration)}
+		}, []string{"applied", "duplicate"}},
+		{"invalid signature", func(key string) []SyncRecord {
+			rec := signRecord(key, registration)
+			rec.UserEmail = "b@example.com"
+			return []SyncRecord{rec}
+		}, []string{"rejected"}},
+		{"missing id", func(key string) []SyncRecord {
+			rec := registration
+			rec.ID = ""
+			return []SyncRecord{signRecord(key, rec)}
+		}, []string{"rejected"}},
+		{"answers without registration", func(key string) []SyncRecord {
+			return []SyncRecord{signRecord(key, answers)}
+		}, []string{"rejected"}},
+		{"mismatched answers", func(key string) []SyncRecord {
+			rec := answers
+			rec.UserAnswers = []string{"a"}
+			return []SyncRecord{signRecord(key, registration), signRecord(key, rec)}
+		}, []string{"applied", "rejected"}},
+		{"future record", func(key string) []SyncRecord {
+			rec := registration
+			rec.RecordedAt = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			return []SyncRecord{signRecord(key, rec)}
+		}, []string{"rejected"}},
+		{"header in session ID", func(key string) []SyncRecord {
+			rec := registration
+			rec.SessionID = "s-1\nQuestions: CRD0001"
+			return []SyncRecord{signRecord(key, rec)}
+		}, []string{"rejected"}},
+		{"unknown type", func(key string) []SyncRecord {
+			rec := registration
+			rec.Type = "nope"
+			return []SyncRecord{signRecord(key, rec)}
+		}, []string{"rejected"}},
+		{"unknown prize", func(key string) []SyncRecord {
+			rec := registration
+			rec.ID, rec.Type, rec.PrizeSKU = "r-3", "prize", "NOPE"
+			return []SyncRecord{signRecord(key, registration), signRecord(key, rec)}
+		}, []string{"applied", "rejected"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			_, kiosk := s.registerKiosk("")
+			rec := s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: tt.records(s.syncKeys[kiosk["X-Kiosk-Token"]])}, kiosk)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", rec.Code, rec.Body.String())
+			}
+			synced := decodeBody[SyncResponse](t, rec)
+			var got []string
+			for _, result := range synced.Results {
+				got = append(got, result.Status)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantResults, ",") {
+				t.Errorf("results = %+v, want %v", synced.Results, tt.wantResults)
+			}
+		})
+	}
+}
+
+func TestSyncKeyIsNotStored(t *testing.T) {
+	s := newTestServer(t)
+	kioskID, kiosk := s.registerKiosk("")
+	key := s.syncKeys[kiosk["X-Kiosk-Token"]]
+	recordedAt := time.Now().UTC().Format(time.RFC3339)
+	registration := SyncRecord{ID: "r-1", Type: "registration", UserEmail: "a@example.com", SessionID: "s-1", RecordedAt: recordedAt}
+
+	// Whoever reads kiosks.json gets the token hash, which must not sign records
+	content, err := os.ReadFile(s.srv.kiosksFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == "" || strings.Contains(string(content), key) {
+		t.Fatalf("sync key %q is empty or stored in kiosks.json", key)
+	}
+	stored := signRecord(s.srv.kiosks[kioskID].TokenHash, registration)
+	synced := decodeBody[SyncResponse](t, s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: []SyncRecord{stored}}, kiosk))
+	if synced.Rejected != 1 {
+		t.Errorf("record signed with the token hash: %+v, want it rejected", synced.Results)
+	}
+	synced = decodeBody[SyncResponse](t, s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: []SyncRecord{signRecord(key, registration)}}, kiosk))
+	if synced.Applied != 1 {
+		t.Errorf("record signed with the sync key: %+v, want it applied", synced.Results)
+	}
+
+	// The key depends on kiosks.syncSecret
+	s.srv.config.Kiosks.SyncSecret = strings.Repeat("s", 32)
+	if s.srv.kioskSyncKey(kiosk["X-Kiosk-Token"]) == key {
+		t.Error("kiosks.syncSecret does not change the sync key")
+	}
+}
+
+func TestSyncAnswersEvaluateSession(t *testing.T) {
+	s := newTestServer(t)
+	_, kiosk := s.registerKiosk("fair")
+	ids := startQuiz(s, "fair", "a@example.com", "s-1")
+	answers := make([]string, len(ids))
+	for i := range ids {
+		answers[i] = "a"
+	}
+	synced := decodeBody[SyncResponse](t, s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: []SyncRecord{
+		signRecord(s.syncKeys[kiosk["X-Kiosk-Token"]], SyncRecord{ID: "r-1", Type: "answers", UserEmail: "a@example.com", SessionID: "s-1",
+			QuestionIDs: ids, UserAnswers: answers, RecordedAt: time.Now().UTC().Format(time.RFC3339)}),
+	}}, kiosk))
+	if synced.Applied != 1 {
+		t.Fatalf("sync = %+v, want the answers applied", synced)
+	}
+
+	// The synced answers were scored, so the session cannot be evaluated online with others
+	answers[0] = "b"
+	rec := s.do(http.MethodPost, apiPrefix+"/evaluate-answers?event=fair", api.EvaluateAnswersRequest{
+		QuestionIds: ids, UserAnswers: answers, UserEmail: "a@example.com", SessionID: "s-1",
+	}, nil)
+	if rec.Code != http.StatusConflict || decodeBody[ErrorResponse](t, rec).Code != CodeAnswerConflict {
+		t.Errorf("online evaluation: status = %d, body %q", rec.Code, rec.Body.String())
+	}
+	results, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "events", "fair", "results.txt"))
+	if err != nil || strings.Count(string(results), "|a@example.com|s-1|") != 1 {
+		t.Errorf("results.txt = %q, %v, want the synced result only", results, err)
+	}
+}
+
+func TestSyncAppliesRecords(t *testing.T) {
+	s := newTestServer(t)
+	kioskID, kiosk := s.registerKiosk("fair")
+	recordedAt := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	records := []SyncRecord{
+		{ID: "r-1", Type: "registration", UserEmail: "a@example.com", SessionID: "s-1", RecordedAt: recordedAt},
+		{ID: "r-2", Type: "answers", UserEmail: "a@example.com", SessionID: "s-1",
+			QuestionIDs: []string{"CRD0001"}, UserAnswers: correctAnswers(t, []string{"CRD0001"}), RecordedAt: recordedAt},
+	}
+	// Three offline mugs for a stock of two: the last is recorded but flagged
+	for i := range 3 {
+		records = append(records, SyncRecord{ID: fmt.Sprintf("p-%d", i), Type: "prize", UserEmail: "a@example.com",
+			SessionID: fmt.Sprintf("s-%d", i+1), PrizeSKU: "MUG", RecordedAt: recordedAt})
+	}
+	for i := range records {
+		records[i] = signRecord(s.syncKeys[kiosk["X-Kiosk-Token"]], records[i])
+	}
+
+	synced := decodeBody[SyncResponse](t, s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: records}, kiosk))
+	if synced.Applied != len(records) || synced.Rejected != 0 {
+		t.Fatalf("sync = %+v, want every record applied", synced)
+	}
+	if !strings.Contains(synced.Results[len(records)-1].Message, "oversold") {
+		t.Errorf("third mug message = %q, want it flagged as oversold", synced.Results[len(records)-1].Message)
+	}
+
+	dataDir := filepath.Join(s.srv.config.DataDir, "events", "fair")
+	content, err := os.ReadFile(filepath.Join(dataDir, "a@example.com_s-1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "KioskID: "+kioskID+"\n") || !strings.Contains(string(content), "CRD0001\nA\n") ||
+		!strings.Contains(string(content), "Evaluated: ") {
+		t.Errorf("session file = %q, want the answers and the session evaluated", content)
+	}
+	results, err := os.ReadFile(filepath.Join(dataDir, "results.txt"))
+	if err != nil || !strings.Contains(string(results), "|a@example.com|s-1|1|1|100.00") {
+		t.Errorf("results.txt = %q, %v", results, err)
+	}
+	awards, err := prizes.ReadAwards(t.Context(), dataDir)
+	if err != nil || len(awards) != 3 {
+		t.Errorf("awards = %+v, %v, want 3", awards, err)
+	}
+	count := decodeBody[api.WinnerCountResponse](t, s.do(http.MethodGet, apiPrefix+"/winner/count?event=fair", nil, nil))
+	if count.WinnerCount != 3 {
+		t.Errorf("winner count = %d, want the 3 mugs handed out", count.WinnerCount)
+	}
+
+	// The same batch again only finds duplicates
+	again := decodeBody[SyncResponse](t, s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: records}, kiosk))
+	if again.Duplicates != len(records) {
+		t.Errorf("replayed sync = %+v, want only duplicates", again)
+	}
+}
+
+func TestSyncAnswersToIssuedQuiz(t *testing.T) {
+	s := newTestServer(t)
+	_, kiosk := s.registerKiosk("")
+	ids := startQuiz(s, "default", "a@example.com", "s-1")
+	other := startQuiz(s, "default", "a@example.com", "s-2")
+	recordedAt := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	records := []SyncRecord{
+		// Answered offline with the shuffled letters the kiosk showed
+		{ID: "r-1", Type: "answers", UserEmail: "a@example.com", SessionID: "s-1", QuestionIDs: ids,
+			UserAnswers: shownAnswers(s, "default", "a@example.com", "s-1", ids, correctAnswers(t, ids)), RecordedAt: recordedAt},
+		{ID: "r-2", Type: "answers", UserEmail: "a@example.com", SessionID: "s-2", QuestionIDs: other[1:],
+			UserAnswers: correctAnswers(t, other[1:]), RecordedAt: recordedAt},
+	}
+	for i := range records {
+		records[i] = signRecord(s.syncKeys[kiosk["X-Kiosk-Token"]], records[i])
+	}
+
+	synced := decodeBody[SyncResponse](t, s.do(http.MethodPost, apiPrefix+"/sync", SyncRequest{Records: records}, kiosk))
+	if len(synced.Results) != 2 || synced.Results[0].Status != "applied" || synced.Results[1].Status != "rejected" {
+		t.Fatalf("sync = %+v, want the issued quiz applied and the other questions rejected", synced.Results)
+	}
+	results, err := os.ReadFile(filepath.Join(s.srv.config.DataDir, "results.txt"))
+	if want := fmt.Sprintf("|a@example.com|s-1|%d|%d|100.00", len(ids), len(ids)); err != nil || !strings.Contains(string(results), want) {
+		t.Errorf("results.txt = %q, %v, want %q", results, err, want)
+	}
+}
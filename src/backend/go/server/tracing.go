@@ -0,0 +1,446 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"delfos/storage"
+)
+
+// Span kinds, numbered as in the OTLP protocol
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+)
+
+// Span status code for failed operations, numbered as in the OTLP protocol
+const spanStatusError = 2
+
+const (
+	spanContextKey         contextKey = "span"
+	remoteParentContextKey contextKey = "remoteParent"
+
+	traceBatchSize     = 512
+	traceQueueSize     = 4096
+	traceFlushInterval = 5 * time.Second
+)
+
+// Span is a timed operation in a trace, exported in the OpenTelemetry (OTLP) model
+// A nil *Span is valid and does nothing, which is what startSpan returns when tracing is off
+type Span struct {
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+
+	mu            sync.Mutex
+	attributes    map[string]any
+	status        int
+	statusMessage string
+}
+
+// spanExporter sends finished spans to a backend
+type spanExporter interface {
+	Export(spans []*Span) error
+}
+
+// tracer batches finished spans and hands them to the exporter in the background
+type tracer struct {
+	service  string
+	exporter spanExporter
+	queue    chan *Span
+	flush    chan chan struct{}
+	dropped  atomic.Int64
+}
+
+var activeTracer *tracer
+
+// SetupTracing configures tracing from the standard OpenTelemetry environment variables
+// OTEL_TRACES_EXPORTER is otlp, console (stdout) or none (default); OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT set the collector URL and OTEL_SERVICE_NAME the service name
+func SetupTracing() error {
+	service := envOrDefault("OTEL_SERVICE_NAME", "delfos-api")
+
+	var exporter spanExporter
+	switch name := envOrDefault("OTEL_TRACES_EXPORTER", "none"); name {
+	case "none":
+		return nil
+	case "console", "stdout":
+		exporter = &stdoutExporter{w: os.Stdout}
+	case "otlp":
+		endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+		if endpoint == "" {
+			endpoint = strings.TrimSuffix(envOrDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"), "/") + "/v1/traces"
+		}
+		exporter = &otlpExporter{
+			endpoint: endpoint,
+			service:  service,
+			client:   &http.Client{Timeout: 10 * time.Second},
+		}
+	default:
+		return fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q (use otlp, console or none)", name)
+	}
+
+	activeTracer = &tracer{
+		service:  service,
+		exporter: exporter,
+		queue:    make(chan *Span, traceQueueSize),
+		flush:    make(chan chan struct{}),
+	}
+	go activeTracer.run()
+	slog.Info("tracing enabled", "exporter", fmt.Sprintf("%T", exporter), "service", service)
+	return nil
+}
+
+// shutdownTracing exports the spans still queued, waiting at most until ctx is done
+func shutdownTracing(ctx context.Context) {
+	if activeTracer == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case activeTracer.flush <- done:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// run exports spans in batches, on a timer or when a flush is requested
+func (t *tracer) run() {
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			slog.Warn("failed to export spans", "spans", len(batch), "error", err)
+		}
+		if dropped := t.dropped.Swap(0); dropped > 0 {
+			slog.Warn("dropped spans because the export queue was full", "spans", dropped)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-t.queue:
+			batch = append(batch, span)
+			if len(batch) >= traceBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case done := <-t.flush:
+			for len(t.queue) > 0 {
+				batch = append(batch, <-t.queue)
+			}
+			export()
+			close(done)
+		}
+	}
+}
+
+// startSpan starts a span as a child of the span in ctx, or a new trace if there is none
+// args are attribute key/value pairs
+func startSpan(ctx context.Context, name string, args ...any) (context.Context, *Span) {
+	return startSpanKind(ctx, name, spanKindInternal, args...)
+}
+
+func startSpanKind(ctx context.Context, name string, kind int, args ...any) (context.Context, *Span) {
+	if activeTracer == nil {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, kind: kind, start: time.Now(), attributes: map[string]any{}}
+	if parent, ok := ctx.Value(spanContextKey).(*Span); ok && parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else if traceID, parentID, ok := remoteParent(ctx); ok {
+		span.traceID = traceID
+		span.parentID = parentID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+	span.SetAttributes(args...)
+
+	return context.WithValue(ctx, spanContextKey, span), span
+}
+
+// SetAttributes adds key/value attributes to the span
+func (s *Span) SetAttributes(args ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); ok {
+			s.attributes[key] = args[i+1]
+		}
+	}
+}
+
+// SetError marks the span as failed with the error message
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = spanStatusError
+	s.statusMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil || activeTracer == nil {
+		return
+	}
+	s.end = time.Now()
+	select {
+	case activeTracer.queue <- s:
+	default:
+		// Never block a request on the exporter, drop the span instead
+		activeTracer.dropped.Add(1)
+	}
+}
+
+// TraceID returns the hex trace ID of the span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+func init() {
+	storage.Trace = traceStorage
+}
+
+// traceStorage starts a span for a storage operation inside a traced operation; the returned
+// function ends it and counts the error in the storage error metric
+// Storage helpers call it as: defer traceStorage(ctx, "operation")(&err)
+func traceStorage(ctx context.Context, operation string) func(err *error) {
+	var span *Span
+	if ctx.Value(spanContextKey) != nil {
+		_, span = startSpan(ctx, "storage."+operation, "storage.operation", operation)
+	}
+	return func(err *error) {
+		if *err != nil {
+			storageErrors.Inc(operation)
+			span.SetError(*err)
+		}
+		span.End()
+	}
+}
+
+// remoteParent returns the trace and span IDs of an incoming traceparent stored in ctx
+func remoteParent(ctx context.Context) ([16]byte, [8]byte, bool) {
+	parent, ok := ctx.Value(remoteParentContextKey).([24]byte)
+	if !ok {
+		return [16]byte{}, [8]byte{}, false
+	}
+	var traceID [16]byte
+	var spanID [8]byte
+	copy(traceID[:], parent[:16])
+	copy(spanID[:], parent[16:])
+	return traceID, spanID, true
+}
+
+// parseTraceparent parses a W3C traceparent header ("00-<trace id>-<span id>-<flags>")
+func parseTraceparent(header string) ([24]byte, bool) {
+	var parent [24]byte
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return parent, false
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return parent, false
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return parent, false
+	}
+	copy(parent[:16], traceID)
+	copy(parent[16:], spanID)
+	return parent, parent != [24]byte{}
+}
+
+// tracingMiddleware starts a server span for each request, continuing the caller's trace when
+// a traceparent header is sent, and adds the trace ID to the request's log fields
+func tracingMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if activeTracer == nil {
+			next(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		if parent, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = context.WithValue(ctx, remoteParentContextKey, parent)
+		}
+		ctx, span := startSpanKind(ctx, r.Method+" "+r.URL.Path, spanKindServer,
+			"http.request.method", r.Method,
+			"url.path", r.URL.Path,
+			"client.address", r.RemoteAddr,
+			"request.id", requestID(ctx),
+		)
+		addLogFields(ctx, "trace_id", span.TraceID())
+		w.Header().Set("traceparent", fmt.Sprintf("00-%s-%s-01", span.TraceID(), hex.EncodeToString(span.spanID[:])))
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(ctx))
+
+		span.SetAttributes("http.response.status_code", recorder.status)
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%s", http.StatusText(recorder.status)))
+		}
+		span.End()
+	}
+}
+
+// stdoutExporter writes one JSON object per span, for checking traces without a collector
+type stdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (e *stdoutExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	encoder := json.NewEncoder(e.w)
+	for _, span := range spans {
+		if err := encoder.Encode(span.otlp()); err != nil {
+			return fmt.Errorf("failed to write span: %w", err)
+		}
+	}
+	return nil
+}
+
+// otlpExporter posts spans to an OpenTelemetry collector using OTLP over HTTP with JSON encoding
+type otlpExporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+}
+
+func (e *otlpExporter) Export(spans []*Span) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, span.otlp())
+	}
+
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpAttribute{otlpAttr("service.name", e.service)},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "delfos-api"},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send spans: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpSpan is the OTLP JSON encoding of a span
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	encoded := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.status, Message: s.statusMessage},
+	}
+	if s.parentID != [8]byte{} {
+		encoded.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for _, key := range sortedKeys(s.attributes) {
+		encoded.Attributes = append(encoded.Attributes, otlpAttr(key, s.attributes[key]))
+	}
+	return encoded
+}
+
+// otlpAttr encodes an attribute value as an OTLP AnyValue
+func otlpAttr(key string, value any) otlpAttribute {
+	switch v := value.(type) {
+	case bool:
+		return otlpAttribute{Key: key, Value: map[string]any{"boolValue": v}}
+	case int:
+		return otlpAttribute{Key: key, Value: map[string]any{"intValue": strconv.Itoa(v)}}
+	case int64:
+		return otlpAttribute{Key: key, Value: map[string]any{"intValue": strconv.FormatInt(v, 10)}}
+	case float64:
+		return otlpAttribute{Key: key, Value: map[string]any{"doubleValue": v}}
+	default:
+		return otlpAttribute{Key: key, Value: map[string]any{"stringValue": fmt.Sprint(v)}}
+	}
+}
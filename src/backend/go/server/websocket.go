@@ -0,0 +1,349 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes and limits (RFC 6455)
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+
+	wsMaxMessageSize = 64 * 1024
+	wsAcceptGUID     = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// wsConn is a minimal server side WebSocket connection
+type wsConn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+}
+
+// FlowMessage represents a terminal message received over the WebSocket
+type FlowMessage struct {
+	Type      string `json:"type"`
+	SessionID string `json:"sessionId"`
+	Content   string `json:"content"`
+	Flow      string `json:"flow,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+}
+
+// upgradeWebSocket performs the WebSocket handshake and takes over the connection
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, fmt.Errorf("not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key header")
+	}
+
+	// ResponseController unwraps middleware response writers down to the connection
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hijack connection: %w", err)
+	}
+	// The server read/write timeouts stay on the hijacked connection, a socket lives longer than a request
+	conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := rw.WriteString(handshake); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to write handshake: %w", err)
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to flush handshake: %w", err)
+	}
+
+	return &wsConn{conn: conn, reader: rw.Reader}, nil
+}
+
+// headerContains reports whether a comma separated header contains the token (case-insensitive)
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage reads the next complete text or binary message, answering pings on the way
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			code := wsCloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload[:2]))
+			}
+			c.Close(code, "")
+			return nil, errWebSocketClosed
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			message = append(message, payload...)
+			if len(message) > wsMaxMessageSize {
+				c.Close(1009, "message too big")
+				return nil, fmt.Errorf("websocket message exceeds %d bytes", wsMaxMessageSize)
+			}
+			if fin {
+				return message, nil
+			}
+		default:
+			c.Close(1002, "unknown opcode")
+			return nil, fmt.Errorf("unknown websocket opcode %d", opcode)
+		}
+	}
+}
+
+// readFrame reads and unmasks a single frame from the client
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+
+	if length > wsMaxMessageSize {
+		return false, 0, nil, fmt.Errorf("websocket frame exceeds %d bytes", wsMaxMessageSize)
+	}
+	if !masked {
+		return false, 0, nil, fmt.Errorf("client frames must be masked")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame to the client
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, payload...)
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// WriteJSON sends a value as a JSON text message
+func (c *wsConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// Close sends a close frame with the given code and closes the connection
+func (c *wsConn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	c.writeFrame(wsOpClose, payload)
+	return c.conn.Close()
+}
+
+// trackWebSocket registers an open connection; it returns false once shutdown has started
+func (srv *Server) trackWebSocket(conn *wsConn) bool {
+	srv.wsClientsMu.Lock()
+	defer srv.wsClientsMu.Unlock()
+	if srv.wsClients == nil {
+		return false
+	}
+	srv.wsClients[conn] = struct{}{}
+	srv.wsHandlers.Add(1)
+	return true
+}
+
+// untrackWebSocket removes a connection once its handler is done with it
+func (srv *Server) untrackWebSocket(conn *wsConn) {
+	srv.wsClientsMu.Lock()
+	delete(srv.wsClients, conn)
+	srv.wsClientsMu.Unlock()
+	srv.wsHandlers.Done()
+}
+
+// closeWebSockets sends a going away close frame to every open connection and refuses new ones
+// A handler finishes the message in progress and notices the closed connection on its next read
+func (srv *Server) closeWebSockets() {
+	srv.wsClientsMu.Lock()
+	open := srv.wsClients
+	srv.wsClients = nil
+	srv.wsClientsMu.Unlock()
+
+	for conn := range open {
+		conn.Close(wsCloseGoingAway, "server shutting down")
+	}
+}
+
+// waitWebSockets waits for the WebSocket handlers to return, or for ctx to be done
+func (srv *Server) waitWebSockets(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		srv.wsHandlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flowWebSocket handles the WebSocket transport for conversation flows
+// Each text message is a FlowMessage and is answered with a ProcessResponse
+func (srv *Server) flowWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+	event, ok := srv.requestEvent(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "websocket upgrade failed", "error", err)
+		writeError(w, r, http.StatusBadRequest, CodeUpgradeRequired, "WebSocket upgrade required", map[string]string{"reason": err.Error()})
+		return
+	}
+	if !srv.trackWebSocket(conn) {
+		conn.Close(wsCloseGoingAway, "server shutting down")
+		return
+	}
+	defer srv.untrackWebSocket(conn)
+	slog.InfoContext(r.Context(), "websocket client connected", "remote_addr", r.RemoteAddr)
+
+	flowID := r.URL.Query().Get("flow")
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			if err != errWebSocketClosed && err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				slog.WarnContext(r.Context(), "failed to read websocket message", "error", err)
+			}
+			break
+		}
+
+		var msg FlowMessage
+		if err := json.Unmarshal(data, &msg); err != nil || msg.SessionID == "" {
+			conn.WriteJSON(map[string]string{"type": "error", "message": "sessionId is required"})
+			continue
+		}
+		if msg.Flow != "" {
+			flowID = msg.Flow
+		}
+
+		// Each message gets its own log fields, a connection may serve several sessions
+		ctx := withLogFields(r.Context())
+		addLogFields(ctx, "session_id", msg.SessionID)
+
+		response, err := srv.advanceFlow(ctx, msg.SessionID, event, requestKioskID(r), flowID, msg.Content)
+		var closed *EventClosedError
+		if errors.As(err, &closed) {
+			conn.WriteJSON(eventClosedResponse(closed, time.Now()))
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to process websocket input", "error", err)
+			conn.WriteJSON(map[string]string{"type": "error", "message": err.Error()})
+			continue
+		}
+		if err := conn.WriteJSON(response); err != nil {
+			slog.WarnContext(ctx, "failed to write websocket message", "error", err)
+			break
+		}
+	}
+
+	conn.conn.Close()
+	slog.InfoContext(r.Context(), "websocket client disconnected", "remote_addr", r.RemoteAddr)
+}
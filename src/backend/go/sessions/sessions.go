@@ -0,0 +1,549 @@
+// Package sessions stores player sessions as plain text files in an event's data directory
+//
+// A session file is named userEmail_sessionId.txt and starts with "Key: value" header lines, among
+// them "Questions:" with the question IDs issued to the session and "OptionOrders:" with the order
+// their options are shown in; "Served:" and "Answered:" lines hold a question ID and when it was
+// shown and answered, "Answer:" lines a question ID and the letter recorded for it one at a time,
+// and "Evaluated:" when those were scored. "ResumeCode:" holds the code the player resumes the
+// session with, and "SessionExpired:" when it was abandoned. Answers sent in bulk are appended as
+// two plain lines, the comma separated question IDs and answers
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"delfos/scoring"
+	"delfos/storage"
+)
+
+// ErrNotFound is returned for a session that was never registered
+var ErrNotFound = errors.New("session not found")
+
+// ErrClosed is returned when questions are issued or answered in a session that has answers or was closed
+var ErrClosed = errors.New("session closed")
+
+// ErrExpired is returned for a session that was abandoned past its time to live
+var ErrExpired = errors.New("session expired")
+
+// ErrExists is returned when registering a session that already has a session file
+var ErrExists = errors.New("session already registered")
+
+// ErrInvalidName is returned for an email or session ID that cannot name a session file, e.g. with
+// a line break, which would add header lines, or a path separator
+var ErrInvalidName = errors.New("invalid email or session ID")
+
+// ErrNotIssued is returned for an answer to a question the session was not issued
+var ErrNotIssued = errors.New("question not issued to the session")
+
+// User represents the data for creating a new user session file
+type User struct {
+	UserEmail  string `json:"userEmail"`
+	SessionID  string `json:"sessionId"`
+	KioskID    string `json:"kioskId,omitempty"`
+	CreatedAt  string `json:"createdAt"`
+	ResumeCode string `json:"resumeCode,omitempty"` // Resumes the session from any kiosk with the email
+}
+
+// FileName returns the name of a session file
+func FileName(userEmail, sessionID string) string {
+	return userEmail + "_" + sessionID + ".txt"
+}
+
+// ValidateName returns ErrInvalidName when an email or session ID holds a line break, a slash, a
+// backslash or "..", which the header lines and the file name cannot hold
+func ValidateName(userEmail, sessionID string) error {
+	for _, value := range []string{userEmail, sessionID} {
+		if strings.ContainsAny(value, "\r\n/\\") || strings.Contains(value, "..") {
+			return ErrInvalidName
+		}
+	}
+	return nil
+}
+
+// path returns the path of a session file; a session with an invalid name was never registered,
+// so the error is both ErrNotFound and ErrInvalidName
+func path(dataDir, userEmail, sessionID string) (string, error) {
+	if err := ValidateName(userEmail, sessionID); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return filepath.Join(dataDir, FileName(userEmail, sessionID)), nil
+}
+
+// Read returns the content of a session file, or ErrNotFound
+func Read(dataDir, userEmail, sessionID string) (string, error) {
+	path, err := path(dataDir, userEmail, sessionID)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read user file: %w", err)
+	}
+	return string(content), nil
+}
+
+// Save writes the plain text session file for a user and returns the stored user and file name
+// It returns ErrInvalidName for an email or session ID ValidateName rejects and ErrExists for a
+// session that is already registered; callers serialize session file writes so the check holds
+func Save(ctx context.Context, dataDir, userEmail, sessionID, kioskID, frontendTimestamp string) (user User, filename string, err error) {
+	if err := ValidateName(userEmail, sessionID); err != nil {
+		return User{}, "", err
+	}
+	if strings.ContainsAny(kioskID+frontendTimestamp, "\r\n") {
+		return User{}, "", ErrInvalidName
+	}
+	defer storage.Trace(ctx, "save_user")(&err)
+
+	// Create server timestamp
+	serverTimestamp := time.Now().Format(time.RFC3339)
+
+	// Create user object with server timestamp
+	user = User{
+		UserEmail:  userEmail,
+		SessionID:  sessionID,
+		KioskID:    kioskID,
+		CreatedAt:  serverTimestamp,
+		ResumeCode: newResumeCode(),
+	}
+
+	filename = FileName(userEmail, sessionID)
+
+	// Ensure the data directory exists
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return User{}, "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// Never replace a registered session, which would drop its questions and answers
+	if _, err := os.Stat(filepath.Join(dataDir, filename)); err == nil {
+		return User{}, "", ErrExists
+	} else if !os.IsNotExist(err) {
+		return User{}, "", fmt.Errorf("failed to check user file: %w", err)
+	}
+
+	// Create enhanced plain text content with timestamps
+	fileContent := fmt.Sprintf("UserEmail: %s\nSessionID: %s\nServerTimestamp: %s\nResumeCode: %s\n",
+		userEmail, sessionID, serverTimestamp, user.ResumeCode)
+
+	// Record the kiosk the session came from
+	if kioskID != "" {
+		fileContent += fmt.Sprintf("KioskID: %s\n", kioskID)
+	}
+
+	// Add frontend timestamp if provided
+	if frontendTimestamp != "" {
+		fileContent += fmt.Sprintf("FrontendTimestamp: %s\n", frontendTimestamp)
+	}
+
+	// Write file as plain text
+	if err := storage.WriteFileAtomic(filepath.Join(dataDir, filename), []byte(fileContent), 0644); err != nil {
+		return User{}, "", fmt.Errorf("failed to write user file: %w", err)
+	}
+
+	return user, filename, nil
+}
+
+// resumeCodeLetters leaves out the letters and digits players confuse, like O and 0
+const resumeCodeLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// newResumeCode returns a random six letter resume code
+func newResumeCode() string {
+	code := make([]byte, 6)
+	rand.Read(code)
+	for i, b := range code {
+		code[i] = resumeCodeLetters[int(b)%len(resumeCodeLetters)]
+	}
+	return string(code)
+}
+
+// FindByResumeCode returns the ID of the session of an email holding a resume code, which is
+// compared ignoring case and spaces, or ErrNotFound
+func FindByResumeCode(ctx context.Context, dataDir, userEmail, code string) (sessionID string, err error) {
+	defer storage.Trace(ctx, "find_session")(&err)
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || ValidateName(userEmail, "") != nil {
+		return "", ErrNotFound
+	}
+	entries, err := os.ReadDir(dataDir)
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		sessionID, ok := strings.CutPrefix(entry.Name(), userEmail+"_")
+		sessionID, txt := strings.CutSuffix(sessionID, ".txt")
+		if !ok || !txt {
+			continue
+		}
+		content, err := Read(dataDir, userEmail, sessionID)
+		if err != nil {
+			return "", err
+		}
+		if header(content, "UserEmail") == userEmail && header(content, "ResumeCode") == code {
+			return sessionID, nil
+		}
+	}
+	return "", ErrNotFound
+}
+
+// header returns the value of the first "Key: value" line of a session file with the key
+func header(content, key string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if value, ok := strings.CutPrefix(line, key+": "); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// StartedAt returns when a session was registered, by the server clock
+func StartedAt(content string) time.Time {
+	started, _ := time.Parse(time.RFC3339, header(content, "ServerTimestamp"))
+	return started
+}
+
+// ExpiresAt returns when a session in progress is abandoned, ttl after it was registered; it is
+// zero when sessions do not expire (ttl 0) or the session is no longer in progress
+func ExpiresAt(content string, ttl time.Duration) time.Time {
+	started := StartedAt(content)
+	if ttl <= 0 || started.IsZero() || !InProgress(content) {
+		return time.Time{}
+	}
+	return started.Add(ttl)
+}
+
+// Expired reports whether a session was closed as abandoned, or is in progress past its time to live
+func Expired(content string, ttl time.Duration, now time.Time) bool {
+	if header(content, "SessionExpired") != "" {
+		return true
+	}
+	expires := ExpiresAt(content, ttl)
+	return !expires.IsZero() && !now.Before(expires)
+}
+
+// AppendAnswers appends the question IDs and the answers, uppercased, to a session file
+// It returns ErrNotFound when the session was never registered
+func AppendAnswers(ctx context.Context, dataDir, userEmail, sessionID string, questionIDs, answers []string) (err error) {
+	path, err := path(dataDir, userEmail, sessionID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	defer storage.Trace(ctx, "update_user")(&err)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read user file: %w", err)
+	}
+	content = append(content, strings.Join(questionIDs, ",")+"\n"+strings.ToUpper(strings.Join(answers, ","))+"\n"...)
+
+	if err := storage.WriteFileAtomic(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to update user file: %w", err)
+	}
+	return nil
+}
+
+// Issue is the quiz issued to a session: the question IDs and, at the same index, the display order
+// of each question's options as bank letters, e.g. "cadb"; sessions issued before options were
+// shuffled have no orders
+type Issue struct {
+	QuestionIDs  []string
+	OptionOrders []string
+}
+
+// Orders returns the display order of each issued question's options by question ID
+func (i Issue) Orders() map[string]string {
+	orders := make(map[string]string, len(i.OptionOrders))
+	for n, order := range i.OptionOrders {
+		if n < len(i.QuestionIDs) {
+			orders[i.QuestionIDs[n]] = order
+		}
+	}
+	return orders
+}
+
+// IssueQuestions records the quiz drawn for a session and returns the quiz the session holds
+// A session keeps the first quiz it is issued, later calls return it unchanged; it returns
+// ErrNotFound when the session was never registered
+func IssueQuestions(ctx context.Context, dataDir, userEmail, sessionID string, drawn Issue) (issued Issue, err error) {
+	content, err := Read(dataDir, userEmail, sessionID)
+	if err != nil {
+		return Issue{}, err
+	}
+	if issued := Issued(content); issued.QuestionIDs != nil {
+		return issued, nil
+	}
+	if !InProgress(content) {
+		return Issue{}, ErrClosed
+	}
+	defer storage.Trace(ctx, "issue_questions")(&err)
+
+	content += "Questions: " + strings.Join(drawn.QuestionIDs, ",") + "\n"
+	if len(drawn.OptionOrders) > 0 {
+		content += "OptionOrders: " + strings.Join(drawn.OptionOrders, ",") + "\n"
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(dataDir, FileName(userEmail, sessionID)), []byte(content), 0644); err != nil {
+		return Issue{}, fmt.Errorf("failed to update user file: %w", err)
+	}
+	return drawn, nil
+}
+
+// Issued returns the quiz issued to a session, with nil question IDs when none was
+func Issued(content string) Issue {
+	var issued Issue
+	for _, line := range strings.Split(content, "\n") {
+		if ids, ok := strings.CutPrefix(line, "Questions: "); ok {
+			issued.QuestionIDs = strings.Split(ids, ",")
+		}
+		if orders, ok := strings.CutPrefix(line, "OptionOrders: "); ok {
+			issued.OptionOrders = strings.Split(orders, ",")
+		}
+	}
+	return issued
+}
+
+// RecordServed records when a question issued to a session was first shown
+// Questions that were not issued, already served or shown after the session ended are not recorded;
+// it returns ErrNotFound when the session was never registered
+func RecordServed(ctx context.Context, dataDir, userEmail, sessionID, questionID string, at time.Time) (err error) {
+	content, err := Read(dataDir, userEmail, sessionID)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(Issued(content).QuestionIDs, questionID) || !InProgress(content) ||
+		!Timings(content)[questionID].ServedAt.IsZero() {
+		return nil
+	}
+	defer storage.Trace(ctx, "record_served")(&err)
+
+	content += fmt.Sprintf("Served: %s %s\n", questionID, at.UTC().Format(time.RFC3339Nano))
+	if err := storage.WriteFileAtomic(filepath.Join(dataDir, FileName(userEmail, sessionID)), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to update user file: %w", err)
+	}
+	return nil
+}
+
+// RecordAnswered records when questions of a session were answered, keyed by question ID
+// Questions that already have an answer time keep it; it returns ErrNotFound when the session was
+// never registered
+func RecordAnswered(ctx context.Context, dataDir, userEmail, sessionID string, answeredAt map[string]time.Time) (err error) {
+	content, err := Read(dataDir, userEmail, sessionID)
+	if err != nil {
+		return err
+	}
+	defer storage.Trace(ctx, "record_answered")(&err)
+
+	timings := Timings(content)
+	for _, questionID := range slices.Sorted(maps.Keys(answeredAt)) {
+		if timings[questionID].AnsweredAt.IsZero() {
+			content += fmt.Sprintf("Answered: %s %s\n", questionID, answeredAt[questionID].UTC().Format(time.RFC3339Nano))
+		}
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(dataDir, FileName(userEmail, sessionID)), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to update user file: %w", err)
+	}
+	return nil
+}
+
+// Timings returns when each question of a session was served and answered, by question ID
+func Timings(content string) map[string]scoring.Timing {
+	timings := map[string]scoring.Timing{}
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || (key != "Served" && key != "Answered") {
+			continue
+		}
+		questionID, stamp, _ := strings.Cut(value, " ")
+		at, err := time.Parse(time.RFC3339Nano, stamp)
+		if err != nil {
+			continue
+		}
+		timing := timings[questionID]
+		if key == "Served" {
+			timing.ServedAt = at
+		} else {
+			timing.AnsweredAt = at
+		}
+		timings[questionID] = timing
+	}
+	return timings
+}
+
+// RecordAnswer records the answer to one question issued to a session, and when it arrived, and
+// returns the answer the session holds for it: a question keeps its first answer, so repeating a
+// submission is harmless and reports repeated. It returns ErrNotFound, ErrNotIssued, or ErrClosed
+// once the session has answers in bulk, was evaluated or was closed
+func RecordAnswer(ctx context.Context, dataDir, userEmail, sessionID, questionID, answer string, at time.Time) (recorded string, repeated bool, err error) {
+	content, err := Read(dataDir, userEmail, sessionID)
+	if err != nil {
+		return "", false, err
+	}
+	if !slices.Contains(Issued(content).QuestionIDs, questionID) {
+		return "", false, ErrNotIssued
+	}
+	if recorded, ok := Answers(content)[questionID]; ok {
+		return recorded, true, nil
+	}
+	if !InProgress(content) {
+		return "", false, ErrClosed
+	}
+	defer storage.Trace(ctx, "record_answer")(&err)
+
+	content += fmt.Sprintf("Answer: %s %s\n", questionID, answer)
+	if Timings(content)[questionID].AnsweredAt.IsZero() {
+		content += fmt.Sprintf("Answered: %s %s\n", questionID, at.UTC().Format(time.RFC3339Nano))
+	}
+	if err := storage.WriteFileAtomic(filepath.Join(dataDir, FileName(userEmail, sessionID)), []byte(content), 0644); err != nil {
+		return "", false, fmt.Errorf("failed to update user file: %w", err)
+	}
+	return answer, false, nil
+}
+
+// Answers returns the answers recorded one at a time in a session, by question ID
+func Answers(content string) map[string]string {
+	answers := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		if value, ok := strings.CutPrefix(line, "Answer: "); ok {
+			questionID, answer, _ := strings.Cut(value, " ")
+			if _, seen := answers[questionID]; !seen {
+				answers[questionID] = answer
+			}
+		}
+	}
+	return answers
+}
+
+// MarkEvaluated records that the answers of a session were scored, which ends the session
+// Answers scored from a bulk submission, by question ID, are recorded first for the questions
+// without one, so a later evaluation cannot score different answers
+func MarkEvaluated(ctx context.Context, dataDir, userEmail, sessionID string, answers map[string]string, at time.Time) (err error) {
+	content, err := Read(dataDir, userEmail, sessionID)
+	if err != nil {
+		return err
+	}
+	defer storage.Trace(ctx, "mark_evaluated")(&err)
+
+	held := Answers(content)
+	for _, questionID := range slices.Sorted(maps.Keys(answers)) {
+		if _, ok := held[questionID]; !ok {
+			content += fmt.Sprintf("Answer: %s %s\n", questionID, answers[questionID])
+		}
+	}
+	content += fmt.Sprintf("Evaluated: %s\n", at.Format(time.RFC3339))
+	if err := storage.WriteFileAtomic(filepath.Join(dataDir, FileName(userEmail, sessionID)), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to update user file: %w", err)
+	}
+	return nil
+}
+
+// Evaluated reports whether the answers a session holds were scored
+func Evaluated(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "Evaluated: ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Closed reports whether a session was closed unanswered when its event ended
+func Closed(content string) bool {
+	return header(content, "SessionClosed") != ""
+}
+
+// CountAttempts counts the session files an email already has in the event
+func CountAttempts(ctx context.Context, dataDir, userEmail string) (count int, err error) {
+	defer storage.Trace(ctx, "count_attempts")(&err)
+
+	if err := ValidateName(userEmail, ""); err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read data directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), userEmail+"_") && strings.HasSuffix(entry.Name(), ".txt") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CloseInProgress marks the session files that never received answers as closed
+func CloseInProgress(ctx context.Context, dataDir string, now time.Time) (closed int, err error) {
+	defer storage.Trace(ctx, "close_sessions")(&err)
+	return closeSessions(dataDir, fmt.Sprintf("SessionClosed: %s\n", now.Format(time.RFC3339)), InProgress)
+}
+
+// CloseExpired marks the session files still in progress ttl after they were registered as expired
+func CloseExpired(ctx context.Context, dataDir string, ttl time.Duration, now time.Time) (closed int, err error) {
+	defer storage.Trace(ctx, "expire_sessions")(&err)
+	return closeSessions(dataDir, fmt.Sprintf("SessionExpired: %s\n", now.Format(time.RFC3339)), func(content string) bool {
+		return InProgress(content) && Expired(content, ttl, now)
+	})
+}
+
+// closeSessions appends line to the session files that match
+func closeSessions(dataDir, line string, match func(content string) bool) (closed int, err error) {
+	paths, err := filepath.Glob(filepath.Join(dataDir, "*_*.txt"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list session files: %w", err)
+	}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return closed, fmt.Errorf("failed to read session file: %w", err)
+		}
+		if !strings.HasPrefix(string(content), "UserEmail: ") || !match(string(content)) {
+			continue
+		}
+
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return closed, fmt.Errorf("failed to open session file: %w", err)
+		}
+		_, err = file.WriteString(line)
+		file.Close()
+		if err != nil {
+			return closed, fmt.Errorf("failed to write session file: %w", err)
+		}
+		closed++
+	}
+	return closed, nil
+}
+
+// InProgress reports whether a session file has no answers in bulk and is not evaluated, closed or expired yet
+// Header lines are "Key: value" pairs, answers are appended as plain comma separated lines
+func InProgress(content string) bool {
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		if strings.HasPrefix(line, "SessionClosed: ") || strings.HasPrefix(line, "SessionExpired: ") ||
+			strings.HasPrefix(line, "Evaluated: ") || !strings.Contains(line, ": ") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+// Package storage reads and writes the plain text files of an event's data directory
+//
+// Every function takes the data directory of one event; callers serialize writes to the same
+// file, e.g. the server holds its winner lock around ReadWinnerCount and WriteWinnerCount
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"delfos/scoring"
+)
+
+// Trace is called at the start of every storage operation and the returned function with its
+// error at the end; the server sets it to record spans and the storage error metric
+// Storage helpers call it as: defer storage.Trace(ctx, "operation")(&err)
+var Trace = func(ctx context.Context, operation string) func(err *error) {
+	return func(*error) {}
+}
+
+// WriteFileAtomic replaces the file at path so readers and crashes never see it half written:
+// the content goes to a temporary file in the same directory, is synced, then renamed over path
+func WriteFileAtomic(path string, content []byte, perm os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(content); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadWinnerCount reads the current winner count from the file in dataDir
+func ReadWinnerCount(ctx context.Context, dataDir string) (count int, err error) {
+	defer Trace(ctx, "read_winner_count")(&err)
+
+	filePath := filepath.Join(dataDir, "winner_count.txt")
+
+	// Create data directory if it doesn't exist
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// If file doesn't exist, return 0
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	// Read the file
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read winner count file: %w", err)
+	}
+
+	// Parse the count
+	countStr := strings.TrimSpace(string(content))
+	if countStr == "" {
+		return 0, nil
+	}
+
+	count, err = strconv.Atoi(countStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid winner count format in file: %s", countStr)
+	}
+
+	return count, nil
+}
+
+// WriteWinnerCount writes the winner count to the file in dataDir
+func WriteWinnerCount(ctx context.Context, dataDir string, count int) (err error) {
+	defer Trace(ctx, "write_winner_count")(&err)
+
+	filePath := filepath.Join(dataDir, "winner_count.txt")
+
+	// Create data directory if it doesn't exist
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	// Replace the file atomically so a crash or shutdown never leaves a partial count
+	content := fmt.Sprintf("%d", count)
+	err = WriteFileAtomic(filePath, []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write winner count file: %w", err)
+	}
+
+	return nil
+}
+
+// AppendResult appends an evaluation summary line to the event's results file
+// Fields are separated by "|": time, email, session, correct, total, percentage, time bonus and total
+// response time in milliseconds, 0 when not every question was timed
+func AppendResult(ctx context.Context, dataDir, userEmail, sessionID string, score scoring.Score) (err error) {
+	defer Trace(ctx, "append_result")(&err)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dataDir, "results.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open results file: %w", err)
+	}
+	defer file.Close()
+
+	line := fmt.Sprintf("%s|%s|%s|%d|%d|%.2f|%.2f|%d\n", time.Now().Format(time.RFC3339), userEmail, sessionID,
+		score.CorrectAnswers, score.TotalQuestions, score.ScorePercentage, score.TimeBonus, score.TotalResponseTimeMs)
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write results file: %w", err)
+	}
+	return nil
+}
+
+// WinnerClaimed reports whether a session already counted as a winner in the event's winners file
+func WinnerClaimed(ctx context.Context, dataDir, userEmail, sessionID string) (claimed bool, err error) {
+	defer Trace(ctx, "read_winner_claims")(&err)
+
+	content, err := os.ReadFile(filepath.Join(dataDir, "winners.txt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read winners file: %w", err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) == 3 && fields[1] == userEmail && fields[2] == sessionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AppendWinnerClaim records that a session counted as a winner, as time|email|session in the
+// event's winners file, so its receipt cannot count again
+func AppendWinnerClaim(ctx context.Context, dataDir, userEmail, sessionID string) (err error) {
+	defer Trace(ctx, "append_winner_claim")(&err)
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(filepath.Join(dataDir, "winners.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open winners file: %w", err)
+	}
+	defer file.Close()
+
+	line := fmt.Sprintf("%s|%s|%s\n", time.Now().Format(time.RFC3339), userEmail, sessionID)
+	if _, err := file.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write winners file: %w", err)
+	}
+	return nil
+}
@@ -1,7 +1,7 @@
 import { LitElement, html, css, PropertyValues } from 'lit';
 import { customElement, property, state } from 'lit/decorators.js';
 import { TerminalLine, TerminalState, Question } from '../types/terminal';
-import { TerminalAPI } from '../services/terminal-api';
+import { TerminalAPI, ResumeSessionRequest } from '../services/terminal-api';
 import './terminal-input';
 import './terminal-output';
 
@@ -536,6 +536,12 @@ export class MatrixTerminal extends LitElement {
   @state()
   private userAnswers: string[] = [];
 
+  // Signed by the server after the evaluation, required to record a winner
+  private evaluationReceipt: string = '';
+
+  // Where the session token is kept, so a refresh continues the session instead of starting over
+  private readonly sessionTokenKey = 'delfos.sessionToken';
+
   @state()
   private isAnsweringQuestions: boolean = false;
 
@@ -630,6 +636,12 @@ export class MatrixTerminal extends LitElement {
     // Add another small delay before collecting email
     await this.delay(500);
     
+    // Continue the session this kiosk left unfinished, e.g. after a refresh
+    const sessionToken = localStorage.getItem(this.sessionTokenKey);
+    if (sessionToken && await this.resumeSession({ sessionToken })) {
+      return;
+    }
+    
     this.collectUserEmail();
   }
 
@@ -756,7 +768,14 @@ export class MatrixTerminal extends LitElement {
     if (this.isCollectingEmail) {
       // Simple email validation
       const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
-      if (emailRegex.test(input)) {
+      const [email, resumeCode] = input.split(/\s+/);
+      if (resumeCode && emailRegex.test(email)) {
+        // The email followed by a resume code continues a session started on another kiosk
+        this.isCollectingEmail = false;
+        if (!await this.resumeSession({ userEmail: email, resumeCode })) {
+          this.collectUserEmail();
+        }
+      } else if (emailRegex.test(input)) {
         this.userEmail = input;
         this.isCollectingEmail = false;
         this.proceedAfterEmail();
@@ -799,7 +818,7 @@ export class MatrixTerminal extends LitElement {
         this.selectedProfile = "1";
         
         // Get random questions from API for Créditos profile
-        const result = await this.api.getRandomQuestions("1");
+        const result = await this.api.getRandomQuestions("1", this.userEmail, this.sessionId);
         this.currentQuestions = result.questionIds;
         this.currentQuestionIndex = 0;
         this.userAnswers = [];
@@ -820,7 +839,7 @@ export class MatrixTerminal extends LitElement {
         this.selectedProfile = "2";
         
         // Get random questions from API for Servicio profile
-        const result = await this.api.getRandomQuestions("2");
+        const result = await this.api.getRandomQuestions("2", this.userEmail, this.sessionId);
         this.currentQuestions = result.questionIds;
         this.currentQuestionIndex = 0;
         this.userAnswers = [];
@@ -841,7 +860,7 @@ export class MatrixTerminal extends LitElement {
         this.selectedProfile = "3";
         
         // Get random questions from API for Expansion profile
-        const result = await this.api.getRandomQuestions("3");
+        const result = await this.api.getRandomQuestions("3", this.userEmail, this.sessionId);
         this.currentQuestions = result.questionIds;
         this.currentQuestionIndex = 0;
         this.userAnswers = [];
@@ -888,26 +907,10 @@ export class MatrixTerminal extends LitElement {
       return;
     }
 
-    // Generate question ID based on selected profile
-    let questionPrefix = 'CRD'; // Default to CRD
-    switch (this.selectedProfile) {
-      case "1":
-        questionPrefix = 'CRD';
-        break;
-      case "2":
-        questionPrefix = 'SRV';
-        break;
-      case "3":
-        questionPrefix = 'EXP';
-        break;
-      default:
-        questionPrefix = 'CRD';
-    }
-
-    const questionId = `${questionPrefix}${String(this.currentQuestions[this.currentQuestionIndex]).padStart(4, '0')}`;
+    const questionId = this.questionId(this.currentQuestions[this.currentQuestionIndex]);
     
     try {
-      const question = await this.api.getQuestion(questionId);
+      const question = await this.api.getQuestion(questionId, this.userEmail, this.sessionId);
       const questionNumber = this.currentQuestionIndex + 1;
       
       // Display question header
@@ -951,6 +954,20 @@ export class MatrixTerminal extends LitElement {
     }
   }
 
+  // Full question ID based on selected profile, e.g. CRD0007
+  private questionId(questionNumber: number): string {
+    let questionPrefix = 'CRD'; // Default to CRD
+    switch (this.selectedProfile) {
+      case "2":
+        questionPrefix = 'SRV';
+        break;
+      case "3":
+        questionPrefix = 'EXP';
+        break;
+    }
+    return `${questionPrefix}${String(questionNumber).padStart(4, '0')}`;
+  }
+
   private async processAnswer(input: string): Promise<void> {
     const validAnswers = ['a', 'b', 'c', 'd'];
     const answer = input.toLowerCase().trim();
@@ -960,14 +977,28 @@ export class MatrixTerminal extends LitElement {
       return;
     }
 
-    // Store the answer
-    this.userAnswers.push(answer);
+    // Send the answer right away, so a crash or refresh does not lose it; the server times it
+    const questionId = this.questionId(this.currentQuestions[this.currentQuestionIndex]);
+    let recorded = answer;
+    try {
+      const result = await this.api.submitAnswer(this.sessionId, this.userEmail, questionId, answer);
+      if (result.code === 'ANSWER_CONFLICT') {
+        recorded = result.details.answer; // Answered before, e.g. from another kiosk
+      } else if (result.status !== 'success') {
+        this.addSystemMessage('⚠️ No se pudo guardar la respuesta. Intenta de nuevo.');
+        return;
+      }
+    } catch (error) {
+      this.addSystemMessage('⚠️ No se pudo guardar la respuesta. Intenta de nuevo.');
+      return;
+    }
+    this.userAnswers.push(recorded);
     
     // Move to next question
     this.currentQuestionIndex++;
     
     if (this.currentQuestionIndex < this.currentQuestions.length) {
-      this.addSystemMessage(`Respuesta registrada: ${answer.toUpperCase()}`);
+      this.addSystemMessage(`Respuesta registrada: ${recorded.toUpperCase()}`);
       await this.delay(1000);
       await this.loadCurrentQuestion();
     } else {
@@ -977,48 +1008,15 @@ export class MatrixTerminal extends LitElement {
 
   private async finishQuestions(): Promise<void> {
     this.isAnsweringQuestions = false;
+    localStorage.removeItem(this.sessionTokenKey);
     this.addSystemMessage('¡Evaluación completada!');
     
-    // Update user file with questions and answers
-    try {
-      const updateSuccess = await this.api.updateUserWithAnswers(
-        this.userEmail,
-        this.sessionId,
-        this.currentQuestions,
-        this.userAnswers
-      );
-      
-      if (updateSuccess) {
-        this.addSystemMessage(`Respuestas enviadas: ${this.userAnswers.join(', ').toUpperCase()}`);
-      } else {
-        this.addSystemMessage('⚠️ Error al guardar resultados, pero se completó la evaluación.');
-      }
-    } catch (error) {
-      this.addSystemMessage('⚠️ Error al guardar resultados, pero se completó la evaluación.');
-    }
-    
-    // Evaluate answers using the API
+    // Evaluate the answers the server holds for the session
     this.addSystemMessage('🔄 Evaluando respuestas...');
     
     try {
-      // Format question IDs correctly based on selected profile
-      let questionPrefix = 'CRD'; // Default to CRD
-      switch (this.selectedProfile) {
-        case "1":
-          questionPrefix = 'CRD';
-          break;
-        case "2":
-          questionPrefix = 'SRV';
-          break;
-        case "3":
-          questionPrefix = 'EXP';
-          break;
-        default:
-          questionPrefix = 'CRD';
-      }
-      
-      const questionIds = this.currentQuestions.map(q => `${questionPrefix}${String(q).padStart(4, '0')}`);
-      const evaluation = await this.api.evaluateAnswers(questionIds, this.userAnswers);
+      const evaluation = await this.api.evaluateSession(this.sessionId, this.userEmail);
+      this.evaluationReceipt = evaluation.receipt ?? '';
       
       if (evaluation.status === 'success') {
         // Display detailed results
@@ -1174,7 +1172,7 @@ export class MatrixTerminal extends LitElement {
         
         // Increment winner count for physical prize
         try {
-          const winnerResponse = await this.api.incrementWinnerCount(this.userEmail, this.sessionId);
+          const winnerResponse = await this.api.incrementWinnerCount(this.userEmail, this.sessionId, this.evaluationReceipt);
           // Update the result to include winner number
           this.rouletteResult = `☕ ¡Ganaste un termo! (Ganador #${winnerResponse.winnerCount})`;
         } catch (error) {
@@ -1210,6 +1208,12 @@ export class MatrixTerminal extends LitElement {
       if (result.user?.createdAt) {
         this.addSystemMessage(`Confirmación del servidor: ${new Date(result.user.createdAt).toLocaleString()}`);
       }
+      if (result.sessionToken) {
+        localStorage.setItem(this.sessionTokenKey, result.sessionToken);
+      }
+      if (result.user?.resumeCode) {
+        this.addSystemMessage(`Código para continuar en otro kiosco: ${result.user.resumeCode}`);
+      }
       
       // Show the main menu after a delay
       setTimeout(() => {
@@ -1237,6 +1241,54 @@ export class MatrixTerminal extends LitElement {
     this.requestUpdate();
   }
 
+  // Restores the issued questions, the answers the server holds and the time left of a session, then
+  // continues at the first unanswered question; returns false when the session cannot be resumed
+  private async resumeSession(request: ResumeSessionRequest): Promise<boolean> {
+    try {
+      const result = await this.api.resumeSession(request);
+      localStorage.setItem(this.sessionTokenKey, result.sessionToken);
+
+      this.userEmail = result.userEmail;
+      this.sessionId = result.sessionId;
+      this.selectedProfile = result.profile ?? '';
+      this.currentQuestions = result.questionIds;
+      const next = result.answers.indexOf('');
+      this.currentQuestionIndex = next === -1 ? result.answers.length : next;
+      this.userAnswers = result.answers.slice(0, this.currentQuestionIndex);
+      this.addSystemMessage(`Sesión recuperada: ${this.userEmail}`);
+
+      if (this.currentQuestions.length === 0) {
+        this.addPromptMessage('Elige tu perfil de investigador:');
+        this.addPromptMessage('> [1] Créditos  [2] Servicio  [3] Expansión');
+        return true;
+      }
+
+      // The server clock decides when the session expires
+      if (result.remainingSeconds !== undefined) {
+        this.countdownTime = Math.min(this.totalCountdownTime, result.remainingSeconds);
+      }
+      this.startCountdown();
+      this.isAnsweringQuestions = true;
+      this.addSystemMessage(`Respuestas registradas: ${result.answered}/${this.currentQuestions.length}`);
+      await this.loadCurrentQuestion();
+      return true;
+
+    } catch (error) {
+      const code = error instanceof Error ? error.message : '';
+      if (request.sessionToken) {
+        localStorage.removeItem(this.sessionTokenKey);
+      }
+      if (code === 'SESSION_EXPIRED') {
+        this.addSystemMessage('⚠ La sesión expiró. Inicia una nueva.');
+      } else if (code === 'SESSION_CLOSED') {
+        this.addSystemMessage('⚠ La sesión ya fue evaluada.');
+      } else if (request.resumeCode) {
+        this.addSystemMessage('⚠ No se encontró una sesión con ese correo y código.');
+      }
+      return false;
+    }
+  }
+
   // Method to get the saved email (for debugging/testing)
   public getUserEmail(): string {
     return this.userEmail;
@@ -15,13 +15,37 @@ export interface CreateUserResponse {
     userEmail: string;
     sessionId: string;
     createdAt: string;
+    resumeCode?: string; // Shown to the player to continue on another kiosk
   };
+  sessionToken: string; // Kept by this kiosk to continue after a refresh
+}
+
+// Interface for resuming a session, by email and resume code or by session token
+export interface ResumeSessionRequest {
+  userEmail?: string;
+  resumeCode?: string;
+  sessionToken?: string;
+}
+
+export interface ResumeSessionResponse {
+  status: string;
+  message: string;
+  userEmail: string;
+  sessionId: string;
+  sessionToken: string;
+  profile?: string;
+  questionIds: number[];
+  answers: string[]; // Aligned with questionIds, empty when not answered yet
+  answered: number;
+  remainingSeconds?: number; // Until the session expires, absent when sessions never expire
+  expiresAt?: string;
 }
 
 // Interface for winner count API
 export interface WinnerCountRequest {
-  userEmail?: string;
-  sessionId?: string;
+  userEmail: string;
+  sessionId: string;
+  receipt: string; // From the session's passed evaluation
 }
 
 export interface WinnerCountResponse {
@@ -150,10 +174,15 @@ export class TerminalAPI {
     }
   }
 
-  // Get question by ID from Go API
-  async getQuestion(questionId: string): Promise<Question> {
+  // Get question by ID from Go API, with the options in the session's shuffled order when one is given
+  async getQuestion(questionId: string, userEmail?: string, sessionId?: string): Promise<Question> {
     try {
-      const response = await fetch(`${this.goApiUrl}/question?id=${questionId}`);
+      const params = new URLSearchParams({ id: questionId });
+      if (userEmail && sessionId) {
+        params.set('userEmail', userEmail);
+        params.set('sessionId', sessionId);
+      }
+      const response = await fetch(`${this.goApiUrl}/question?${params}`);
       
       if (!response.ok) {
         throw new Error(`HTTP error! status: ${response.status}`);
@@ -180,10 +209,15 @@ export class TerminalAPI {
     }
   }
 
-  // Get random question IDs from Go API
-  async getRandomQuestions(profile: string = "1"): Promise<{profile: string, questionIds: number[]}> {
+  // Get random question IDs from Go API; a session keeps the first set it is issued
+  async getRandomQuestions(profile: string = "1", userEmail?: string, sessionId?: string): Promise<{profile: string, questionIds: number[]}> {
     try {
-      const response = await fetch(`${this.goApiUrl}/choose-questions?profile=${profile}`);
+      const params = new URLSearchParams({ profile });
+      if (userEmail && sessionId) {
+        params.set('userEmail', userEmail);
+        params.set('sessionId', sessionId);
+      }
+      const response = await fetch(`${this.goApiUrl}/choose-questions?${params}`);
       
       if (!response.ok) {
         throw new Error(`HTTP error! status: ${response.status}`);
@@ -206,7 +240,7 @@ export class TerminalAPI {
   }
 
   // Update user session file with questions and answers via Go API
-  async updateUserWithAnswers(userEmail: string, sessionId: string, questionIds: number[], userAnswers: string[]): Promise<boolean> {
+  async updateUserWithAnswers(userEmail: string, sessionId: string, questionIds: number[], userAnswers: string[], answeredAt?: string[]): Promise<boolean> {
     try {
       const response = await fetch(`${this.goApiUrl}/user/update`, {
         method: 'POST',
@@ -217,7 +251,8 @@ export class TerminalAPI {
           userEmail,
           sessionId,
           questionIds,
-          userAnswers
+          userAnswers,
+          answeredAt
         })
       });
 
@@ -255,7 +290,59 @@ export class TerminalAPI {
     }
   }
 
-  async evaluateAnswers(questionIds: string[], userAnswers: string[]): Promise<any> {
+  // Records one answer as soon as it is given; a question keeps its first answer, so retries are safe
+  // and an ANSWER_CONFLICT error carries the answer the server already holds in details.answer
+  async submitAnswer(sessionId: string, userEmail: string, questionId: string, answer: string): Promise<any> {
+    const response = await fetch(`${this.goApiUrl}/session/${encodeURIComponent(sessionId)}/answer`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({
+        userEmail,
+        questionId,
+        answer
+      })
+    });
+
+    return await response.json();
+  }
+
+  // Continues a session after a refresh or on another kiosk; errors carry the envelope code, such as
+  // SESSION_EXPIRED, SESSION_CLOSED or INVALID_SESSION_TOKEN
+  async resumeSession(request: ResumeSessionRequest): Promise<ResumeSessionResponse> {
+    const response = await fetch(`${this.goApiUrl}/session/resume`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify(request)
+    });
+
+    const result = await response.json();
+    if (!response.ok) {
+      throw new Error(result.code || `HTTP error! status: ${response.status}`);
+    }
+    return result;
+  }
+
+  // Scores the answers the server holds for the session; the response carries the receipt incrementWinnerCount needs
+  async evaluateSession(sessionId: string, userEmail: string): Promise<any> {
+    const response = await fetch(`${this.goApiUrl}/session/${encodeURIComponent(sessionId)}/evaluate`, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+      },
+      body: JSON.stringify({
+        userEmail
+      })
+    });
+
+    return await response.json();
+  }
+
+  // With userEmail and sessionId the response carries the receipt incrementWinnerCount needs
+  async evaluateAnswers(questionIds: string[], userAnswers: string[], userEmail?: string, sessionId?: string): Promise<any> {
     try {
       const response = await fetch(`${this.goApiUrl}/evaluate-answers`, {
         method: 'POST',
@@ -264,7 +351,9 @@ export class TerminalAPI {
         },
         body: JSON.stringify({
           questionIds,
-          userAnswers
+          userAnswers,
+          userEmail,
+          sessionId
         })
       });
       
@@ -403,11 +492,9 @@ export class TerminalAPI {
     }
   }
 
-  async incrementWinnerCount(userEmail?: string, sessionId?: string): Promise<WinnerCountResponse> {
+  async incrementWinnerCount(userEmail: string, sessionId: string, receipt: string): Promise<WinnerCountResponse> {
     try {
-      const requestBody: WinnerCountRequest = {};
-      if (userEmail) requestBody.userEmail = userEmail;
-      if (sessionId) requestBody.sessionId = sessionId;
+      const requestBody: WinnerCountRequest = { userEmail, sessionId, receipt };
 
       const response = await fetch(`${this.goApiUrl}/winner/increment`, {
         method: 'POST',
@@ -27,7 +27,7 @@ echo "2. Testing valid answer evaluation (all correct answers)..."
 response=$(curl -s -X POST "$BASE_URL/evaluate-answers" \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "2", "3"],
+        "questionIds": ["CRD0001", "CRD0002", "CRD0003"],
         "userAnswers": ["a", "b", "c"]
     }')
 
@@ -40,7 +40,7 @@ echo "3. Testing mixed correct/incorrect answers..."
 response=$(curl -s -X POST "$BASE_URL/evaluate-answers" \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "2", "3", "4", "5"],
+        "questionIds": ["CRD0001", "CRD0002", "CRD0003", "CRD0004", "CRD0005"],
         "userAnswers": ["a", "x", "c", "d", "wrong"]
     }')
 
@@ -53,7 +53,7 @@ echo "4. Testing all incorrect answers..."
 response=$(curl -s -X POST "$BASE_URL/evaluate-answers" \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "2", "3"],
+        "questionIds": ["CRD0001", "CRD0002", "CRD0003"],
         "userAnswers": ["x", "y", "z"]
     }')
 
@@ -66,7 +66,7 @@ echo "5. Testing invalid request (mismatched arrays)..."
 response=$(curl -s -X POST "$BASE_URL/evaluate-answers" \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "2", "3"],
+        "questionIds": ["CRD0001", "CRD0002", "CRD0003"],
         "userAnswers": ["a", "b"]
     }')
 
@@ -79,7 +79,7 @@ echo "6. Testing with invalid question ID..."
 response=$(curl -s -X POST "$BASE_URL/evaluate-answers" \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "999", "3"],
+        "questionIds": ["CRD0001", "CRD0999", "CRD0003"],
         "userAnswers": ["a", "b", "c"]
     }')
 
@@ -92,7 +92,7 @@ echo "7. Testing case insensitive comparison..."
 response=$(curl -s -X POST "$BASE_URL/evaluate-answers" \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "2", "3"],
+        "questionIds": ["CRD0001", "CRD0002", "CRD0003"],
         "userAnswers": ["A", "B", "C"]
     }')
 
@@ -103,7 +103,7 @@ echo ""
 echo "🎉 All tests completed!"
 echo ""
 echo "To test the full frontend flow:"
-echo "1. Start the Go server: cd src/backend/go/cmd && go run main.go"
+echo "1. Start the Go server: cd src/backend/go/cmd && go run ."
 echo "2. Start the frontend: cd src/frontend && npm run dev"
 echo "3. Open the terminal in your browser"
 echo "4. Complete the question flow and see the evaluation results"
@@ -3,6 +3,29 @@
 echo "🧪 Testing Winner Count API"
 echo "================================"
 
+API=http://localhost:8080
+
+# Plays a passing quiz for a new session and prints its evaluation receipt
+win_session() {
+  local email=$1 session=$2 drawn profile prefix ids answers letter correct
+  curl -s -X POST "$API/user/create" -H "Content-Type: application/json" \
+    -d "{\"userEmail\": \"$email\", \"sessionId\": \"$session\"}" > /dev/null
+  drawn=$(curl -s "$API/choose-questions?userEmail=$email&sessionId=$session")
+  profile=$(echo "$drawn" | jq -r '.profile')
+  prefix=$(case $profile in 2) echo SRV;; 3) echo EXP;; *) echo CRD;; esac)
+  ids=$(echo "$drawn" | jq -c --arg p "$prefix" '[.questionIds[] | $p + (tostring | ("000" + .)[-4:])]')
+  # The session sees the options shuffled, so answer with the letter the correct one is shown under
+  answers=$(echo "$ids" | jq -r '.[]' | while read -r id; do
+    letter=$(curl -s "$API/answer?question_id=$id" | jq -r '.answer')
+    correct=$(curl -s "$API/question?id=$id" | jq -r --arg l "$letter" '.options[($l | explode[0]) - 97]')
+    curl -s "$API/question?id=$id&userEmail=$email&sessionId=$session" \
+      | jq --arg c "$correct" '[(.options | index($c)) + 97] | implode'
+  done | jq -s -c '.')
+  curl -s -X POST "$API/evaluate-answers" -H "Content-Type: application/json" \
+    -d "{\"questionIds\": $ids, \"userAnswers\": $answers, \"userEmail\": \"$email\", \"sessionId\": \"$session\"}" \
+    | jq -r '.receipt'
+}
+
 # Test 1: Get initial winner count
 echo "1. Getting current winner count..."
 curl -X GET "http://localhost:8080/winner/count" \
@@ -10,22 +33,25 @@ curl -X GET "http://localhost:8080/winner/count" \
 
 echo -e "\n"
 
-# Test 2: Increment winner count without user info
-echo "2. Incrementing winner count (anonymous)..."
+# Test 2: Increment winner count without a receipt is refused
+echo "2. Incrementing winner count without a receipt (refused)..."
 curl -X POST "http://localhost:8080/winner/increment" \
   -H "Content-Type: application/json" \
-  -d '{}' | jq .
+  -d '{"userEmail": "test@example.com", "sessionId": "test-session-123"}' | jq .
 
 echo -e "\n"
 
-# Test 3: Increment winner count with user info
-echo "3. Incrementing winner count with user info..."
+# Test 3: Increment winner count with the receipt of a passed quiz
+echo "3. Passing a quiz and incrementing winner count with its receipt..."
+SESSION="test-session-$(date +%s)"
+RECEIPT=$(win_session "test@example.com" "$SESSION")
 curl -X POST "http://localhost:8080/winner/increment" \
   -H "Content-Type: application/json" \
-  -d '{
-    "userEmail": "test@example.com",
-    "sessionId": "test-session-123"
-  }' | jq .
+  -d "{
+    \"userEmail\": \"test@example.com\",
+    \"sessionId\": \"$SESSION\",
+    \"receipt\": \"$RECEIPT\"
+  }" | jq .
 
 echo -e "\n"
 
@@ -3,6 +3,29 @@
 echo "🧪 Testing Winner Count Logic"
 echo "=============================="
 
+API=http://localhost:8080
+
+# Plays a passing quiz for a new session and prints its evaluation receipt
+win_session() {
+  local email=$1 session=$2 drawn profile prefix ids answers letter correct
+  curl -s -X POST "$API/user/create" -H "Content-Type: application/json" \
+    -d "{\"userEmail\": \"$email\", \"sessionId\": \"$session\"}" > /dev/null
+  drawn=$(curl -s "$API/choose-questions?userEmail=$email&sessionId=$session")
+  profile=$(echo "$drawn" | jq -r '.profile')
+  prefix=$(case $profile in 2) echo SRV;; 3) echo EXP;; *) echo CRD;; esac)
+  ids=$(echo "$drawn" | jq -c --arg p "$prefix" '[.questionIds[] | $p + (tostring | ("000" + .)[-4:])]')
+  # The session sees the options shuffled, so answer with the letter the correct one is shown under
+  answers=$(echo "$ids" | jq -r '.[]' | while read -r id; do
+    letter=$(curl -s "$API/answer?question_id=$id" | jq -r '.answer')
+    correct=$(curl -s "$API/question?id=$id" | jq -r --arg l "$letter" '.options[($l | explode[0]) - 97]')
+    curl -s "$API/question?id=$id&userEmail=$email&sessionId=$session" \
+      | jq --arg c "$correct" '[(.options | index($c)) + 97] | implode'
+  done | jq -s -c '.')
+  curl -s -X POST "$API/evaluate-answers" -H "Content-Type: application/json" \
+    -d "{\"questionIds\": $ids, \"userAnswers\": $answers, \"userEmail\": \"$email\", \"sessionId\": \"$session\"}" \
+    | jq -r '.receipt'
+}
+
 # Get current winner count
 echo "📊 Current winner count:"
 CURRENT_COUNT=$(curl -s http://localhost:8080/winner/count | jq -r '.winnerCount')
@@ -19,8 +42,10 @@ if [ $NEEDED -gt 0 ]; then
     echo "Need to increment $NEEDED times to reach $TARGET"
     
     for i in $(seq 1 $NEEDED); do
+        SESSION="test$i-$(date +%s)"
+        RECEIPT=$(win_session "testuser$i@example.com" "$SESSION")
         RESPONSE=$(curl -s -X POST -H "Content-Type: application/json" \
-            -d "{\"email\": \"testuser$i@example.com\", \"sessionId\": \"test$i\"}" \
+            -d "{\"userEmail\": \"testuser$i@example.com\", \"sessionId\": \"$SESSION\", \"receipt\": \"$RECEIPT\"}" \
             http://localhost:8080/winner/increment)
         NEW_COUNT=$(echo $RESPONSE | jq -r '.winnerCount')
         echo "Increment $i: Winner count is now $NEW_COUNT"
@@ -97,7 +97,7 @@ echo "5. Testing /evaluate-answers endpoint..."
 response=$(curl -s -X POST http://localhost:8080/evaluate-answers \
     -H "Content-Type: application/json" \
     -d '{
-        "questionIds": ["1", "2", "3"],
+        "questionIds": ["CRD0001", "CRD0002", "CRD0003"],
         "userAnswers": ["a", "b", "c"]
     }')
 
@@ -107,7 +107,7 @@ echo ""
 echo "🏁 Integration test complete!"
 echo ""
 echo "📋 Next steps:"
-echo "   1. Start your Go server: cd src/backend/go/cmd && go run main.go"
+echo "   1. Start your Go server: cd src/backend/go/cmd && go run ."
 echo "   2. Open your frontend: cd src/frontend && npm run dev"
 echo "   3. Navigate to debug.html or index.html"
 echo "   4. Test the email collection and menu flow"